@@ -6,16 +6,22 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
 	"runtime"
 	"syscall"
-	"time"
 
 	"logchat/agent/internal/buffer"
 	"logchat/agent/internal/collector"
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/metrics"
+	"logchat/agent/internal/pidfile"
 	"logchat/agent/internal/sender"
 )
 
+// log is the logger for agent lifecycle messages
+var log = logging.For("agent")
+
 var (
 	Version   = "1.0.0"
 	BuildTime = "unknown"
@@ -28,7 +34,11 @@ func main() {
 	showVersion := flag.Bool("version", false, "Show version information")
 	generateConfig := flag.Bool("generate-config", false, "Generate a sample config file")
 	validate := flag.Bool("validate", false, "Validate config file and exit")
+	checkServer := flag.Bool("check-server", false, "Validate config, test the server connection and API key, then exit")
+	dryRun := flag.Bool("dry-run", false, "Check that the server and every collector are ready, without collecting or sending, then exit")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	readStdin := flag.Bool("stdin", false, "Read log lines from stdin and exit at EOF")
+	pidFile := flag.String("pid-file", "", "Write the agent's PID to this file (overrides agent.pid_file)")
 	flag.Parse()
 
 	// Set verbose mode
@@ -52,15 +62,25 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Print banner
-	printBanner()
+	// Report the real build to the server
+	sender.SetBuildInfo(Version, GitCommit)
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	flags := overrides{readStdin: *readStdin, pidFile: *pidFile}
+	cfg, err := loadConfig(*configPath, flags)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	if err := logging.Setup(cfg.Agent.LogLevel, cfg.Agent.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The banner would only get in the way of JSON log collection
+	if !logging.JSON() {
+		printBanner()
+	}
 
 	// Validate only mode
 	if *validate {
@@ -68,20 +88,51 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Check-server mode: connect, send a test entry and report
+	if *checkServer {
+		fmt.Printf("Checking server %s...\n", cfg.Server.URL)
+		snd, err := newCheckSender(cfg)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.Timeout)
+			err = snd.CheckServer(ctx)
+			cancel()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ Server check failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Server accepts logs from this agent")
+		os.Exit(0)
+	}
+
+	// Dry-run mode: report whether the server and collectors are ready
+	if *dryRun {
+		os.Exit(runDryRun(cfg))
+	}
+
+	// Claim the PID file before touching the buffer or checkpoints, which
+	// two agents must not share
+	if cfg.Agent.PIDFile != "" {
+		release, err := pidfile.Acquire(cfg.Agent.PIDFile)
+		if err != nil {
+			log.Error("Error writing PID file", "error", err)
+			os.Exit(1)
+		}
+		defer release()
+	}
+
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Initialize components
-	fmt.Println("🚀 Starting LogChat Agent...")
-	fmt.Printf("   Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
-	fmt.Printf("   Server: %s\n", cfg.Server.URL)
-	fmt.Printf("   Hostname: %s\n", cfg.Agent.Hostname)
+	log.Info("Starting LogChat Agent", "version", Version,
+		"platform", runtime.GOOS+"/"+runtime.GOARCH, "server", cfg.Server.URL, "hostname", cfg.Agent.Hostname)
 
 	// Initialize buffer
 	buf, err := buffer.New(cfg.Buffer)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing buffer: %v\n", err)
+		log.Error("Error initializing buffer", "error", err)
 		os.Exit(1)
 	}
 	defer buf.Close()
@@ -89,38 +140,253 @@ func main() {
 	// Initialize sender
 	snd, err := sender.New(cfg.Server, cfg.Agent, buf)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing sender: %v\n", err)
+		log.Error("Error initializing sender", "error", err)
 		os.Exit(1)
 	}
+	defer snd.Close()
 
 	// Start sender
-	go snd.Start(ctx)
+	senderDone := make(chan struct{})
+	go func() {
+		snd.Start(ctx)
+		close(senderDone)
+	}()
 
-	// Initialize collectors
-	collectors := collector.Initialize(cfg.Collectors, snd)
-	fmt.Printf("   Collectors: %d active\n", len(collectors))
+	// Start collectors. They get their own context so they can be stopped
+	// first on shutdown, before the sender's final flush.
+	collectorCtx, stopCollectors := context.WithCancel(ctx)
+	defer stopCollectors()
 
-	// Start collectors
-	for _, c := range collectors {
-		go c.Start(ctx)
+	collectors := collector.NewManager(snd)
+	collectors.Start(collectorCtx, cfg.Collectors)
+	log.Info("Collectors started", "active", len(collectors.Collectors()))
+
+	// Start metrics endpoint
+	if m := metrics.New(cfg.Metrics, snd, collectors.Collectors); m != nil {
+		go m.Start(ctx)
 	}
 
-	fmt.Println("✓ Agent is running. Press Ctrl+C to stop.")
+	log.Info("Agent is running, press Ctrl+C to stop")
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, or the end of stdin in pipe mode; SIGHUP
+	// reloads the config
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-sigChan
-	fmt.Println("\n🛑 Shutting down gracefully...")
+	inputDone := stdinDone(collectors)
+wait:
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				cfg = reloadConfig(cfg, *configPath, flags, collectors)
+				continue
+			}
+			log.Info("Shutting down gracefully", "signal", sig.String())
+			break wait
+		case <-inputDone:
+			log.Info("Input finished, flushing and shutting down")
+			break wait
+		}
+	}
+
+	// The whole shutdown, collectors and final flush, shares one deadline
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Agent.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Stop collectors first so everything they emit is buffered before the
+	// sender's final flush
+	stopCollectors()
+	collectors.Stop()
+	if !waitFor(shutdownCtx, collectors.Wait) {
+		log.Warn("Collectors did not stop before the shutdown timeout")
+	}
+	if err := collectors.Drain(shutdownCtx); err != nil {
+		log.Warn("Collectors did not drain before the shutdown timeout", "error", err)
+	}
 
-	// Cancel context to stop all goroutines
+	// Stopping the sender flushes every buffer one last time
 	cancel()
+	select {
+	case <-senderDone:
+	case <-shutdownCtx.Done():
+		log.Warn("Shutdown timeout reached with entries unsent",
+			"timeout", cfg.Agent.ShutdownTimeout, "unsent", snd.Stats()["buffer_length"])
+	}
+
+	log.Info("Agent stopped")
+}
+
+// overrides are the command line flags that take precedence over the
+// config file
+type overrides struct {
+	readStdin bool
+	pidFile   string
+}
 
-	// Give components time to cleanup
-	time.Sleep(2 * time.Second)
+// loadConfig loads the config file, applying command line overrides
+func loadConfig(path string, flags overrides) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if flags.pidFile != "" {
+		cfg.Agent.PIDFile = flags.pidFile
+	}
+
+	// Stdin mode enables the stdin collector even without config
+	if flags.readStdin {
+		if cfg.Collectors.Stdin == nil {
+			cfg.Collectors.Stdin = &config.StdinCollectorConfig{}
+		}
+		cfg.Collectors.Stdin.Enabled = true
+	}
+
+	return cfg, nil
+}
 
-	fmt.Println("✓ Agent stopped.")
+// reloadConfig re-reads the config file and applies its collectors
+// section and log settings, the only hot-reloadable parts. It returns the config now in
+// effect, which is the old one if the reload is rejected.
+func reloadConfig(cfg *config.Config, path string, flags overrides, collectors *collector.Manager) *config.Config {
+	log.Info("Reloading configuration")
+
+	next, err := loadConfig(path, flags)
+	if err != nil {
+		log.Error("Reload failed, keeping the current config", "error", err)
+		return cfg
+	}
+
+	// Entries already buffered can't move between buffer types
+	if next.Buffer.Type != cfg.Buffer.Type {
+		log.Error("Reload rejected: buffer type can't change without a restart",
+			"from", cfg.Buffer.Type, "to", next.Buffer.Type)
+		return cfg
+	}
+
+	// Log settings apply right away
+	if err := logging.Setup(next.Agent.LogLevel, next.Agent.LogFormat); err != nil {
+		log.Error("Reload failed, keeping the current config", "error", err)
+		return cfg
+	}
+	agent := cfg.Agent
+	agent.LogLevel, agent.LogFormat = next.Agent.LogLevel, next.Agent.LogFormat
+
+	sections := []struct {
+		name      string
+		old, next any
+	}{
+		{"server", cfg.Server, next.Server},
+		{"agent", agent, next.Agent},
+		{"buffer", cfg.Buffer, next.Buffer},
+		{"metrics", cfg.Metrics, next.Metrics},
+	}
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.next) {
+			log.Warn("Changes take effect after a restart", "section", section.name)
+		}
+	}
+
+	started, stopped := collectors.Reload(next.Collectors)
+	log.Info("Configuration reloaded", "started", started, "stopped", stopped,
+		"active", len(collectors.Collectors()))
+
+	// Everything but the collectors and log settings stays as it was
+	reloaded := *cfg
+	reloaded.Agent = agent
+	reloaded.Collectors = next.Collectors
+	return &reloaded
+}
+
+// newCheckSender creates a sender for checks. Throwaway in-memory buffers
+// and no dedup filter, ledger or dead letters keep the real state untouched.
+func newCheckSender(cfg *config.Config) (*sender.Sender, error) {
+	serverCfg := cfg.Server
+	serverCfg.Classes = nil
+	serverCfg.Dedup = nil
+	serverCfg.Ledger = nil
+	serverCfg.DeadLetterDir = ""
+	serverCfg.AdditionalServers = nil
+	checkBuf, _ := buffer.New(config.BufferConfig{Type: "memory", MaxItems: 10, MaxSize: 1024 * 1024})
+	return sender.New(serverCfg, cfg.Agent, checkBuf)
+}
+
+// runDryRun checks the server's health endpoints and builds every enabled
+// collector to verify its setup: globs match files, ports are free,
+// commands exist. Nothing is collected, sent or checkpointed. It returns
+// the exit code, 1 if anything isn't ready.
+func runDryRun(cfg *config.Config) int {
+	fmt.Println("Dry run: checking the configuration without collecting or sending logs")
+
+	snd, err := newCheckSender(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error initializing sender: %v\n", err)
+		return 1
+	}
+
+	problems := 0
+	fmt.Println("Server:")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.Timeout)
+	for _, h := range snd.CheckHealth(ctx) {
+		if h.OK {
+			fmt.Printf("  ✓ %s: health check passed\n", h.URL)
+		} else {
+			fmt.Printf("  ✗ %s: health check failed\n", h.URL)
+			problems++
+		}
+	}
+	cancel()
+
+	fmt.Println("Collectors:")
+	results := collector.Check(cfg.Collectors, snd)
+	if len(results) == 0 {
+		fmt.Println("  ✗ no collectors enabled")
+		problems++
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  ✗ %s: %v\n", r.Name, r.Err)
+			problems++
+		} else {
+			fmt.Printf("  ✓ %s: %s\n", r.Name, r.Detail)
+		}
+	}
+
+	if problems > 0 {
+		fmt.Printf("✗ %d problem(s) found\n", problems)
+		return 1
+	}
+	fmt.Println("✓ Agent is ready to run")
+	return 0
+}
+
+// stdinDone returns the stdin collector's done channel, or nil when stdin
+// isn't being read
+func stdinDone(collectors *collector.Manager) <-chan struct{} {
+	for _, c := range collectors.Collectors() {
+		if sc, ok := c.(*collector.StdinCollector); ok {
+			return sc.Done()
+		}
+	}
+	return nil
+}
+
+// waitFor runs wait in the background and reports whether it returned
+// before the context ended
+func waitFor(ctx context.Context, wait func()) bool {
+	done := make(chan struct{})
+	go func() {
+		wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
 func printBanner() {
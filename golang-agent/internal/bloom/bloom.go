@@ -0,0 +1,224 @@
+package bloom
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"logchat/agent/internal/logging"
+)
+
+// fileMagic identifies a persisted filter file
+const fileMagic = "LCBF"
+
+// fileVersion is the on-disk format version
+const fileVersion uint32 = 1
+
+// Filter is a rolling bloom filter made of two generations. New keys go
+// into the current generation; once it holds capacity keys it becomes the
+// previous generation and a fresh one takes its place. Lookups check both,
+// so a key is remembered for at least capacity and at most 2*capacity adds.
+type Filter struct {
+	mu sync.Mutex
+
+	path     string
+	capacity int
+	bits     uint64 // bits per generation
+	hashes   uint32
+
+	cur   []uint64
+	prev  []uint64
+	count int  // keys added to the current generation
+	dirty bool // keys added since the last save
+}
+
+// New creates a rolling filter sized for capacity keys per generation at the
+// given false positive rate, loading existing state from path when present.
+// An empty path keeps the filter in memory only.
+func New(path string, capacity int, fpRate float64) (*Filter, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("bloom filter capacity must be positive")
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("bloom filter false positive rate must be between 0 and 1")
+	}
+
+	// Optimal sizing: m = -n ln(p) / ln(2)^2, k = m/n ln(2)
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) &^ 63
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+
+	f := &Filter{
+		path:     path,
+		capacity: capacity,
+		bits:     m,
+		hashes:   k,
+		cur:      make([]uint64, m/64),
+		prev:     make([]uint64, m/64),
+	}
+
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create bloom filter directory: %w", err)
+		}
+		if err := f.load(); err != nil && !os.IsNotExist(err) {
+			// A filter saved with different sizing (or a damaged file) is
+			// not usable; start empty rather than refusing to run.
+			logging.For("bloom").Warn("Discarding filter state", "path", path, "error", err)
+		}
+	}
+
+	return f, nil
+}
+
+// Add records key in the filter
+func (f *Filter) Add(key []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.count >= f.capacity {
+		f.prev, f.cur = f.cur, f.prev
+		for i := range f.cur {
+			f.cur[i] = 0
+		}
+		f.count = 0
+	}
+
+	h1, h2 := hashKey(key)
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		f.cur[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+	f.dirty = true
+}
+
+// Test reports whether key may have been added. False positives are
+// possible; false negatives are not (within the rolling window).
+func (f *Filter) Test(key []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h1, h2 := hashKey(key)
+	return f.contains(f.cur, h1, h2) || f.contains(f.prev, h1, h2)
+}
+
+func (f *Filter) contains(set []uint64, h1, h2 uint64) bool {
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		if set[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Save atomically writes the filter state to disk, unless nothing was
+// added since the last save
+func (f *Filter) Save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+
+	tmp := f.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	w.WriteString(fileMagic)
+	binary.Write(w, binary.LittleEndian, fileVersion)
+	binary.Write(w, binary.LittleEndian, f.bits)
+	binary.Write(w, binary.LittleEndian, f.hashes)
+	binary.Write(w, binary.LittleEndian, uint64(f.count))
+	binary.Write(w, binary.LittleEndian, f.cur)
+	binary.Write(w, binary.LittleEndian, f.prev)
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+// load reads filter state from disk
+func (f *Filter) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != fileMagic {
+		return errors.New("not a bloom filter file")
+	}
+
+	var version, hashes uint32
+	var bits, count uint64
+	binary.Read(r, binary.LittleEndian, &version)
+	binary.Read(r, binary.LittleEndian, &bits)
+	binary.Read(r, binary.LittleEndian, &hashes)
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+
+	if version != fileVersion {
+		return fmt.Errorf("unsupported version %d", version)
+	}
+	if bits != f.bits || hashes != f.hashes {
+		return errors.New("filter sizing changed")
+	}
+
+	cur := make([]uint64, len(f.cur))
+	prev := make([]uint64, len(f.prev))
+	if err := binary.Read(r, binary.LittleEndian, cur); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, prev); err != nil {
+		return err
+	}
+
+	f.cur, f.prev, f.count = cur, prev, int(count)
+	return nil
+}
+
+// hashKey derives the two base hashes used for double hashing
+func hashKey(key []byte) (uint64, uint64) {
+	sum := sha256.Sum256(key)
+	h1 := binary.LittleEndian.Uint64(sum[0:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:16]) | 1
+	return h1, h2
+}
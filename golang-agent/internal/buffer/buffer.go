@@ -1,16 +1,22 @@
 package buffer
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
 )
 
+// log is the logger shared by the buffer implementations
+var log = logging.For("buffer")
+
 // LogEntry represents a log entry in the buffer
 type LogEntry struct {
 	Timestamp   time.Time         `json:"timestamp"`
@@ -22,6 +28,38 @@ type LogEntry struct {
 	Environment string            `json:"environment"`
 	Tags        map[string]string `json:"tags,omitempty"`
 	Metadata    map[string]any    `json:"metadata,omitempty"`
+
+	// Class selects the sender's delivery class; it is not shipped
+	Class string `json:"-"`
+}
+
+// positionKeys are metadata fields that locate an entry in its source: a
+// file offset, a journal cursor or an event log record number
+var positionKeys = []string{"file_path", "file_offset", "journal_cursor", "record_number"}
+
+// Fingerprint returns a hash identifying the entry across restarts. The
+// entry's position in its source is included when the collector recorded
+// one; without it the hash covers content only, so identical lines with the
+// same timestamp share a fingerprint.
+func (e LogEntry) Fingerprint() []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%s\x00%s",
+		e.Timestamp.UnixNano(), e.Source, e.Service, e.Hostname, e.Level, e.Message)
+	for _, key := range positionKeys {
+		if v, ok := e.Metadata[key]; ok {
+			fmt.Fprintf(h, "\x00%s=%s", key, positionValue(v))
+		}
+	}
+	return h.Sum(nil)
+}
+
+// positionValue formats a position the same before and after a JSON round
+// trip, which turns integers into float64
+func positionValue(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
 }
 
 // Buffer interface for log buffering
@@ -36,21 +74,15 @@ type Buffer interface {
 
 // MemoryBuffer implements in-memory buffering
 type MemoryBuffer struct {
-	mu       sync.RWMutex
-	entries  []LogEntry
-	maxItems int
-	maxSize  int64
-	curSize  int64
-}
-
-// FileBuffer implements file-based buffering for persistence
-type FileBuffer struct {
-	mu       sync.RWMutex
-	path     string
-	maxItems int
-	maxSize  int64
-	file     *os.File
-	entries  []LogEntry
+	mu      sync.RWMutex
+	entries *ring // Fixed at max_items, sizes measured once on push
+	maxSize int64
+	curSize int64
+
+	// Optional periodic snapshot to disk
+	snapshotPath string
+	stop         chan struct{}
+	done         chan struct{}
 }
 
 // New creates a new buffer based on configuration
@@ -58,7 +90,12 @@ func New(cfg config.BufferConfig) (Buffer, error) {
 	switch cfg.Type {
 	case "file":
 		return newFileBuffer(cfg)
+	case "sqlite":
+		return newSQLiteBuffer(cfg)
 	case "memory", "":
+		if cfg.PersistInterval > 0 {
+			return newPersistentMemoryBuffer(cfg)
+		}
 		return newMemoryBuffer(cfg), nil
 	default:
 		return nil, fmt.Errorf("unknown buffer type: %s", cfg.Type)
@@ -68,241 +105,179 @@ func New(cfg config.BufferConfig) (Buffer, error) {
 // newMemoryBuffer creates a new memory buffer
 func newMemoryBuffer(cfg config.BufferConfig) *MemoryBuffer {
 	return &MemoryBuffer{
-		entries:  make([]LogEntry, 0, cfg.MaxItems),
-		maxItems: cfg.MaxItems,
-		maxSize:  cfg.MaxSize,
-	}
-}
-
-// Push adds an entry to the memory buffer
-func (b *MemoryBuffer) Push(entry LogEntry) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	// Calculate entry size
-	data, _ := json.Marshal(entry)
-	entrySize := int64(len(data))
-
-	// Check if we need to evict old entries
-	for b.curSize+entrySize > b.maxSize && len(b.entries) > 0 {
-		oldData, _ := json.Marshal(b.entries[0])
-		b.curSize -= int64(len(oldData))
-		b.entries = b.entries[1:]
-	}
-
-	// Check max items
-	for len(b.entries) >= b.maxItems {
-		oldData, _ := json.Marshal(b.entries[0])
-		b.curSize -= int64(len(oldData))
-		b.entries = b.entries[1:]
-	}
-
-	b.entries = append(b.entries, entry)
-	b.curSize += entrySize
-
-	return nil
-}
-
-// Pop removes and returns entries from the buffer
-func (b *MemoryBuffer) Pop(count int) ([]LogEntry, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	entries := make([]LogEntry, count)
-	copy(entries, b.entries[:count])
-	b.entries = b.entries[count:]
-
-	// Update size
-	for _, entry := range entries {
-		data, _ := json.Marshal(entry)
-		b.curSize -= int64(len(data))
-	}
-
-	return entries, nil
-}
-
-// Peek returns entries without removing them
-func (b *MemoryBuffer) Peek(count int) ([]LogEntry, error) {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	entries := make([]LogEntry, count)
-	copy(entries, b.entries[:count])
-
-	return entries, nil
-}
-
-// Remove removes entries from the buffer
-func (b *MemoryBuffer) Remove(count int) error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	for i := 0; i < count; i++ {
-		data, _ := json.Marshal(b.entries[i])
-		b.curSize -= int64(len(data))
+		entries: newRing(cfg.MaxItems),
+		maxSize: cfg.MaxSize,
 	}
-
-	b.entries = b.entries[count:]
-	return nil
-}
-
-// Len returns the number of entries in the buffer
-func (b *MemoryBuffer) Len() int {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	return len(b.entries)
-}
-
-// Close closes the memory buffer
-func (b *MemoryBuffer) Close() error {
-	return nil
 }
 
-// newFileBuffer creates a new file-based buffer
-func newFileBuffer(cfg config.BufferConfig) (*FileBuffer, error) {
+// newPersistentMemoryBuffer creates a memory buffer that snapshots to disk
+func newPersistentMemoryBuffer(cfg config.BufferConfig) (*MemoryBuffer, error) {
 	if cfg.Path == "" {
 		cfg.Path = filepath.Join(os.TempDir(), "logchat-buffer")
 	}
 
-	// Ensure directory exists
 	if err := os.MkdirAll(cfg.Path, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
 	}
 
-	bufferFile := filepath.Join(cfg.Path, "buffer.json")
-
-	buf := &FileBuffer{
-		path:     bufferFile,
-		maxItems: cfg.MaxItems,
-		maxSize:  cfg.MaxSize,
-		entries:  make([]LogEntry, 0),
+	b := newMemoryBuffer(cfg)
+	b.snapshotPath = filepath.Join(cfg.Path, "snapshot.json")
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+
+	// Reload the last snapshot
+	data, err := os.ReadFile(b.snapshotPath)
+	if err == nil {
+		var entries []LogEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to load buffer snapshot: %w", err)
+		}
+		for _, entry := range entries {
+			b.Push(entry)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load buffer snapshot: %w", err)
 	}
 
-	// Load existing buffer if it exists
-	if err := buf.load(); err != nil {
-		// It's okay if the file doesn't exist
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("failed to load existing buffer: %w", err)
+	go b.persistLoop(cfg.PersistInterval)
+
+	return b, nil
+}
+
+// persistLoop snapshots the buffer every interval until Close
+func (b *MemoryBuffer) persistLoop(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			if err := b.snapshot(); err != nil {
+				log.Error("Error writing snapshot", "error", err)
+			}
 		}
 	}
-
-	return buf, nil
 }
 
-// load loads entries from the file
-func (b *FileBuffer) load() error {
-	data, err := os.ReadFile(b.path)
+// snapshot atomically writes the buffer contents to the snapshot file
+func (b *MemoryBuffer) snapshot() error {
+	b.mu.RLock()
+	data, err := json.Marshal(b.entries.copyOldest(b.entries.len()))
+	b.mu.RUnlock()
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &b.entries)
+	return writeFileAtomic(b.snapshotPath, data)
 }
 
-// save saves entries to the file
-func (b *FileBuffer) save() error {
-	data, err := json.Marshal(b.entries)
+// writeFileAtomic writes data to a temp file and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(b.path, data, 0644)
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
 }
 
-// Push adds an entry to the file buffer
-func (b *FileBuffer) Push(entry LogEntry) error {
+// Push adds an entry to the memory buffer
+func (b *MemoryBuffer) Push(entry LogEntry) error {
+	// Calculate entry size
+	data, _ := json.Marshal(entry)
+	entrySize := int64(len(data))
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Evict old entries if needed
-	for len(b.entries) >= b.maxItems {
-		b.entries = b.entries[1:]
+	// Check if we need to evict old entries, by size and then by count
+	evict := 0
+	var freed int64
+	for b.curSize-freed+entrySize > b.maxSize && evict < b.entries.len() {
+		freed += b.entries.size(evict)
+		evict++
 	}
+	if evict == 0 && b.entries.full() {
+		evict = 1
+	}
+	b.curSize -= b.entries.dropOldest(evict)
 
-	b.entries = append(b.entries, entry)
+	b.entries.push(entry, entrySize)
+	b.curSize += entrySize
 
-	return b.save()
+	return nil
 }
 
-// Pop removes and returns entries from the file buffer
-func (b *FileBuffer) Pop(count int) ([]LogEntry, error) {
+// Pop removes and returns entries from the buffer
+func (b *MemoryBuffer) Pop(count int) ([]LogEntry, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	entries := make([]LogEntry, count)
-	copy(entries, b.entries[:count])
-	b.entries = b.entries[count:]
-
-	if err := b.save(); err != nil {
-		return entries, err
-	}
+	entries := b.entries.copyOldest(count)
+	b.curSize -= b.entries.dropOldest(count)
 
 	return entries, nil
 }
 
 // Peek returns entries without removing them
-func (b *FileBuffer) Peek(count int) ([]LogEntry, error) {
+func (b *MemoryBuffer) Peek(count int) ([]LogEntry, error) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	entries := make([]LogEntry, count)
-	copy(entries, b.entries[:count])
-
-	return entries, nil
+	return b.entries.copyOldest(count), nil
 }
 
-// Remove removes entries from the file buffer
-func (b *FileBuffer) Remove(count int) error {
+// Remove removes entries from the buffer
+func (b *MemoryBuffer) Remove(count int) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if count > len(b.entries) {
-		count = len(b.entries)
-	}
-
-	b.entries = b.entries[count:]
-	return b.save()
+	b.curSize -= b.entries.dropOldest(count)
+	return nil
 }
 
 // Len returns the number of entries in the buffer
-func (b *FileBuffer) Len() int {
+func (b *MemoryBuffer) Len() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return len(b.entries)
+	return b.entries.len()
 }
 
-// Close closes the file buffer
-func (b *FileBuffer) Close() error {
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// Size returns the serialized size of the buffered entries in bytes
+func (b *MemoryBuffer) Size() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.curSize
+}
 
-	if err := b.save(); err != nil {
-		return err
+// Close closes the memory buffer, taking a final snapshot when persistence is enabled
+func (b *MemoryBuffer) Close() error {
+	if b.snapshotPath == "" {
+		return nil
 	}
 
-	if b.file != nil {
-		return b.file.Close()
-	}
+	close(b.stop)
+	<-b.done
 
-	return nil
+	return b.snapshot()
 }
@@ -0,0 +1,237 @@
+package buffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/config"
+)
+
+// testEntry creates an entry whose message is "entry <i>"
+func testEntry(i int) LogEntry {
+	return LogEntry{
+		Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Level:     "INFO",
+		Message:   fmt.Sprintf("entry %03d", i),
+	}
+}
+
+// entrySize returns the serialized size the buffers account for an entry
+func entrySize(t testing.TB, e LogEntry) int64 {
+	t.Helper()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(data))
+}
+
+// sizedBuffer is a buffer that reports its serialized size, as the
+// built-in ones do
+type sizedBuffer interface {
+	Buffer
+	Size() int64
+}
+
+// newBuffers creates one buffer of each built-in type with the same limits
+func newBuffers(t *testing.T, maxItems int, maxSize int64) map[string]sizedBuffer {
+	t.Helper()
+
+	buffers := make(map[string]sizedBuffer)
+	for _, typ := range []string{"memory", "file"} {
+		b, err := New(config.BufferConfig{Type: typ, Path: t.TempDir(), MaxItems: maxItems, MaxSize: maxSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { b.Close() })
+		buffers[typ] = b.(sizedBuffer)
+	}
+	return buffers
+}
+
+// assertHolds checks that b holds exactly entries first..last, oldest first
+func assertHolds(t *testing.T, b Buffer, first, last int) {
+	t.Helper()
+
+	entries, err := b.Peek(b.Len() + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != last-first+1 {
+		t.Fatalf("holds %d entries, want %d (%d..%d)", len(entries), last-first+1, first, last)
+	}
+	for i, e := range entries {
+		if want := testEntry(first + i).Message; e.Message != want {
+			t.Errorf("entry %d = %q, want %q", i, e.Message, want)
+		}
+	}
+}
+
+func TestBufferEvictsOldestByCount(t *testing.T) {
+	for typ, b := range newBuffers(t, 10, 1<<20) {
+		t.Run(typ, func(t *testing.T) {
+			for i := 0; i < 25; i++ {
+				if err := b.Push(testEntry(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertHolds(t, b, 15, 24)
+			if want := 10 * entrySize(t, testEntry(0)); b.Size() != want {
+				t.Errorf("size = %d, want %d", b.Size(), want)
+			}
+		})
+	}
+}
+
+func TestBufferEvictsOldestBySize(t *testing.T) {
+	size := entrySize(t, testEntry(0))
+	for typ, b := range newBuffers(t, 1000, 4*size+size/2) {
+		t.Run(typ, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if err := b.Push(testEntry(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertHolds(t, b, 6, 9)
+			if want := 4 * size; b.Size() != want {
+				t.Errorf("size = %d, want %d", b.Size(), want)
+			}
+
+			// A larger entry pushes out as many as it needs
+			big := testEntry(10)
+			big.Message += strings.Repeat("x", int(2*size))
+			if err := b.Push(big); err != nil {
+				t.Fatal(err)
+			}
+			if b.Len() != 2 || b.Size() > 4*size+size/2 {
+				t.Errorf("after a large push: %d entries, %d bytes", b.Len(), b.Size())
+			}
+		})
+	}
+}
+
+func TestFileBufferEvictionSurvivesReopen(t *testing.T) {
+	size := entrySize(t, testEntry(0))
+	cfg := config.BufferConfig{Type: "file", Path: t.TempDir(), MaxItems: 5, MaxSize: 3*size + size/2}
+
+	b, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := b.Push(testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := newFileBuffer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	assertHolds(t, reopened, 7, 9)
+	if want := 3 * size; reopened.Size() != want {
+		t.Errorf("size after reopen = %d, want %d", reopened.Size(), want)
+	}
+}
+
+// BenchmarkMemoryBufferSizeAccounting pushes into a buffer held at its
+// size limit, so every push evicts by size, and drains batches the way
+// the sender does
+func BenchmarkMemoryBufferSizeAccounting(b *testing.B) {
+	e := testEntry(0)
+	e.Tags = map[string]string{"service": "api", "env": "prod"}
+	e.Metadata = map[string]any{"status": 200, "path": "/v1/items", "duration_ms": 12.5}
+	size := entrySize(b, e)
+
+	buf, err := New(config.BufferConfig{Type: "memory", MaxItems: 100000, MaxSize: 1000 * size})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		buf.Push(e)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Push(e)
+		if i%100 == 99 {
+			buf.Peek(50)
+			buf.Remove(50)
+		}
+	}
+}
+
+// BenchmarkMemoryBufferSustainedLoad keeps a buffer full by count, so each
+// push evicts the oldest entry, while batches are popped off the head
+func BenchmarkMemoryBufferSustainedLoad(b *testing.B) {
+	const maxItems = 10000
+	buf, err := New(config.BufferConfig{Type: "memory", MaxItems: maxItems, MaxSize: 1 << 40})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < maxItems; i++ {
+		buf.Push(testEntry(i))
+	}
+
+	e := testEntry(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Push(e)
+		if i%200 == 199 {
+			buf.Pop(100)
+		}
+	}
+}
+
+func TestFingerprintUsesSourcePosition(t *testing.T) {
+	at := func(offset int64) LogEntry {
+		e := testEntry(0)
+		e.Metadata = map[string]any{"file_path": "/var/log/app.log", "file_offset": offset}
+		return e
+	}
+	fp := func(e LogEntry) string { return string(e.Fingerprint()) }
+
+	// The same line twice in one second is two entries when offsets differ
+	if fp(at(100)) == fp(at(200)) {
+		t.Error("identical lines at different offsets share a fingerprint")
+	}
+	// Content-only fingerprints collapse them, as documented
+	if fp(testEntry(0)) != fp(testEntry(0)) {
+		t.Error("content-only fingerprint isn't stable")
+	}
+	for _, e := range []LogEntry{
+		{Message: "x", Metadata: map[string]any{"journal_cursor": "s=1;i=2"}},
+		{Message: "x", Metadata: map[string]any{"record_number": uint64(1)}},
+	} {
+		if fp(e) == fp(LogEntry{Message: "x"}) {
+			t.Errorf("position %v not fingerprinted", e.Metadata)
+		}
+	}
+
+	// A JSON round trip, as through the file buffer, turns offsets into
+	// float64 without changing the fingerprint
+	for _, offset := range []int64{100, 123456789012} {
+		e := at(offset)
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back LogEntry
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if fp(back) != fp(e) {
+			t.Errorf("offset %d: fingerprint changed after a JSON round trip", offset)
+		}
+	}
+}
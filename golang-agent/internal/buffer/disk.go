@@ -0,0 +1,97 @@
+package buffer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// prunableSuffixes mark files under the state directory that are safe to
+// delete to free space: rotated files and backups
+var prunableSuffixes = []string{".1", ".bak", ".corrupt"}
+
+// DeadLetterPattern matches the names DeadLetterName gives dead letters, as
+// a filepath.Match pattern
+const DeadLetterPattern = "dead-*.json"
+
+// tmpGracePeriod is how long a ".tmp" file must go unmodified before it is
+// pruned. Snapshots, checkpoints and the dedup filter write one and then
+// rename it, so a recent one is still being written.
+const tmpGracePeriod = 10 * time.Minute
+
+// DeadLetterName names the seq-th dead letter written at t. The disk budget
+// recognizes dead letters by this name to prune them.
+func DeadLetterName(t time.Time, seq int64) string {
+	return fmt.Sprintf("dead-%s-%d.json", t.UTC().Format("20060102T150405"), seq)
+}
+
+// diskFile is a file under the state directory
+type diskFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// diskUsage totals every file under dir except skip, and returns the
+// prunable ones oldest first
+func diskUsage(dir, skip string) (int64, []diskFile) {
+	var total int64
+	var prunable []diskFile
+
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == skip {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total += info.Size()
+		if isPrunable(d.Name(), info.ModTime()) {
+			prunable = append(prunable, diskFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+
+	sort.Slice(prunable, func(i, j int) bool {
+		return prunable[i].modTime.Before(prunable[j].modTime)
+	})
+	return total, prunable
+}
+
+// isPrunable reports whether a file is a backup, a dead letter or a stale
+// temp file. The ledger is kept whole since dropping part of it breaks the
+// chain.
+func isPrunable(name string, modTime time.Time) bool {
+	if strings.HasPrefix(name, "ledger") {
+		return false
+	}
+	for _, suffix := range prunableSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	if ok, _ := filepath.Match(DeadLetterPattern, name); ok {
+		return true
+	}
+	return strings.HasSuffix(name, ".tmp") && time.Since(modTime) > tmpGracePeriod
+}
+
+// pruneFiles deletes prunable files, oldest first, until need bytes are
+// freed. It returns the bytes freed.
+func pruneFiles(files []diskFile, need int64) int64 {
+	var freed int64
+	for _, f := range files {
+		if freed >= need {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			freed += f.size
+		}
+	}
+	return freed
+}
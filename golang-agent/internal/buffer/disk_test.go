@@ -0,0 +1,129 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/config"
+)
+
+func TestIsPrunable(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	for _, tc := range []struct {
+		name    string
+		modTime time.Time
+		want    bool
+	}{
+		{DeadLetterName(time.Now(), 7), old, true},
+		{"buffer.json.bak", old, true},
+		{"app.log.1", old, true},
+		{"snapshot.json.corrupt", old, true},
+		{"snapshot.json.tmp", old, true},
+		{"delivered.bloom.tmp", time.Now(), false}, // Still being written
+		{"ledger.jsonl", old, false},
+		{"ledger.jsonl.bak", old, false},
+		{"buffer-3.log", old, false},
+		{"buffer.offset", old, false},
+		{"dead-letter-notes.txt", old, false},
+	} {
+		if got := isPrunable(tc.name, tc.modTime); got != tc.want {
+			t.Errorf("isPrunable(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFitDiskBudgetPrunesOldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	payload := strings.Repeat("x", 1000)
+
+	// Prunable oldest first, then the files that must survive
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"buffer.json.bak", 4 * time.Hour},
+		{DeadLetterName(now.Add(-3*time.Hour), 1), 3 * time.Hour},
+		{filepath.Join("output-0", DeadLetterName(now.Add(-150*time.Minute), 1)), 150 * time.Minute},
+		{"snapshot.json.tmp", 2 * time.Hour},
+		{DeadLetterName(now.Add(-time.Hour), 2), time.Hour},
+		{DeadLetterName(now.Add(-30*time.Minute), 3), 30 * time.Minute},
+		{"delivered.bloom.tmp", 0},
+		{"ledger.jsonl", 5 * time.Hour},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(payload), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-f.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	b, err := newFileBuffer(config.BufferConfig{Type: "file", Path: dir, MaxItems: 1000, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	// Freeing 3.5KB plus the entry takes the four oldest prunable files
+	total, _ := diskUsage(dir, "")
+	b.maxDiskBytes = total - 3500
+	if err := b.Push(testEntry(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, f := range files {
+		if want := i >= 4; exists(f.name) != want {
+			t.Errorf("%s exists = %v, want %v", f.name, !want, want)
+		}
+	}
+	if b.dropped != 0 || b.Len() != 1 {
+		t.Errorf("dropped %d entries, holding %d; pruning should have sufficed", b.dropped, b.Len())
+	}
+	if total, _ := diskUsage(dir, ""); total > b.maxDiskBytes {
+		t.Errorf("disk usage %d over the %d budget", total, b.maxDiskBytes)
+	}
+
+	// Past every prunable file, the oldest entries go, but never the ledger
+	// or a temp file being written
+	b.maxDiskBytes = 0
+	for i := 1; i < 50; i++ {
+		if err := b.Push(testEntry(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	total, _ = diskUsage(dir, "")
+	b.maxDiskBytes = total - 2500
+	b.otherChecked = time.Time{}
+	if err := b.Push(testEntry(50)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, f := range files {
+		if want := i >= 6; exists(f.name) != want {
+			t.Errorf("%s exists = %v, want %v", f.name, !want, want)
+		}
+	}
+	if b.dropped == 0 {
+		t.Error("no entries dropped once only protected files were left")
+	}
+	if entries, _ := b.Peek(b.Len()); len(entries) == 0 || entries[len(entries)-1].Message != testEntry(50).Message {
+		t.Error("newest entry not kept")
+	}
+	if total, _ := diskUsage(dir, ""); total > b.maxDiskBytes {
+		t.Errorf("disk usage %d over the %d budget", total, b.maxDiskBytes)
+	}
+}
@@ -0,0 +1,462 @@
+package buffer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/config"
+)
+
+// defaultCompactThreshold is how many bytes of consumed entries the log may
+// carry before it is rewritten
+const defaultCompactThreshold = 8 * 1024 * 1024
+
+// FileBuffer implements file-based buffering for persistence. Entries are
+// appended to a newline-delimited JSON log, so a push is a single write.
+// Removing entries only advances the committed offset recorded next to the
+// log; the consumed head is reclaimed by compaction, which copies the live
+// tail into a new log generation.
+type FileBuffer struct {
+	mu       sync.RWMutex
+	dir      string
+	maxItems int
+	maxSize  int64
+	curSize  int64 // Serialized size of the buffered entries
+	entries  []LogEntry
+	sizes    []int64 // Bytes each entry occupies in the log, newline included
+
+	log     *os.File // Append handle on the current log generation
+	gen     int64
+	offset  int64 // Committed offset: where the first buffered entry starts
+	logSize int64 // Bytes in the log, consumed entries included
+
+	compactThreshold int64
+
+	// Total disk budget for the state directory, 0 = unlimited
+	maxDiskBytes int64
+	dropped      int64     // Entries dropped to stay within the budget
+	otherBytes   int64     // Size of other files in the directory, refreshed periodically
+	otherChecked time.Time // When otherBytes was last measured
+}
+
+// logState is the committed position, written atomically after removals
+type logState struct {
+	Gen    int64 `json:"gen"`
+	Offset int64 `json:"offset"`
+}
+
+// newFileBuffer creates a new file-based buffer
+func newFileBuffer(cfg config.BufferConfig) (*FileBuffer, error) {
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(os.TempDir(), "logchat-buffer")
+	}
+
+	// Ensure directory exists
+	if err := os.MkdirAll(cfg.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+
+	buf := &FileBuffer{
+		dir:      cfg.Path,
+		maxItems: cfg.MaxItems,
+		maxSize:  cfg.MaxSize,
+
+		compactThreshold: cfg.CompactThreshold,
+		maxDiskBytes:     cfg.MaxDiskBytes,
+	}
+	if buf.compactThreshold <= 0 {
+		buf.compactThreshold = defaultCompactThreshold
+	}
+
+	if err := buf.load(); err != nil {
+		return nil, fmt.Errorf("failed to load existing buffer: %w", err)
+	}
+
+	if err := buf.migrate(); err != nil {
+		return nil, fmt.Errorf("failed to migrate buffer.json: %w", err)
+	}
+
+	return buf, nil
+}
+
+// logPath returns the path of a log generation
+func (b *FileBuffer) logPath(gen int64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("buffer-%d.log", gen))
+}
+
+// statePath returns the path of the committed offset file
+func (b *FileBuffer) statePath() string {
+	return filepath.Join(b.dir, "buffer.offset")
+}
+
+// load replays the log from the committed offset. A torn last line from a
+// crash mid-append is cut off so later appends start on a clean line.
+func (b *FileBuffer) load() error {
+	if data, err := os.ReadFile(b.statePath()); err == nil {
+		var state logState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("invalid %s: %w", b.statePath(), err)
+		}
+		b.gen, b.offset = state.Gen, state.Offset
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	b.removeStaleLogs()
+
+	f, err := os.OpenFile(b.logPath(b.gen), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if b.offset > info.Size() {
+		log.Warn("Committed offset is past the end of the log, starting empty", "offset", b.offset)
+		b.offset = info.Size()
+	}
+
+	if _, err := f.Seek(b.offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	end := b.offset
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A partial line without its newline was never fully written
+			break
+		}
+
+		var entry LogEntry
+		if json.Unmarshal(line, &entry) != nil {
+			log.Warn("Corrupt entry, discarding the rest of the log", "offset", end)
+			break
+		}
+
+		b.entries = append(b.entries, entry)
+		b.sizes = append(b.sizes, int64(len(line)))
+		b.curSize += int64(len(line) - 1)
+		end += int64(len(line))
+	}
+
+	if end < info.Size() {
+		if err := f.Truncate(end); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	b.log = f
+	b.logSize = end
+	return nil
+}
+
+// removeStaleLogs deletes log generations other than the committed one,
+// left behind by a crash during compaction
+func (b *FileBuffer) removeStaleLogs() {
+	current := filepath.Base(b.logPath(b.gen))
+	matches, _ := filepath.Glob(filepath.Join(b.dir, "buffer-*.log"))
+	for _, path := range matches {
+		if filepath.Base(path) != current {
+			os.Remove(path)
+		}
+	}
+}
+
+// migrate imports a buffer.json written by the old whole-file format
+func (b *FileBuffer) migrate() error {
+	legacy := filepath.Join(b.dir, "buffer.json")
+	data, err := os.ReadFile(legacy)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries []LogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := b.Push(entry); err != nil {
+			return err
+		}
+	}
+	if err := b.log.Sync(); err != nil {
+		return err
+	}
+
+	log.Info("Migrated entries", "count", len(entries), "from", legacy)
+	return os.Remove(legacy)
+}
+
+// saveState records the committed offset
+func (b *FileBuffer) saveState() error {
+	data, err := json.Marshal(logState{Gen: b.gen, Offset: b.offset})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.statePath(), data)
+}
+
+// dropOldest removes the first count entries and advances the committed
+// offset past them. The caller persists the offset.
+func (b *FileBuffer) dropOldest(count int) {
+	for i := 0; i < count; i++ {
+		b.offset += b.sizes[i]
+		b.curSize -= b.sizes[i] - 1
+	}
+	b.entries = b.entries[count:]
+	b.sizes = b.sizes[count:]
+}
+
+// commit persists the offset after removals, compacting when the consumed
+// head of the log has grown large or nothing is left to keep
+func (b *FileBuffer) commit() error {
+	if b.offset > 0 && (len(b.entries) == 0 || b.offset >= b.compactThreshold) {
+		return b.compact()
+	}
+	return b.saveState()
+}
+
+// compact copies the buffered tail of the log into a new generation and
+// switches to it. The old log stays authoritative until the new offset file
+// is in place, so a crash at any point loses nothing.
+func (b *FileBuffer) compact() error {
+	next := b.gen + 1
+	dst, err := os.OpenFile(b.logPath(next), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	src := io.NewSectionReader(b.log, b.offset, b.logSize-b.offset)
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+
+	prevGen, prevOffset := b.gen, b.offset
+	b.gen, b.offset = next, 0
+	if err := b.saveState(); err != nil {
+		b.gen, b.offset = prevGen, prevOffset
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+
+	b.log.Close()
+	os.Remove(b.logPath(prevGen))
+
+	b.log = dst
+	b.logSize -= prevOffset
+	return nil
+}
+
+// fitDiskBudget keeps everything under the buffer directory within
+// max_disk_bytes before incoming bytes are appended: it reclaims the
+// consumed head of the log, then prunes backups and dead letters, oldest
+// first, then drops the oldest buffered entries.
+func (b *FileBuffer) fitDiskBudget(incoming int64) error {
+	logPath := b.logPath(b.gen)
+
+	// Walking the directory on every push is too costly; refresh periodically
+	if time.Since(b.otherChecked) > 10*time.Second {
+		b.otherBytes, _ = diskUsage(b.dir, logPath)
+		b.otherChecked = time.Now()
+	}
+
+	over := b.otherBytes + b.logSize + incoming - b.maxDiskBytes
+	if over <= 0 {
+		return nil
+	}
+
+	if b.offset > 0 {
+		if err := b.compact(); err != nil {
+			return err
+		}
+		logPath = b.logPath(b.gen)
+	}
+
+	// Re-measure before acting, then free prunable files first
+	var prunable []diskFile
+	b.otherBytes, prunable = diskUsage(b.dir, logPath)
+	b.otherChecked = time.Now()
+	over = b.otherBytes + b.logSize + incoming - b.maxDiskBytes
+	if over > 0 {
+		freed := pruneFiles(prunable, over)
+		b.otherBytes -= freed
+		over -= freed
+	}
+
+	// Drop the oldest entries until the buffer fits
+	dropped := 0
+	for over > 0 && len(b.entries) > 0 {
+		drop := max(len(b.entries)/10, 1)
+		b.dropOldest(drop)
+		b.dropped += int64(drop)
+		dropped += drop
+
+		if err := b.compact(); err != nil {
+			return err
+		}
+		over = b.otherBytes + b.logSize + incoming - b.maxDiskBytes
+	}
+
+	if dropped > 0 {
+		log.Warn("Disk budget reached, dropped oldest entries",
+			"max_disk_bytes", b.maxDiskBytes, "dropped", dropped, "total_dropped", b.dropped)
+	}
+	return nil
+}
+
+// Push appends an entry to the log
+func (b *FileBuffer) Push(entry LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Evict old entries by size, then by count, as the memory buffer does
+	evict := 0
+	var freed int64
+	for b.curSize-freed+size > b.maxSize && evict < len(b.entries) {
+		freed += b.sizes[evict] - 1
+		evict++
+	}
+	for len(b.entries)-evict >= b.maxItems && evict < len(b.entries) {
+		evict++
+	}
+	if evict > 0 {
+		b.dropOldest(evict)
+		if err := b.commit(); err != nil {
+			return err
+		}
+	}
+
+	if b.maxDiskBytes > 0 {
+		if err := b.fitDiskBudget(size + 1); err != nil {
+			return err
+		}
+	}
+
+	n, err := b.log.Write(append(data, '\n'))
+	if err != nil {
+		// Cut a partial write so the next append starts on a clean line
+		if n > 0 {
+			b.log.Truncate(b.logSize)
+			b.log.Seek(b.logSize, io.SeekStart)
+		}
+		return err
+	}
+
+	b.entries = append(b.entries, entry)
+	b.sizes = append(b.sizes, size+1)
+	b.curSize += size
+	b.logSize += size + 1
+	return nil
+}
+
+// Pop removes and returns entries from the file buffer
+func (b *FileBuffer) Pop(count int) ([]LogEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if count > len(b.entries) {
+		count = len(b.entries)
+	}
+
+	entries := make([]LogEntry, count)
+	copy(entries, b.entries[:count])
+	b.dropOldest(count)
+
+	if err := b.commit(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// Peek returns entries without removing them
+func (b *FileBuffer) Peek(count int) ([]LogEntry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if count > len(b.entries) {
+		count = len(b.entries)
+	}
+
+	entries := make([]LogEntry, count)
+	copy(entries, b.entries[:count])
+
+	return entries, nil
+}
+
+// Remove removes entries from the file buffer
+func (b *FileBuffer) Remove(count int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if count > len(b.entries) {
+		count = len(b.entries)
+	}
+
+	b.dropOldest(count)
+	return b.commit()
+}
+
+// Len returns the number of entries in the buffer
+func (b *FileBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.entries)
+}
+
+// Size returns the serialized size of the buffered entries in bytes
+func (b *FileBuffer) Size() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.curSize
+}
+
+// Close flushes the log and records the committed offset
+func (b *FileBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.saveState(); err != nil {
+		return err
+	}
+
+	if err := b.log.Sync(); err != nil {
+		b.log.Close()
+		return err
+	}
+	return b.log.Close()
+}
@@ -0,0 +1,78 @@
+package buffer
+
+// ring is a fixed-capacity FIFO of entries and their serialized sizes.
+// Pushing and dropping are O(1) and never grow or re-slice the backing
+// arrays; dropped slots are zeroed so their entries can be collected.
+type ring struct {
+	entries []LogEntry
+	sizes   []int64
+	head    int // Index of the oldest entry
+	n       int // Number of entries held
+}
+
+// newRing creates a ring holding up to capacity entries, at least one
+func newRing(capacity int) *ring {
+	capacity = max(capacity, 1)
+	return &ring{
+		entries: make([]LogEntry, capacity),
+		sizes:   make([]int64, capacity),
+	}
+}
+
+// len returns the number of entries held
+func (r *ring) len() int {
+	return r.n
+}
+
+// full reports whether another push needs a drop first
+func (r *ring) full() bool {
+	return r.n == len(r.entries)
+}
+
+// slot maps the i-th oldest entry to its index in the backing arrays
+func (r *ring) slot(i int) int {
+	return (r.head + i) % len(r.entries)
+}
+
+// size returns the serialized size of the i-th oldest entry
+func (r *ring) size(i int) int64 {
+	return r.sizes[r.slot(i)]
+}
+
+// push appends an entry after the newest one. The ring must not be full.
+func (r *ring) push(entry LogEntry, size int64) {
+	i := r.slot(r.n)
+	r.entries[i] = entry
+	r.sizes[i] = size
+	r.n++
+}
+
+// copyOldest copies up to count of the oldest entries into a new slice
+func (r *ring) copyOldest(count int) []LogEntry {
+	count = min(count, r.n)
+	out := make([]LogEntry, count)
+
+	// The entries wrap around the end of the backing array at most once
+	first := copy(out, r.entries[r.head:min(r.head+count, len(r.entries))])
+	copy(out[first:], r.entries[:count-first])
+	return out
+}
+
+// dropOldest removes up to count of the oldest entries and returns the
+// total size freed
+func (r *ring) dropOldest(count int) int64 {
+	count = min(count, r.n)
+
+	var freed int64
+	for ; count > 0; count-- {
+		freed += r.sizes[r.head]
+		r.entries[r.head] = LogEntry{}
+		r.sizes[r.head] = 0
+		r.head = (r.head + 1) % len(r.entries)
+		r.n--
+	}
+	if r.n == 0 {
+		r.head = 0
+	}
+	return freed
+}
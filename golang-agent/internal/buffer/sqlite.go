@@ -0,0 +1,277 @@
+package buffer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"logchat/agent/internal/config"
+)
+
+// sqliteDriver is the database/sql driver the sqlite buffer opens. It is
+// registered by sqlite_driver.go, which is only built with -tags sqlite so
+// the default binary doesn't carry the driver.
+const sqliteDriver = "sqlite"
+
+// SQLiteBuffer implements durable buffering in a SQLite database. Entries
+// live in one table ordered by an autoincrement id, and every operation is
+// a transaction, so a crash never loses or half-applies a change.
+type SQLiteBuffer struct {
+	mu       sync.RWMutex
+	db       *sql.DB
+	maxItems int
+	maxSize  int64
+
+	// Cached so Len and Size don't query on every flush check
+	count   int
+	curSize int64
+}
+
+// newSQLiteBuffer opens or creates the buffer database. Path is the
+// database file, or a directory to create buffer.db in.
+func newSQLiteBuffer(cfg config.BufferConfig) (*SQLiteBuffer, error) {
+	if !sqliteAvailable() {
+		return nil, fmt.Errorf("sqlite buffer is not available in this build (rebuild with -tags sqlite)")
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "logchat-buffer")
+	}
+	if info, err := os.Stat(path); (err == nil && info.IsDir()) || (os.IsNotExist(err) && filepath.Ext(path) == "") {
+		path = filepath.Join(path, "buffer.db")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open buffer database: %w", err)
+	}
+	// One connection serializes writers and keeps the pragmas in effect
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA synchronous = FULL`,
+		`CREATE TABLE IF NOT EXISTS entries (
+			id   INTEGER PRIMARY KEY AUTOINCREMENT,
+			data BLOB NOT NULL,
+			size INTEGER NOT NULL
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize buffer database: %w", err)
+		}
+	}
+
+	buf := &SQLiteBuffer{
+		db:       db,
+		maxItems: cfg.MaxItems,
+		maxSize:  cfg.MaxSize,
+	}
+
+	row := db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM entries`)
+	if err := row.Scan(&buf.count, &buf.curSize); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load buffer database: %w", err)
+	}
+
+	return buf, nil
+}
+
+// sqliteAvailable reports whether the sqlite driver is compiled in
+func sqliteAvailable() bool {
+	for _, name := range sql.Drivers() {
+		if name == sqliteDriver {
+			return true
+		}
+	}
+	return false
+}
+
+// Push inserts an entry, evicting the oldest ones past max_size or max_items
+func (b *SQLiteBuffer) Push(entry LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, curSize := b.count, b.curSize
+
+	// Evict by size, then by count, as the memory buffer does
+	for curSize+size > b.maxSize && count > 0 {
+		n, freed, err := deleteOldest(tx, max(count/10, 1))
+		if err != nil {
+			return err
+		}
+		count -= n
+		curSize -= freed
+	}
+	if count >= b.maxItems && count > 0 {
+		n, freed, err := deleteOldest(tx, count-b.maxItems+1)
+		if err != nil {
+			return err
+		}
+		count -= n
+		curSize -= freed
+	}
+
+	if _, err := tx.Exec(`INSERT INTO entries (data, size) VALUES (?, ?)`, data, size); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	b.count, b.curSize = count+1, curSize+size
+	return nil
+}
+
+// deleteOldest deletes up to n of the lowest ids and returns how many rows
+// and bytes it removed
+func deleteOldest(tx *sql.Tx, n int) (int, int64, error) {
+	var count int
+	var size int64
+	row := tx.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM
+		(SELECT size FROM entries ORDER BY id LIMIT ?)`, n)
+	if err := row.Scan(&count, &size); err != nil {
+		return 0, 0, err
+	}
+
+	_, err := tx.Exec(`DELETE FROM entries WHERE id IN
+		(SELECT id FROM entries ORDER BY id LIMIT ?)`, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, size, nil
+}
+
+// Pop removes and returns the oldest entries
+func (b *SQLiteBuffer) Pop(count int) ([]LogEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entries, err := selectOldest(tx, count)
+	if err != nil {
+		return nil, err
+	}
+	n, freed, err := deleteOldest(tx, len(entries))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	b.count -= n
+	b.curSize -= freed
+	return entries, nil
+}
+
+// Peek returns the oldest entries without removing them
+func (b *SQLiteBuffer) Peek(count int) ([]LogEntry, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	return selectOldest(tx, count)
+}
+
+// selectOldest reads up to count entries in insertion order. Rows that no
+// longer decode are skipped rather than wedging the buffer.
+func selectOldest(tx *sql.Tx, count int) ([]LogEntry, error) {
+	rows, err := tx.Query(`SELECT data FROM entries ORDER BY id LIMIT ?`, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]LogEntry, 0, count)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var entry LogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Warn("Skipping undecodable entry", "error", strings.TrimSpace(err.Error()))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Remove deletes the oldest count entries
+func (b *SQLiteBuffer) Remove(count int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	n, freed, err := deleteOldest(tx, count)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	b.count -= n
+	b.curSize -= freed
+	return nil
+}
+
+// Len returns the number of entries in the buffer
+func (b *SQLiteBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count
+}
+
+// Size returns the serialized size of the buffered entries in bytes
+func (b *SQLiteBuffer) Size() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.curSize
+}
+
+// Close closes the database
+func (b *SQLiteBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.db.Close()
+}
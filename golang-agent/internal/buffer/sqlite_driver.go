@@ -0,0 +1,7 @@
+//go:build sqlite
+// +build sqlite
+
+package buffer
+
+// Registers the pure-Go "sqlite" driver for the sqlite buffer
+import _ "modernc.org/sqlite"
@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// changeTracker remembers the last value seen per key so state files and
+// command outputs can be turned into change events
+type changeTracker struct {
+	mu    sync.Mutex
+	field string
+	last  map[string]string
+}
+
+func newChangeTracker(field string) *changeTracker {
+	return &changeTracker{
+		field: field,
+		last:  make(map[string]string),
+	}
+}
+
+// update records value for key and returns the previous value when it
+// changed. The first value seen for a key only establishes a baseline.
+func (t *changeTracker) update(key, value string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	old, seen := t.last[key]
+	t.last[key] = value
+	return old, seen && old != value
+}
+
+// extract finds the tracked field in content. JSON documents are searched by
+// dotted path; other content by "field=value" or "field: value" lines. An
+// empty field tracks the whole content.
+func (t *changeTracker) extract(content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	if t.field == "" {
+		return content, true
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(content), &doc); err == nil {
+		var cur any = doc
+		for _, part := range strings.Split(t.field, ".") {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return "", false
+			}
+			if cur, ok = obj[part]; !ok {
+				return "", false
+			}
+		}
+		return fmt.Sprint(cur), true
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		for _, sep := range []string{"=", ":"} {
+			if key, value, ok := strings.Cut(line, sep); ok && strings.TrimSpace(key) == t.field {
+				return strings.Trim(strings.TrimSpace(value), `"'`), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// changeMessage describes a value transition
+func (t *changeTracker) changeMessage(subject, old, value string) string {
+	name := t.field
+	if name == "" {
+		name = "content"
+	}
+	return fmt.Sprintf("%s %s changed: %q -> %q", subject, name, truncateValue(old), truncateValue(value))
+}
+
+// truncateValue shortens long values for change messages
+func truncateValue(value string) string {
+	if len(value) > 200 {
+		return value[:200] + "..."
+	}
+	return value
+}
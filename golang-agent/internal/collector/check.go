@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/sender"
+)
+
+// checker is a collector that can verify its setup before it runs. check
+// must not collect, send or persist anything; on success it describes
+// what it verified.
+type checker interface {
+	check() (string, error)
+}
+
+// CheckResult is the readiness of one configured collector
+type CheckResult struct {
+	Name   string
+	Detail string // What was verified, e.g. how many files matched
+	Err    error  // Nil when the collector is ready to run
+}
+
+// Check builds the configured collectors without starting them and reports
+// whether each is ready to run. Collectors with nothing to verify, such as
+// stdin, are reported ready.
+func Check(cfg config.CollectorsConfig, snd *sender.Sender) []CheckResult {
+	var results []CheckResult
+	for _, c := range Initialize(cfg, snd) {
+		result := CheckResult{Name: c.Name(), Detail: "ready"}
+		if ch, ok := c.(checker); ok {
+			result.Detail, result.Err = ch.check()
+		}
+		results = append(results, result)
+	}
+	return results
+}
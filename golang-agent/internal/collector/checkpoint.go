@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileCheckpoint is the read position of one file. The inode tells a
+// resumed file apart from a new file that took its path during a restart.
+type fileCheckpoint struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// checkpointStore persists file read offsets so a restart resumes where the
+// previous run stopped instead of at the end of each file
+type checkpointStore struct {
+	mu      sync.Mutex
+	writeMu sync.Mutex // Serializes saves, which share a temp file
+	path    string
+	files   map[string]fileCheckpoint
+	dirty   bool
+}
+
+// newCheckpointStore loads the checkpoints for a collector. Without a
+// directory the store only tracks offsets in memory for lag reporting, and
+// it is nil when neither is wanted.
+func newCheckpointStore(dir, name string, track bool) *checkpointStore {
+	if dir == "" {
+		if !track {
+			return nil
+		}
+		return &checkpointStore{files: make(map[string]fileCheckpoint)}
+	}
+
+	// One file per collector so collectors sharing a directory don't clash
+	file := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(name) + ".json"
+	return &checkpointStore{
+		path:  filepath.Join(dir, file),
+		files: make(map[string]fileCheckpoint),
+	}
+}
+
+// load reads the saved checkpoints. It runs when the collector starts
+// rather than when it's built, so a collector replaced on reload picks up
+// its predecessor's final save.
+func (cs *checkpointStore) load() {
+	if cs.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(cs.path)
+	if err != nil {
+		return
+	}
+
+	cs.mu.Lock()
+	json.Unmarshal(data, &cs.files)
+	cs.mu.Unlock()
+}
+
+// get returns the saved checkpoint for a path
+func (cs *checkpointStore) get(path string) (fileCheckpoint, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cp, ok := cs.files[path]
+	return cp, ok
+}
+
+// set records the position up to which a file has been emitted
+func (cs *checkpointStore) set(path string, inode uint64, offset int64) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cp, ok := cs.files[path]; ok && cp.Inode == inode && cp.Offset == offset {
+		return
+	}
+	cs.files[path] = fileCheckpoint{Inode: inode, Offset: offset}
+	cs.dirty = true
+}
+
+// remove forgets a file that is no longer tailed
+func (cs *checkpointStore) remove(path string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.files[path]; ok {
+		delete(cs.files, path)
+		cs.dirty = true
+	}
+}
+
+// save writes the checkpoints to disk if they changed since the last save
+func (cs *checkpointStore) save() error {
+	if cs.path == "" {
+		return nil
+	}
+
+	cs.writeMu.Lock()
+	defer cs.writeMu.Unlock()
+
+	cs.mu.Lock()
+	if !cs.dirty {
+		cs.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(cs.files)
+	cs.dirty = false
+	cs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cs.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := cs.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, cs.path)
+}
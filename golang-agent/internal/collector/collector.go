@@ -1,10 +1,21 @@
 package collector
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/processor"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 )
 
@@ -14,12 +25,31 @@ type Collector interface {
 	Start(ctx context.Context)
 	Stop()
 	Stats() map[string]any
+
+	// Drain emits data still held once Start has returned, such as held
+	// repeated lines or a frame a connection was reading, and returns when
+	// done or ctx expires. Collectors with nothing held return nil.
+	Drain(ctx context.Context) error
+
+	// Fingerprint identifies the config the collector was built from, so a
+	// reload can leave unchanged collectors running
+	Fingerprint() string
 }
 
 // BaseCollector provides common functionality for collectors
 type BaseCollector struct {
 	name   string
 	sender *sender.Sender
+	class  string // Delivery class for the sender
+	schema string // Schema version stamped into metadata
+
+	fingerprint string // Hash of the collector config
+	minLevel    string // Entries below this level are dropped
+
+	limiter *ratelimit.Limiter // Nil when the collector isn't rate limited
+	repeats *repeatCollapser   // Nil unless repeated lines are collapsed
+
+	logsFiltered int64 // atomic
 
 	// Stats
 	logsCollected int64
@@ -28,6 +58,94 @@ type BaseCollector struct {
 	running       bool
 }
 
+// send queues an entry with the collector's delivery class
+func (bc *BaseCollector) send(entry buffer.LogEntry) error {
+	if processor.BelowLevel(entry.Level, bc.minLevel) {
+		atomic.AddInt64(&bc.logsFiltered, 1)
+		return nil
+	}
+	if bc.repeats != nil {
+		return bc.repeats.send(entry, bc.ship)
+	}
+	return bc.ship(entry)
+}
+
+// ship stamps and queues an entry that passed the collector's filters
+func (bc *BaseCollector) ship(entry buffer.LogEntry) error {
+	if !bc.limiter.Allow() {
+		return nil
+	}
+
+	entry.Class = bc.class
+	if bc.schema != "" {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]any)
+		}
+		entry.Metadata["schema_version"] = bc.schema
+	}
+	return bc.sender.Send(entry)
+}
+
+// logger returns the collector's logger
+func (bc *BaseCollector) logger() *slog.Logger {
+	return logging.For(bc.name)
+}
+
+// Drain ships repeated lines still held back, once the collector has
+// stopped
+func (bc *BaseCollector) Drain(ctx context.Context) error {
+	if bc.repeats != nil {
+		bc.repeats.flush()
+	}
+	return nil
+}
+
+// waitCtx waits for wg, giving up with ctx's error when it expires first
+func waitCtx(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolveSchemaVersion returns the configured schema version, deriving one
+// from a hash of the collector config when set to "auto"
+func resolveSchemaVersion(version string, cfg any) string {
+	if version != "auto" {
+		return version
+	}
+
+	hash := configHash(cfg)
+	if hash == "" {
+		return ""
+	}
+	return "cfg-" + hash
+}
+
+// configHash returns a short hash of a collector config, empty if the
+// config can't be encoded
+func configHash(cfg any) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Fingerprint returns the hash of the config the collector was built from
+func (bc *BaseCollector) Fingerprint() string {
+	return bc.fingerprint
+}
+
 // createLogEntry creates a log entry with common fields
 func createLogEntry(level, message, service, source string, tags map[string]string) buffer.LogEntry {
 	entry := buffer.LogEntry{
@@ -86,3 +204,30 @@ func containsWord(message, word string) bool {
 func isAlphanumeric(c byte) bool {
 	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
+
+// readLimitedLine reads one newline-terminated line of at most maxLen bytes.
+// Longer lines are truncated and the remainder discarded, so one huge line
+// cannot stall the reader. The returned line has no trailing newline.
+func readLimitedLine(r *bufio.Reader, maxLen int) ([]byte, bool, error) {
+	var line []byte
+	truncated := false
+
+	for {
+		chunk, err := r.ReadSlice('\n')
+
+		if room := maxLen - len(line); len(chunk) > room {
+			if room > 0 {
+				line = append(line, chunk[:room]...)
+			}
+			truncated = true
+		} else {
+			line = append(line, chunk...)
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		return bytes.TrimRight(line, "\r\n"), truncated, err
+	}
+}
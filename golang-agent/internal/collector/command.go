@@ -3,13 +3,20 @@ package collector
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 )
 
@@ -18,18 +25,37 @@ type CommandCollector struct {
 	BaseCollector
 	mu sync.RWMutex
 
-	config config.CommandCollectorConfig
+	config  config.CommandCollectorConfig
+	changes *changeTracker // Set in on_change mode
+
+	// dedupe_unchanged state
+	lastOutput     [sha256.Size]byte
+	lastShipped    time.Time
+	suppressedRuns int64
 }
 
 // NewCommandCollector creates a new command collector
 func NewCommandCollector(cfg config.CommandCollectorConfig, snd *sender.Sender) *CommandCollector {
-	return &CommandCollector{
+	cc := &CommandCollector{
 		BaseCollector: BaseCollector{
 			name:   fmt.Sprintf("cmd:%s", cfg.Service),
 			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
 		},
 		config: cfg,
 	}
+
+	if cfg.OnChange != nil {
+		cc.changes = newChangeTracker(cfg.OnChange.Field)
+	}
+
+	return cc
 }
 
 // Name returns the collector name
@@ -81,12 +107,16 @@ func (cc *CommandCollector) Stats() map[string]any {
 	defer cc.mu.RUnlock()
 
 	return map[string]any{
-		"name":           cc.name,
-		"logs_collected": cc.logsCollected,
-		"errors_count":   cc.errorsCount,
-		"last_collected": cc.lastCollected,
-		"running":        cc.running,
-		"command":        cc.config.Command,
+		"name":              cc.name,
+		"logs_collected":    cc.logsCollected,
+		"errors_count":      cc.errorsCount,
+		"logs_filtered":     atomic.LoadInt64(&cc.logsFiltered),
+		"rate_limited":      cc.limiter.Dropped(),
+		"repeats_collapsed": cc.repeats.collapsedCount(),
+		"last_collected":    cc.lastCollected,
+		"running":           cc.running,
+		"command":           cc.config.Command,
+		"suppressed_runs":   cc.suppressedRuns,
 	}
 }
 
@@ -100,7 +130,7 @@ func (cc *CommandCollector) runCommand(ctx context.Context) {
 	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(cmdCtx, cc.config.Command, cc.config.Args...)
+	cmd := cc.buildCommand(cmdCtx)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -108,11 +138,24 @@ func (cc *CommandCollector) runCommand(ctx context.Context) {
 
 	err := cmd.Run()
 
+	exitCode := 0
+	if err != nil {
+		exitCode = -1 // Didn't start or was killed
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	if cc.config.DedupeUnchanged && cc.unchanged(stdout.Bytes(), stderr.Bytes(), err == nil) {
+		return
+	}
+
 	// Process stdout as a single log entry
 	if stdout.Len() > 0 {
 		output := strings.TrimSpace(stdout.String())
 		if output != "" {
-			cc.processOutput(output, "stdout", err == nil)
+			cc.processOutput(output, "stdout", exitCode)
 		}
 	}
 
@@ -120,7 +163,7 @@ func (cc *CommandCollector) runCommand(ctx context.Context) {
 	if stderr.Len() > 0 {
 		output := strings.TrimSpace(stderr.String())
 		if output != "" {
-			cc.processOutput(output, "stderr", false)
+			cc.processOutput(output, "stderr", exitCode)
 		}
 	}
 
@@ -131,17 +174,109 @@ func (cc *CommandCollector) runCommand(ctx context.Context) {
 	}
 }
 
-// processOutput processes the complete command output as a single log entry
-func (cc *CommandCollector) processOutput(text, stream string, success bool) {
+// buildCommand prepares the command: executed directly by default, or as a
+// script for the platform shell when shell is set. Args become the script's
+// positional parameters.
+func (cc *CommandCollector) buildCommand(ctx context.Context) *exec.Cmd {
+	var cmd *exec.Cmd
+	switch {
+	case !cc.config.Shell:
+		cmd = exec.CommandContext(ctx, cc.config.Command, cc.config.Args...)
+	case runtime.GOOS == "windows":
+		cmd = exec.CommandContext(ctx, "cmd", append([]string{"/c", cc.config.Command}, cc.config.Args...)...)
+	default:
+		cmd = exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", cc.config.Command, "sh"}, cc.config.Args...)...)
+	}
+
+	cmd.Dir = cc.config.WorkDir
+	if len(cc.config.Env) > 0 {
+		keys := make([]string, 0, len(cc.config.Env))
+		for k := range cc.config.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		cmd.Env = os.Environ()
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+cc.config.Env[k])
+		}
+	}
+	return cmd
+}
+
+// unchanged reports whether this run's output matches the previous run and
+// should be suppressed. Unchanged output is still shipped once per keepalive.
+func (cc *CommandCollector) unchanged(stdout, stderr []byte, success bool) bool {
+	h := sha256.New()
+	h.Write(stdout)
+	h.Write([]byte{0})
+	h.Write(stderr)
+	fmt.Fprintf(h, "\x00%t", success)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	keepaliveDue := cc.config.Keepalive > 0 && time.Since(cc.lastShipped) >= cc.config.Keepalive
+	if !cc.lastShipped.IsZero() && sum == cc.lastOutput && !keepaliveDue {
+		cc.suppressedRuns++
+		return true
+	}
+
+	cc.lastOutput = sum
+	cc.lastShipped = time.Now()
+	return false
+}
+
+// processOutput processes the complete command output as a single log
+// entry, or as one entry per line when split_lines is set
+func (cc *CommandCollector) processOutput(text, stream string, exitCode int) {
 	if text == "" {
 		return
 	}
 
+	if cc.config.SplitLines && cc.changes == nil {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			cc.ship(parseLevel(line), line, stream, exitCode, nil)
+		}
+		return
+	}
+
 	level := "INFO"
-	if stream == "stderr" || !success {
+	if stream == "stderr" || exitCode != 0 {
 		level = "ERROR"
 	}
 
+	// In on_change mode stdout is only shipped when the tracked value changes
+	var change map[string]any
+	if cc.changes != nil && stream == "stdout" {
+		value, ok := cc.changes.extract(text)
+		if !ok {
+			return
+		}
+		old, changed := cc.changes.update(stream, value)
+		if !changed {
+			return
+		}
+		text = cc.changes.changeMessage(cc.config.Command, old, value)
+		change = map[string]any{
+			"field":     cc.config.OnChange.Field,
+			"old_value": old,
+			"new_value": value,
+		}
+	}
+
+	cc.ship(level, text, stream, exitCode, change)
+}
+
+// ship sends one entry of command output
+func (cc *CommandCollector) ship(level, text, stream string, exitCode int, extra map[string]any) {
 	entry := createLogEntry(
 		level,
 		text,
@@ -154,13 +289,17 @@ func (cc *CommandCollector) processOutput(text, stream string, success bool) {
 	)
 
 	entry.Metadata = map[string]any{
-		"command": cc.config.Command,
-		"args":    cc.config.Args,
-		"stream":  stream,
-		"success": success,
+		"command":   cc.config.Command,
+		"args":      cc.config.Args,
+		"stream":    stream,
+		"success":   exitCode == 0,
+		"exit_code": exitCode,
+	}
+	for k, v := range extra {
+		entry.Metadata[k] = v
 	}
 
-	if err := cc.sender.Send(entry); err != nil {
+	if err := cc.send(entry); err != nil {
 		cc.mu.Lock()
 		cc.errorsCount++
 		cc.mu.Unlock()
@@ -172,3 +311,30 @@ func (cc *CommandCollector) processOutput(text, stream string, success bool) {
 	cc.lastCollected = time.Now()
 	cc.mu.Unlock()
 }
+
+// check verifies the command, or the shell running it, can be found and
+// the working directory exists
+func (cc *CommandCollector) check() (string, error) {
+	name := cc.config.Command
+	if cc.config.Shell {
+		name = "/bin/sh"
+		if runtime.GOOS == "windows" {
+			name = "cmd"
+		}
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if dir := cc.config.WorkDir; dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("work_dir %s is not a directory", dir)
+		}
+	}
+	return "runs " + path, nil
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+// criMaxPartial caps a reassembled CRI line; longer ones are emitted as is
+const criMaxPartial = 1024 * 1024
+
+// criLine is one line of the CRI container log format:
+// "<RFC 3339 timestamp> <stdout|stderr> <P|F> <content>"
+type criLine struct {
+	timestamp string
+	stream    string
+	partial   bool // "P": the runtime split a long line, more follows
+	content   string
+}
+
+// splitCRI splits a CRI log line, reporting false for other formats
+func splitCRI(text string) (criLine, bool) {
+	fields := strings.SplitN(text, " ", 4)
+	if len(fields) < 3 {
+		return criLine{}, false
+	}
+	if fields[1] != "stdout" && fields[1] != "stderr" {
+		return criLine{}, false
+	}
+
+	// The tag field may carry more ":"-separated tags after P or F
+	tag, _, _ := strings.Cut(fields[2], ":")
+	if tag != "P" && tag != "F" {
+		return criLine{}, false
+	}
+
+	line := criLine{timestamp: fields[0], stream: fields[1], partial: tag == "P"}
+	if len(fields) == 4 {
+		line.content = fields[3]
+	}
+	return line, true
+}
+
+// formatCRI renders a complete CRI line
+func formatCRI(line criLine) string {
+	return line.timestamp + " " + line.stream + " F " + line.content
+}
+
+// criPartials reassembles lines a container runtime split into parts: CRI
+// "P" parts followed by a final "F" part, or docker-json records without a
+// trailing newline. Streams are tracked separately since stdout and stderr
+// parts interleave.
+type criPartials struct {
+	streams map[string]*criPending
+	split   func(text string) (criLine, bool)
+	format  func(line criLine) string // Renders a reassembled line
+}
+
+// criPending is a line whose final part hasn't arrived yet
+type criPending struct {
+	timestamp string
+	offset    int64 // Offset of the first part
+	content   strings.Builder
+}
+
+// newCRIPartials creates per-file reassembly state, nil unless the cri or
+// docker-json parser is in use
+func newCRIPartials(parser string) *criPartials {
+	c := &criPartials{streams: make(map[string]*criPending)}
+	switch parser {
+	case "cri":
+		c.split, c.format = splitCRI, formatCRI
+	case "docker-json":
+		c.split, c.format = splitDockerJSON, formatDockerJSON
+	default:
+		return nil
+	}
+	return c
+}
+
+// add feeds one line and returns the complete line and its offset, if any.
+// Lines in another format pass through unchanged.
+func (c *criPartials) add(text string, offset int64) (string, int64, bool) {
+	line, ok := c.split(text)
+	if !ok {
+		return text, offset, true
+	}
+
+	p := c.streams[line.stream]
+	if p == nil {
+		if !line.partial {
+			return text, offset, true
+		}
+		p = &criPending{timestamp: line.timestamp, offset: offset}
+		c.streams[line.stream] = p
+	}
+
+	p.content.WriteString(line.content)
+	if line.partial && p.content.Len() < criMaxPartial {
+		return "", 0, false
+	}
+
+	delete(c.streams, line.stream)
+	return c.format(criLine{timestamp: p.timestamp, stream: line.stream, content: p.content.String()}), p.offset, true
+}
+
+// pending reports the offset of the oldest incomplete line, if any
+func (c *criPartials) pending() (int64, bool) {
+	var oldest int64
+	found := false
+	for _, p := range c.streams {
+		if !found || p.offset < oldest {
+			oldest, found = p.offset, true
+		}
+	}
+	return oldest, found
+}
+
+// parseCRI parses a CRI container log line. The content becomes the
+// message and the stream is kept as metadata.
+func (fc *FileCollector) parseCRI(text string, entry *buffer.LogEntry) {
+	line, ok := splitCRI(text)
+	if !ok {
+		return
+	}
+
+	entry.Message = line.content
+	entry.Level = parseLevel(line.content)
+	if ts, err := time.Parse(time.RFC3339Nano, line.timestamp); err == nil {
+		entry.Timestamp = ts
+	}
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]any)
+	}
+	entry.Metadata["stream"] = line.stream
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	"logchat/agent/internal/buffer"
+)
+
+// parseCSV maps a CSV row onto metadata by column name. Without configured
+// columns the first row of each file is its header. It reports whether the
+// line should be emitted: header rows are not. Rows with the wrong number
+// of fields are counted as errors and kept as plain text.
+func (fc *FileCollector) parseCSV(filePath, text string, offset int64, entry *buffer.LogEntry) bool {
+	fields, err := fc.splitCSV(text)
+	if err != nil {
+		fc.csvError(filePath, err)
+		return true
+	}
+
+	columns := fc.config.CSVColumns
+	if len(columns) == 0 {
+		// The first line is the header, and a rotated file brings its own
+		if offset == 0 {
+			fc.setCSVHeader(filePath, fields)
+			return false
+		}
+		var ok bool
+		if columns, ok = fc.csvHeader(filePath); !ok {
+			// No file to read it from, as with stdin: this row is the header
+			fc.setCSVHeader(filePath, fields)
+			return false
+		}
+	}
+
+	if len(fields) != len(columns) {
+		fc.csvError(filePath, fmt.Errorf("row has %d fields, want %d", len(fields), len(columns)))
+		return true
+	}
+
+	metadata := make(map[string]any, len(columns))
+	for i, name := range columns {
+		metadata[name] = fields[i]
+	}
+
+	if col := fc.config.CSVMessageColumn; col != "" {
+		if value, ok := metadata[col].(string); ok {
+			entry.Message = value
+		}
+	}
+	if col := fc.config.CSVLevelColumn; col != "" {
+		if value, ok := metadata[col].(string); ok && value != "" {
+			entry.Level = strings.ToUpper(value)
+		}
+	}
+	if col := fc.config.CSVTimestampColumn; col != "" {
+		if value, ok := metadata[col]; ok {
+			fc.setTimestamp(entry, value)
+		}
+	}
+
+	entry.Metadata = metadata
+	return true
+}
+
+// splitCSV splits one line into its fields
+func (fc *FileCollector) splitCSV(text string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = fc.csvDelimiter()
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r.Read()
+}
+
+// csvDelimiter returns the configured field delimiter, a comma by default
+func (fc *FileCollector) csvDelimiter() rune {
+	for _, c := range fc.config.CSVDelimiter {
+		return c
+	}
+	return ','
+}
+
+// csvHeader returns the header of a file, reading its first line if it
+// hasn't been seen yet, as when tailing starts at the end. Compressed files
+// are read from their first row, so theirs is always known.
+func (fc *FileCollector) csvHeader(filePath string) ([]string, bool) {
+	fc.mu.RLock()
+	header, ok := fc.csvHeaders[filePath]
+	fc.mu.RUnlock()
+	if ok || isCompressed(filePath) {
+		return header, ok
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	head, _ := reader.Peek(headSize)
+	decoder := newLineDecoder(fc.config.Encoding, head)
+	line, err := decoder.readLine(reader)
+	if line == "" && err != nil {
+		return nil, false
+	}
+	line = decoder.decode(line)
+	header, err = fc.splitCSV(strings.TrimRight(line, "\r"))
+	if err != nil {
+		return nil, false
+	}
+
+	fc.setCSVHeader(filePath, header)
+	return header, true
+}
+
+// setCSVHeader records the header of a file
+func (fc *FileCollector) setCSVHeader(filePath string, header []string) {
+	fc.mu.Lock()
+	fc.csvHeaders[filePath] = header
+	fc.mu.Unlock()
+}
+
+// csvError counts a row that could not be mapped onto the columns
+func (fc *FileCollector) csvError(filePath string, err error) {
+	fc.mu.Lock()
+	fc.errorsCount++
+	fc.mu.Unlock()
+	fc.logger().Warn("Invalid CSV row", "path", filePath, "error", err)
+}
@@ -0,0 +1,517 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
+	"logchat/agent/internal/sender"
+)
+
+// DockerCollector streams container logs from the Docker daemon, or from
+// Podman through its Docker-compatible API
+type DockerCollector struct {
+	BaseCollector
+	mu sync.RWMutex
+
+	config  config.DockerCollectorConfig
+	socket  string
+	client  *http.Client
+	streams map[string]context.CancelFunc // Container ID -> log stream
+	cancel  context.CancelFunc
+
+	podman bool              // Use libpod endpoints for pod metadata
+	pods   map[string]string // Pod ID -> pod name
+}
+
+// dockerContainer is the subset of container details the collector uses
+type dockerContainer struct {
+	ID     string
+	Name   string
+	Image  string
+	Labels map[string]string
+	TTY    bool
+	PodID  string // Podman only
+	Pod    string
+}
+
+// NewDockerCollector creates a new Docker collector
+func NewDockerCollector(cfg config.DockerCollectorConfig, snd *sender.Sender) *DockerCollector {
+	socket := cfg.Socket
+	if socket == "" {
+		socket = "/var/run/docker.sock"
+	}
+	return newContainerCollector("docker", socket, cfg, snd)
+}
+
+// newContainerCollector creates a collector for a Docker-compatible API socket
+func newContainerCollector(name, socket string, cfg config.DockerCollectorConfig, snd *sender.Sender) *DockerCollector {
+	socket = strings.TrimPrefix(socket, "unix://")
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &DockerCollector{
+		BaseCollector: BaseCollector{
+			name:   name,
+			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
+		},
+		config:  cfg,
+		socket:  socket,
+		client:  &http.Client{Transport: transport},
+		streams: make(map[string]context.CancelFunc),
+	}
+}
+
+// Name returns the collector name
+func (dc *DockerCollector) Name() string {
+	return dc.name
+}
+
+// Start lists running containers, streams their logs and follows container
+// start events so containers launched later are picked up
+func (dc *DockerCollector) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	dc.mu.Lock()
+	dc.running = true
+	dc.cancel = cancel
+	dc.mu.Unlock()
+
+	defer func() {
+		dc.mu.Lock()
+		dc.running = false
+		dc.mu.Unlock()
+	}()
+
+	dc.logger().Info("Connecting", "socket", dc.socket)
+
+	since := dc.parseSince(dc.config.Since)
+	containers, err := dc.listContainers(ctx)
+	if err != nil {
+		dc.logger().Error("Error listing containers", "error", err)
+	}
+	for _, c := range containers {
+		dc.follow(ctx, c, since)
+	}
+
+	// Watch for new containers until cancelled, reconnecting on errors
+	for {
+		err := dc.watchEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		dc.logger().Warn("Event stream ended, reconnecting", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// Stop stops the Docker collector
+func (dc *DockerCollector) Stop() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.running = false
+	if dc.cancel != nil {
+		dc.cancel()
+	}
+}
+
+// Stats returns collector statistics
+func (dc *DockerCollector) Stats() map[string]any {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return map[string]any{
+		"name":               dc.name,
+		"logs_collected":     dc.logsCollected,
+		"errors_count":       dc.errorsCount,
+		"logs_filtered":      atomic.LoadInt64(&dc.logsFiltered),
+		"rate_limited":       dc.limiter.Dropped(),
+		"repeats_collapsed":  dc.repeats.collapsedCount(),
+		"last_collected":     dc.lastCollected,
+		"containers_watched": len(dc.streams),
+		"running":            dc.running,
+	}
+}
+
+// get issues a GET against the Docker API over the socket
+func (dc *DockerCollector) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	u := "http://docker" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := dc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker API %s: %d %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// listContainers returns running containers that pass the filters
+func (dc *DockerCollector) listContainers(ctx context.Context) ([]dockerContainer, error) {
+	resp, err := dc.get(ctx, "/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list []struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+
+	var containers []dockerContainer
+	for _, item := range list {
+		c, err := dc.inspect(ctx, item.ID)
+		if err != nil {
+			dc.logger().Error("Error inspecting container", "container", shortID(item.ID), "error", err)
+			continue
+		}
+		if dc.matches(c) {
+			containers = append(containers, c)
+		}
+	}
+	return containers, nil
+}
+
+// inspect fetches container details
+func (dc *DockerCollector) inspect(ctx context.Context, id string) (dockerContainer, error) {
+	if dc.podman {
+		return dc.inspectPodman(ctx, id)
+	}
+
+	resp, err := dc.get(ctx, "/containers/"+id+"/json", nil)
+	if err != nil {
+		return dockerContainer{}, err
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Labels map[string]string `json:"Labels"`
+			Tty    bool              `json:"Tty"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return dockerContainer{}, fmt.Errorf("failed to decode container: %w", err)
+	}
+
+	return dockerContainer{
+		ID:     info.ID,
+		Name:   strings.TrimPrefix(info.Name, "/"),
+		Image:  info.Config.Image,
+		Labels: info.Config.Labels,
+		TTY:    info.Config.Tty,
+	}, nil
+}
+
+// matches applies the container allow-list and label filters. Labels are
+// "key" (must exist) or "key=value" (must equal) and must all match.
+func (dc *DockerCollector) matches(c dockerContainer) bool {
+	if len(dc.config.Containers) > 0 {
+		allowed := false
+		for _, want := range dc.config.Containers {
+			if want == c.Name || (len(want) >= 12 && strings.HasPrefix(c.ID, want)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	for _, label := range dc.config.Labels {
+		key, value, hasValue := strings.Cut(label, "=")
+		got, ok := c.Labels[key]
+		if !ok || (hasValue && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchEvents follows container start events and streams the new containers
+func (dc *DockerCollector) watchEvents(ctx context.Context) error {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start"},
+	})
+
+	resp, err := dc.get(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			return err
+		}
+		if event.ID == "" {
+			continue
+		}
+
+		c, err := dc.inspect(ctx, event.ID)
+		if err != nil {
+			dc.logger().Error("Error inspecting container", "container", shortID(event.ID), "error", err)
+			continue
+		}
+		if dc.matches(c) {
+			// A new container's logs are read from the start
+			dc.follow(ctx, c, 0)
+		}
+	}
+}
+
+// follow starts streaming a container's logs unless already streaming
+func (dc *DockerCollector) follow(ctx context.Context, c dockerContainer, since int64) {
+	dc.mu.Lock()
+	if _, ok := dc.streams[c.ID]; ok {
+		dc.mu.Unlock()
+		return
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	dc.streams[c.ID] = cancel
+	dc.mu.Unlock()
+
+	dc.logger().Info("Following container", "container", c.Name, "id", shortID(c.ID))
+
+	go func() {
+		defer func() {
+			cancel()
+			dc.mu.Lock()
+			delete(dc.streams, c.ID)
+			dc.mu.Unlock()
+		}()
+
+		if err := dc.streamLogs(streamCtx, c, since); err != nil && streamCtx.Err() == nil {
+			dc.logger().Warn("Log stream ended", "container", c.Name, "error", err)
+		}
+	}()
+}
+
+// streamLogs reads a container's log stream until it ends
+func (dc *DockerCollector) streamLogs(ctx context.Context, c dockerContainer, since int64) error {
+	query := url.Values{
+		"follow":     {"1"},
+		"stdout":     {"1"},
+		"stderr":     {"1"},
+		"timestamps": {"1"},
+	}
+	if since > 0 {
+		query.Set("since", strconv.FormatInt(since, 10))
+	}
+
+	resp, err := dc.get(ctx, "/containers/"+c.ID+"/logs", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// TTY containers send a raw stream; others multiplex stdout and stderr
+	if c.TTY {
+		return dc.readLines(c, "stdout", resp.Body)
+	}
+
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		dc.readLines(c, "stdout", stdoutR)
+	}()
+	go func() {
+		defer wg.Done()
+		dc.readLines(c, "stderr", stderrR)
+	}()
+
+	err = demuxDockerStream(resp.Body, stdoutW, stderrW)
+	stdoutW.Close()
+	stderrW.Close()
+	wg.Wait()
+	return err
+}
+
+// demuxDockerStream splits Docker's multiplexed log stream. Each frame has an
+// 8-byte header: stream type, three zero bytes and a big-endian payload size.
+func demuxDockerStream(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var w io.Writer
+		switch header[0] {
+		case 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			w = io.Discard
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:8]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// readLines turns one container output stream into log entries
+func (dc *DockerCollector) readLines(c dockerContainer, stream string, r io.Reader) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, _, err := readLimitedLine(reader, 1024*1024)
+		if len(line) > 0 {
+			dc.processLine(c, stream, string(line))
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// processLine sends one log line, using Docker's timestamp prefix
+func (dc *DockerCollector) processLine(c dockerContainer, stream, text string) {
+	ts := time.Now()
+	if stamp, rest, ok := strings.Cut(text, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			ts = t
+			text = rest
+		}
+	}
+	if text == "" {
+		return
+	}
+
+	entry := createLogEntry(
+		parseLevel(text),
+		text,
+		c.Name,
+		fmt.Sprintf("%s:%s", dc.name, c.Name),
+		map[string]string{
+			"container_name": c.Name,
+			"container_id":   shortID(c.ID),
+			"image":          c.Image,
+			"stream":         stream,
+		},
+	)
+	if c.Pod != "" {
+		entry.Tags["pod"] = c.Pod
+		entry.Tags["pod_id"] = shortID(c.PodID)
+	}
+	entry.Timestamp = ts
+
+	if err := dc.send(entry); err != nil {
+		dc.mu.Lock()
+		dc.errorsCount++
+		dc.mu.Unlock()
+		return
+	}
+
+	dc.mu.Lock()
+	dc.logsCollected++
+	dc.lastCollected = time.Now()
+	dc.mu.Unlock()
+}
+
+// parseSince converts the since setting (a duration like "1h", an
+// RFC3339 time or unix seconds) into unix seconds, 0 = from the beginning
+func (dc *DockerCollector) parseSince(since string) int64 {
+	if since == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d).Unix()
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t.Unix()
+	}
+	if n, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return n
+	}
+	dc.logger().Warn("Ignoring invalid since", "since", since)
+	return 0
+}
+
+// shortID returns the 12-character form of a container ID
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// check verifies the container API answers on the socket
+func (dc *DockerCollector) check() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := dc.get(ctx, "/_ping", nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return "API reachable at " + dc.socket, nil
+}
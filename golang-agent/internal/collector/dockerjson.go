@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+// dockerJSONLine is one line of Docker's json-file log driver, as found in
+// /var/lib/docker/containers/<id>/<id>-json.log
+type dockerJSONLine struct {
+	Log    string         `json:"log"` // Ends in "\n" unless Docker split a long line
+	Stream string         `json:"stream"`
+	Time   string         `json:"time"`
+	Attrs  map[string]any `json:"attrs,omitempty"` // Set with --log-opt labels/env
+}
+
+// splitDockerJSON decodes a json-file line, reporting false for other formats
+func splitDockerJSON(text string) (criLine, bool) {
+	var l dockerJSONLine
+	if err := json.Unmarshal([]byte(text), &l); err != nil || l.Stream == "" {
+		return criLine{}, false
+	}
+	return criLine{
+		timestamp: l.Time,
+		stream:    l.Stream,
+		partial:   !strings.HasSuffix(l.Log, "\n"),
+		content:   l.Log,
+	}, true
+}
+
+// formatDockerJSON renders a reassembled json-file line
+func formatDockerJSON(line criLine) string {
+	data, _ := json.Marshal(dockerJSONLine{Log: line.content, Stream: line.stream, Time: line.timestamp})
+	return string(data)
+}
+
+// parseDockerJSON parses a json-file log line. The log field becomes the
+// message, time the timestamp and stream a tag; attrs go to metadata.
+func (fc *FileCollector) parseDockerJSON(text string, entry *buffer.LogEntry) {
+	var l dockerJSONLine
+	if err := json.Unmarshal([]byte(text), &l); err != nil {
+		return
+	}
+
+	entry.Message = strings.TrimSuffix(strings.TrimSuffix(l.Log, "\n"), "\r")
+	entry.Level = parseLevel(entry.Message)
+	if ts, err := time.Parse(time.RFC3339Nano, l.Time); err == nil {
+		entry.Timestamp = ts
+	}
+	if l.Stream != "" {
+		entry.Tags["stream"] = l.Stream
+	}
+	if len(l.Attrs) > 0 {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]any)
+		}
+		for k, v := range l.Attrs {
+			entry.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+}
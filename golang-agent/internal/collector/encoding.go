@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// fileEncodings maps the encoding option to its decoder. Decoders replace
+// invalid sequences with U+FFFD rather than dropping them.
+var fileEncodings = map[string]encoding.Encoding{
+	"utf-8":        unicode.UTF8,
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"latin1":       charmap.ISO8859_1,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+}
+
+// lineDecoder splits a file into lines and converts them to UTF-8
+type lineDecoder struct {
+	enc   encoding.Encoding
+	utf16 bool
+	big   bool // Big-endian UTF-16
+}
+
+// newLineDecoder creates a decoder for a file starting with head, nil when
+// lines are passed through as is. A byte order mark in head selects UTF-8
+// or UTF-16 over the configured encoding.
+func newLineDecoder(name string, head []byte) *lineDecoder {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		name = "utf-8"
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		name = "utf-16le"
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		name = "utf-16be"
+	}
+
+	enc, ok := fileEncodings[name]
+	if !ok {
+		return nil
+	}
+	return &lineDecoder{
+		enc:   enc,
+		utf16: strings.HasPrefix(name, "utf-16"),
+		big:   name == "utf-16be",
+	}
+}
+
+// readLine reads one line, with its newline, from r, which must start on a
+// line. In UTF-16 the newline is the code unit U+000A, so a 0x0A byte that
+// is half of another code unit, such as U+010A or U+0A00, doesn't end the
+// line. When r ends first, the partial line is returned with the error.
+func (d *lineDecoder) readLine(r *bufio.Reader) (string, error) {
+	if d == nil || !d.utf16 {
+		return r.ReadString('\n')
+	}
+
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return string(line), err
+		}
+
+		// The 0x0A byte is the newline's low byte: the second byte of a
+		// code unit in big-endian, the first in little-endian
+		i := len(line) - 1
+		if d.big {
+			if i%2 == 1 && line[i-1] == 0 {
+				return string(line), nil
+			}
+			continue
+		}
+		if i%2 == 0 {
+			next, err := r.ReadByte()
+			if err != nil {
+				return string(line), err
+			}
+			line = append(line, next)
+			if next == 0 {
+				return string(line), nil
+			}
+		}
+	}
+}
+
+// decode converts one line read by readLine to UTF-8, without its newline
+func (d *lineDecoder) decode(line string) string {
+	if d == nil {
+		return strings.TrimSuffix(line, "\n")
+	}
+
+	switch {
+	case !d.utf16:
+		line = strings.TrimSuffix(line, "\n")
+	case d.big:
+		line = strings.TrimSuffix(line, "\x00\n")
+	default:
+		line = strings.TrimSuffix(line, "\n\x00")
+	}
+
+	text, err := d.enc.NewDecoder().String(line)
+	if err != nil {
+		return line
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(text, "\uFEFF"), "\r")
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/config"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestLineDecoderUTF16(t *testing.T) {
+	// U+010A and U+0A05 each hold a 0x0A byte that isn't a newline
+	lines := []string{"Ċ first", "ਅ second", "third ĊĊ ਅਅ"}
+	text := lines[0] + "\n" + lines[1] + "\r\n" + lines[2] + "\n"
+
+	for _, tc := range []struct {
+		name   string
+		endian unicode.Endianness
+	}{
+		{"utf-16le", unicode.LittleEndian},
+		{"utf-16be", unicode.BigEndian},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := unicode.UTF16(tc.endian, unicode.UseBOM).NewEncoder().Bytes([]byte(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decoder := newLineDecoder("", data)
+			if decoder == nil {
+				t.Fatal("byte order mark not detected")
+			}
+
+			reader := bufio.NewReader(bytes.NewReader(data))
+			var got []string
+			var read int
+			for {
+				line, err := decoder.readLine(reader)
+				if err == io.EOF && line == "" {
+					break
+				}
+				if err != nil {
+					t.Fatalf("readLine: %v (partial %q)", err, line)
+				}
+				read += len(line)
+				got = append(got, decoder.decode(line))
+			}
+
+			if read != len(data) {
+				t.Errorf("read %d bytes, want %d", read, len(data))
+			}
+			if len(got) != len(lines) {
+				t.Fatalf("got %d lines %q, want %q", len(got), got, lines)
+			}
+			for i := range lines {
+				if got[i] != lines[i] {
+					t.Errorf("line %d = %q, want %q", i, got[i], lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLineDecoderUTF16PartialLine(t *testing.T) {
+	data, err := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte("done\nhalf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := newLineDecoder("utf-16le", nil)
+	reader := bufio.NewReader(bytes.NewReader(data))
+
+	if line, err := decoder.readLine(reader); err != nil || decoder.decode(line) != "done" {
+		t.Fatalf("first line = %q, %v", decoder.decode(line), err)
+	}
+	if line, err := decoder.readLine(reader); err == nil {
+		t.Fatalf("unterminated line %q read without an error", line)
+	}
+}
+
+func TestFileCollectorUTF16(t *testing.T) {
+	want := []string{"Ċ first", "ਅ second", "third"}
+	data, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(strings.Join(want, "\r\n") + "\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fc, buf := newTestFileCollector(t, config.FileCollectorConfig{Paths: []string{path}})
+	stop := startCollector(t, fc)
+	waitForCount(t, buf, len(want), 5*time.Second)
+	stop()
+	assertMessages(t, bufferedMessages(t, buf), want)
+}
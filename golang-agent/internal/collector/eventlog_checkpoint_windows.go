@@ -0,0 +1,117 @@
+//go:build windows
+// +build windows
+
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// eventlogCheckpoint is the read position of one channel
+type eventlogCheckpoint struct {
+	Bookmark string `json:"bookmark,omitempty"` // Rendered Event Log API bookmark
+	Record   uint32 `json:"record,omitempty"`   // Last record read by the legacy reader
+}
+
+// eventlogCheckpoints persists channel positions so a restart resumes
+// where the previous run stopped instead of at the newest event
+type eventlogCheckpoints struct {
+	mu       sync.Mutex
+	path     string
+	channels map[string]eventlogCheckpoint
+	dirty    bool
+}
+
+// newEventlogCheckpoints loads the saved positions, dropping channels no
+// longer configured. It returns nil without a directory.
+func newEventlogCheckpoints(dir string, channels []string) *eventlogCheckpoints {
+	if dir == "" {
+		return nil
+	}
+
+	cs := &eventlogCheckpoints{
+		path:     filepath.Join(dir, "eventlog.json"),
+		channels: make(map[string]eventlogCheckpoint),
+	}
+	if data, err := os.ReadFile(cs.path); err == nil {
+		json.Unmarshal(data, &cs.channels)
+	}
+
+	configured := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		configured[channel] = true
+	}
+	for channel := range cs.channels {
+		if !configured[channel] {
+			delete(cs.channels, channel)
+			cs.dirty = true
+		}
+	}
+
+	return cs
+}
+
+// get returns the saved position of a channel. Like the setters, it is a
+// no-op on a nil store.
+func (cs *eventlogCheckpoints) get(channel string) (eventlogCheckpoint, bool) {
+	if cs == nil {
+		return eventlogCheckpoint{}, false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cp, ok := cs.channels[channel]
+	return cp, ok
+}
+
+// setBookmark records a channel's rendered bookmark
+func (cs *eventlogCheckpoints) setBookmark(channel, bookmark string) {
+	if cs == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.channels[channel].Bookmark != bookmark {
+		cs.channels[channel] = eventlogCheckpoint{Bookmark: bookmark}
+		cs.dirty = true
+	}
+}
+
+// setRecord records the last record the legacy reader read from a channel
+func (cs *eventlogCheckpoints) setRecord(channel string, record uint32) {
+	if cs == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.channels[channel].Record != record {
+		cs.channels[channel] = eventlogCheckpoint{Record: record}
+		cs.dirty = true
+	}
+}
+
+// save writes the positions to disk if they changed since the last save
+func (cs *eventlogCheckpoints) save() error {
+	cs.mu.Lock()
+	if !cs.dirty {
+		cs.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(cs.channels)
+	cs.dirty = false
+	cs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cs.path), 0755); err != nil {
+		return err
+	}
+	tmp := cs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cs.path)
+}
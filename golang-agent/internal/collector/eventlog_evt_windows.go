@@ -0,0 +1,466 @@
+//go:build windows
+// +build windows
+
+package collector
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	wevtapi          = windows.NewLazySystemDLL("wevtapi.dll")
+	procEvtSubscribe = wevtapi.NewProc("EvtSubscribe")
+	procEvtQuery     = wevtapi.NewProc("EvtQuery")
+	procEvtNext      = wevtapi.NewProc("EvtNext")
+	procEvtRender    = wevtapi.NewProc("EvtRender")
+	procEvtClose     = wevtapi.NewProc("EvtClose")
+
+	procEvtCreateBookmark = wevtapi.NewProc("EvtCreateBookmark")
+	procEvtUpdateBookmark = wevtapi.NewProc("EvtUpdateBookmark")
+)
+
+const (
+	evtSubscribeToFutureEvents     = 1
+	evtSubscribeStartAfterBookmark = 3
+	evtQueryChannelPath            = 0x1
+	evtQueryReverseDirection       = 0x200
+	evtRenderEventXml              = 1
+	evtRenderBookmark              = 2
+
+	// Audit keyword bits, set on Security channel events
+	evtKeywordAuditFailure = 0x10000000000000
+)
+
+// evtHandle is a handle from the Windows Event Log API
+type evtHandle uintptr
+
+// evtEvent is the part of an event's XML rendering the collector uses
+type evtEvent struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     uint32 `xml:"EventID"`
+		Level       uint8  `xml:"Level"`
+		Task        uint16 `xml:"Task"`
+		Keywords    string `xml:"Keywords"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID uint64 `xml:"EventRecordID"`
+		Channel       string `xml:"Channel"`
+		Computer      string `xml:"Computer"`
+	} `xml:"System"`
+	EventData struct {
+		Data []evtData `xml:"Data"`
+	} `xml:"EventData"`
+}
+
+// evtData is one EventData value, named for manifest-based providers
+type evtData struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// evtQueryString returns the configured XPath query, or all events
+func (ec *EventLogCollector) evtQueryString() string {
+	if ec.config.Query != "" {
+		return ec.config.Query
+	}
+	return "*"
+}
+
+// subscribe subscribes to events of a channel matching the query, after
+// the bookmark when resuming and from now on otherwise. signal is set
+// whenever new events are available.
+func (ec *EventLogCollector) subscribe(channel string, signal windows.Handle, bookmark evtHandle, resume bool) (evtHandle, error) {
+	if err := procEvtSubscribe.Find(); err != nil {
+		return 0, err
+	}
+
+	channelPtr, _ := syscall.UTF16PtrFromString(channel)
+	queryPtr, _ := syscall.UTF16PtrFromString(ec.evtQueryString())
+
+	flags := uintptr(evtSubscribeToFutureEvents)
+	if resume {
+		// Without the strict flag, a bookmark whose event was cleared from
+		// the log resumes at the oldest event left
+		flags = evtSubscribeStartAfterBookmark
+	} else {
+		bookmark = 0
+	}
+
+	h, _, err := procEvtSubscribe.Call(
+		0,
+		uintptr(signal),
+		uintptr(unsafe.Pointer(channelPtr)),
+		uintptr(unsafe.Pointer(queryPtr)),
+		uintptr(bookmark),
+		0,
+		0,
+		flags,
+	)
+	if h == 0 {
+		return 0, fmt.Errorf("EvtSubscribe failed: %v", err)
+	}
+	return evtHandle(h), nil
+}
+
+// followSubscription reads a channel's subscription as events arrive until
+// the context ends. Events up to record number after were already sent.
+// The bookmark, if any, follows the events read.
+func (ec *EventLogCollector) followSubscription(ctx context.Context, channel string, sub evtHandle, signal windows.Handle, bookmark evtHandle, after uint64) {
+	defer procEvtClose.Call(uintptr(sub))
+	defer windows.CloseHandle(signal)
+
+	for {
+		// Wake up regularly to notice cancellation
+		windows.WaitForSingleObject(signal, 1000)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reset before draining so events arriving meanwhile signal again
+		windows.ResetEvent(signal)
+
+		collected := 0
+		for {
+			events, err := evtNext(sub, 64)
+			for _, h := range events {
+				if ec.processEvtEvent(channel, h, after) {
+					collected++
+				}
+				ec.updateBookmark(bookmark, h)
+				procEvtClose.Call(uintptr(h))
+			}
+			if len(events) > 0 {
+				ec.saveBookmark(channel, bookmark)
+			}
+			if err != nil || len(events) == 0 {
+				break
+			}
+		}
+
+		if collected > 0 {
+			ec.logger().Info("Collected events", "channel", channel, "count", collected)
+		}
+	}
+}
+
+// backfillEvt ships the most recent count events of a channel matching the
+// query, oldest first, moving the bookmark past them. It returns the newest
+// record number shipped.
+func (ec *EventLogCollector) backfillEvt(channel string, count int, bookmark evtHandle) (uint64, error) {
+	if err := procEvtQuery.Find(); err != nil {
+		return 0, err
+	}
+
+	channelPtr, _ := syscall.UTF16PtrFromString(channel)
+	queryPtr, _ := syscall.UTF16PtrFromString(ec.evtQueryString())
+
+	h, _, err := procEvtQuery.Call(
+		0,
+		uintptr(unsafe.Pointer(channelPtr)),
+		uintptr(unsafe.Pointer(queryPtr)),
+		evtQueryChannelPath|evtQueryReverseDirection,
+	)
+	if h == 0 {
+		return 0, fmt.Errorf("EvtQuery failed: %v", err)
+	}
+	defer procEvtClose.Call(h)
+
+	// Results come newest first
+	var events []evtHandle
+	for len(events) < count {
+		batch, _ := evtNext(evtHandle(h), min(count-len(events), 64))
+		if len(batch) == 0 {
+			break
+		}
+		events = append(events, batch...)
+	}
+
+	var newest uint64
+	for i := len(events) - 1; i >= 0; i-- {
+		if i == 0 {
+			newest = evtRecordID(events[i])
+		}
+		ec.processEvtEvent(channel, events[i], 0)
+		ec.updateBookmark(bookmark, events[i])
+		procEvtClose.Call(uintptr(events[i]))
+	}
+	if len(events) > 0 {
+		ec.saveBookmark(channel, bookmark)
+	}
+
+	if len(events) > 0 {
+		ec.logger().Info("Backfilled events", "channel", channel, "count", len(events))
+	}
+	return newest, nil
+}
+
+// checkEvtChannel opens a query on a channel, which fails when the channel
+// doesn't exist, can't be read or the XPath query is invalid
+func (ec *EventLogCollector) checkEvtChannel(channel string) error {
+	if err := procEvtQuery.Find(); err != nil {
+		return err
+	}
+
+	channelPtr, _ := syscall.UTF16PtrFromString(channel)
+	queryPtr, _ := syscall.UTF16PtrFromString(ec.evtQueryString())
+
+	h, _, err := procEvtQuery.Call(
+		0,
+		uintptr(unsafe.Pointer(channelPtr)),
+		uintptr(unsafe.Pointer(queryPtr)),
+		evtQueryChannelPath,
+	)
+	if h == 0 {
+		return fmt.Errorf("EvtQuery failed: %v", err)
+	}
+	procEvtClose.Call(h)
+	return nil
+}
+
+// evtNext returns up to size events from a subscription or query without
+// waiting. The error is nil when no events are left.
+func evtNext(results evtHandle, size int) ([]evtHandle, error) {
+	handles := make([]evtHandle, size)
+	var returned uint32
+
+	ok, _, err := procEvtNext.Call(
+		uintptr(results),
+		uintptr(size),
+		uintptr(unsafe.Pointer(&handles[0])),
+		0,
+		0,
+		uintptr(unsafe.Pointer(&returned)),
+	)
+	if ok == 0 {
+		if err == windows.ERROR_NO_MORE_ITEMS {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return handles[:returned], nil
+}
+
+// renderEvtXML renders an event, or a bookmark with evtRenderBookmark, as XML
+func renderEvtXML(h evtHandle, flags uintptr) (string, error) {
+	var used, props uint32
+	procEvtRender.Call(0, uintptr(h), flags, 0, 0,
+		uintptr(unsafe.Pointer(&used)), uintptr(unsafe.Pointer(&props)))
+	if used == 0 {
+		return "", fmt.Errorf("EvtRender returned no data")
+	}
+
+	buf := make([]uint16, (used+1)/2)
+	ok, _, err := procEvtRender.Call(0, uintptr(h), flags,
+		uintptr(len(buf)*2), uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(unsafe.Pointer(&used)), uintptr(unsafe.Pointer(&props)))
+	if ok == 0 {
+		return "", fmt.Errorf("EvtRender failed: %v", err)
+	}
+	return syscall.UTF16ToString(buf), nil
+}
+
+// newEvtBookmark creates a bookmark from its rendered XML, or an empty one
+func newEvtBookmark(rendered string) (evtHandle, error) {
+	if err := procEvtCreateBookmark.Find(); err != nil {
+		return 0, err
+	}
+
+	var xmlPtr *uint16
+	if rendered != "" {
+		xmlPtr, _ = syscall.UTF16PtrFromString(rendered)
+	}
+	h, _, err := procEvtCreateBookmark.Call(uintptr(unsafe.Pointer(xmlPtr)))
+	if h == 0 {
+		return 0, fmt.Errorf("EvtCreateBookmark failed: %v", err)
+	}
+	return evtHandle(h), nil
+}
+
+// seedBookmark points a new bookmark at the newest event of a channel, so
+// a restart before any new event arrives still resumes from this run's
+// starting point
+func (ec *EventLogCollector) seedBookmark(channel string, bookmark evtHandle) {
+	channelPtr, _ := syscall.UTF16PtrFromString(channel)
+	queryPtr, _ := syscall.UTF16PtrFromString(ec.evtQueryString())
+
+	h, _, _ := procEvtQuery.Call(
+		0,
+		uintptr(unsafe.Pointer(channelPtr)),
+		uintptr(unsafe.Pointer(queryPtr)),
+		evtQueryChannelPath|evtQueryReverseDirection,
+	)
+	if h == 0 {
+		return
+	}
+	defer procEvtClose.Call(h)
+
+	events, _ := evtNext(evtHandle(h), 1)
+	for _, event := range events {
+		ec.updateBookmark(bookmark, event)
+		procEvtClose.Call(uintptr(event))
+	}
+	if len(events) > 0 {
+		ec.saveBookmark(channel, bookmark)
+	}
+}
+
+// updateBookmark moves a bookmark to an event; a zero bookmark is ignored
+func (ec *EventLogCollector) updateBookmark(bookmark, event evtHandle) {
+	if bookmark != 0 {
+		procEvtUpdateBookmark.Call(uintptr(bookmark), uintptr(event))
+	}
+}
+
+// saveBookmark records a channel's bookmark for the next checkpoint save
+func (ec *EventLogCollector) saveBookmark(channel string, bookmark evtHandle) {
+	if bookmark == 0 || ec.checkpoints == nil {
+		return
+	}
+	if rendered, err := renderEvtXML(bookmark, evtRenderBookmark); err == nil {
+		ec.checkpoints.setBookmark(channel, rendered)
+	}
+}
+
+// parseEvtEvent renders an event and parses the rendering
+func parseEvtEvent(event evtHandle) (evtEvent, error) {
+	var e evtEvent
+	text, err := renderEvtXML(event, evtRenderEventXml)
+	if err != nil {
+		return e, err
+	}
+	if err := xml.Unmarshal([]byte(text), &e); err != nil {
+		return e, fmt.Errorf("invalid event XML: %w", err)
+	}
+	return e, nil
+}
+
+// evtRecordID returns an event's record number, or 0 if it can't be read
+func evtRecordID(event evtHandle) uint64 {
+	e, err := parseEvtEvent(event)
+	if err != nil {
+		return 0
+	}
+	return e.System.EventRecordID
+}
+
+// processEvtEvent renders and sends one event from the Event Log API,
+// unless its record number is at most after. It reports whether the event
+// was sent.
+func (ec *EventLogCollector) processEvtEvent(channel string, event evtHandle, after uint64) bool {
+	e, err := parseEvtEvent(event)
+	if err != nil {
+		ec.mu.Lock()
+		ec.errorsCount++
+		ec.mu.Unlock()
+		logVerbose("Error reading event from %s: %v", channel, err)
+		return false
+	}
+	if e.System.EventRecordID <= after {
+		return false
+	}
+
+	if e.System.Channel != "" {
+		channel = e.System.Channel
+	}
+
+	service := ec.config.Service
+	if service == "" {
+		service = channel
+	}
+
+	// Values are named by manifest-based providers, positional otherwise
+	var values []string
+	data := make(map[string]any, len(e.EventData.Data))
+	for i, d := range e.EventData.Data {
+		value := strings.TrimSpace(d.Value)
+		name := d.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		data[name] = value
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	// Render the message, or fall back to the insertion strings
+	message := ec.formatEvtMessage(e.System.Provider.Name, event)
+	if message == "" {
+		message = strings.Join(values, " | ")
+	}
+
+	entry := createLogEntry(
+		evtLevel(e.System.Level, e.System.Keywords),
+		message,
+		service,
+		fmt.Sprintf("eventlog:%s", channel),
+		map[string]string{
+			"channel":  channel,
+			"event_id": fmt.Sprintf("%d", e.System.EventID),
+			"category": fmt.Sprintf("%d", e.System.Task),
+		},
+	)
+	if t, err := time.Parse(time.RFC3339Nano, e.System.TimeCreated.SystemTime); err == nil {
+		entry.Timestamp = t
+	}
+
+	entry.Metadata = map[string]any{
+		"record_number": e.System.EventRecordID,
+		"event_id":      e.System.EventID,
+		"level":         e.System.Level,
+		"category":      e.System.Task,
+		"provider":      e.System.Provider.Name,
+		"computer":      e.System.Computer,
+	}
+	if len(data) > 0 {
+		entry.Metadata["event_data"] = data
+	}
+
+	if err := ec.send(entry); err != nil {
+		ec.mu.Lock()
+		ec.errorsCount++
+		ec.mu.Unlock()
+		return false
+	}
+
+	ec.mu.Lock()
+	ec.logsCollected++
+	ec.lastCollected = time.Now()
+	ec.mu.Unlock()
+	return true
+}
+
+// evtLevel converts an event's level, and audit keywords for Security
+// events which carry none, to a log level
+func evtLevel(level uint8, keywords string) string {
+	switch level {
+	case 1: // Critical
+		return "FATAL"
+	case 2: // Error
+		return "ERROR"
+	case 3: // Warning
+		return "WARN"
+	case 5: // Verbose
+		return "DEBUG"
+	case 4: // Information
+		return "INFO"
+	}
+
+	if k, err := strconv.ParseUint(strings.TrimPrefix(keywords, "0x"), 16, 64); err == nil && k&evtKeywordAuditFailure != 0 {
+		return "ERROR"
+	}
+	return "INFO"
+}
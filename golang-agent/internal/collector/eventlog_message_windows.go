@@ -0,0 +1,195 @@
+//go:build windows
+// +build windows
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var (
+	procEvtOpenPublisherMetadata = wevtapi.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = wevtapi.NewProc("EvtFormatMessage")
+)
+
+const (
+	evtFormatMessageEvent = 1
+
+	// Returned with a usable message when some inserts couldn't be resolved
+	errorEvtUnresolvedValueInsert = syscall.Errno(15029)
+
+	// FormatMessage reads past the insertion strings it's given when a
+	// message has more inserts, so the argument array is padded to this
+	maxMessageInserts = 99
+)
+
+// publisherCache holds opened message sources per publisher, including
+// failed lookups so they aren't retried for every event
+type publisherCache struct {
+	evt    map[string]evtHandle      // Publisher metadata by provider name
+	legacy map[string]windows.Handle // Message file modules by channel\source
+}
+
+// formatEvtMessage renders an event's message from its publisher's
+// message table, or returns "" when it isn't available
+func (ec *EventLogCollector) formatEvtMessage(provider string, event evtHandle) string {
+	if provider == "" {
+		return ""
+	}
+	publisher := ec.evtPublisher(provider)
+	if publisher == 0 {
+		return ""
+	}
+
+	var used uint32
+	procEvtFormatMessage.Call(uintptr(publisher), uintptr(event), 0, 0, 0, evtFormatMessageEvent,
+		0, 0, uintptr(unsafe.Pointer(&used)))
+	if used == 0 {
+		return ""
+	}
+
+	buf := make([]uint16, used)
+	ok, _, err := procEvtFormatMessage.Call(uintptr(publisher), uintptr(event), 0, 0, 0, evtFormatMessageEvent,
+		uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+	if ok == 0 && err != errorEvtUnresolvedValueInsert {
+		return ""
+	}
+	return strings.TrimSpace(syscall.UTF16ToString(buf))
+}
+
+// evtPublisher returns the cached metadata handle of a publisher, opening
+// it on first use. It is 0 if the publisher has no metadata here.
+func (ec *EventLogCollector) evtPublisher(provider string) evtHandle {
+	ec.pubMu.Lock()
+	defer ec.pubMu.Unlock()
+
+	if h, ok := ec.publishers.evt[provider]; ok {
+		return h
+	}
+
+	var h evtHandle
+	if err := procEvtOpenPublisherMetadata.Find(); err == nil {
+		providerPtr, _ := syscall.UTF16PtrFromString(provider)
+		r, _, err := procEvtOpenPublisherMetadata.Call(0, uintptr(unsafe.Pointer(providerPtr)), 0, 0, 0)
+		if r == 0 {
+			logVerbose("No publisher metadata for %s: %v", provider, err)
+		}
+		h = evtHandle(r)
+	}
+	ec.publishers.evt[provider] = h
+	return h
+}
+
+// formatLegacyMessage renders a legacy record's message from the message
+// files registered for its source, or returns "" when none has it
+func (ec *EventLogCollector) formatLegacyMessage(channel, source string, eventID uint32, inserts []string) string {
+	if source == "" {
+		return ""
+	}
+	module := ec.legacyMessageModule(channel, source)
+	if module == 0 {
+		return ""
+	}
+
+	empty, _ := syscall.UTF16PtrFromString("")
+	args := make([]uintptr, max(len(inserts), maxMessageInserts))
+	for i := range args {
+		args[i] = uintptr(unsafe.Pointer(empty))
+		if i < len(inserts) {
+			if p, err := syscall.UTF16PtrFromString(inserts[i]); err == nil {
+				args[i] = uintptr(unsafe.Pointer(p))
+			}
+		}
+	}
+
+	buf := make([]uint16, 32*1024)
+	n, err := windows.FormatMessage(
+		windows.FORMAT_MESSAGE_FROM_HMODULE|windows.FORMAT_MESSAGE_ARGUMENT_ARRAY,
+		uintptr(module), eventID, 0, buf, (*byte)(unsafe.Pointer(&args[0])))
+	if err != nil || n == 0 {
+		return ""
+	}
+	return strings.TrimSpace(syscall.UTF16ToString(buf[:n]))
+}
+
+// legacyMessageModule returns the cached message file of an event source,
+// loading it on first use from the source's EventMessageFile registration.
+// It is 0 if none can be loaded.
+func (ec *EventLogCollector) legacyMessageModule(channel, source string) windows.Handle {
+	key := channel + `\` + source
+
+	ec.pubMu.Lock()
+	defer ec.pubMu.Unlock()
+
+	if h, ok := ec.publishers.legacy[key]; ok {
+		return h
+	}
+
+	var module windows.Handle
+	for _, file := range messageFiles(channel, source) {
+		h, err := windows.LoadLibraryEx(file, 0,
+			windows.LOAD_LIBRARY_AS_DATAFILE|windows.LOAD_LIBRARY_AS_IMAGE_RESOURCE)
+		if err == nil {
+			module = h
+			break
+		}
+		logVerbose("Can't load message file %s for %s: %v", file, key, err)
+	}
+	ec.publishers.legacy[key] = module
+	return module
+}
+
+// messageFiles returns the message files registered for an event source.
+// Several files may be listed, separated by semicolons.
+func messageFiles(channel, source string) []string {
+	path := fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\EventLog\%s\%s`, channel, source)
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+	if err != nil {
+		return nil
+	}
+	defer k.Close()
+
+	value, _, err := k.GetStringValue("EventMessageFile")
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, file := range strings.Split(value, ";") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		if expanded, err := registry.ExpandString(file); err == nil {
+			file = expanded
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// closePublishers releases the cached message sources
+func (ec *EventLogCollector) closePublishers() {
+	ec.pubMu.Lock()
+	defer ec.pubMu.Unlock()
+
+	for _, h := range ec.publishers.evt {
+		if h != 0 {
+			procEvtClose.Call(uintptr(h))
+		}
+	}
+	for _, h := range ec.publishers.legacy {
+		if h != 0 {
+			windows.FreeLibrary(h)
+		}
+	}
+	ec.publishers = publisherCache{
+		evt:    make(map[string]evtHandle),
+		legacy: make(map[string]windows.Handle),
+	}
+}
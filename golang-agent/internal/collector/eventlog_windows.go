@@ -6,7 +6,7 @@ package collector
 import (
 	"context"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
 	"sync"
 	"syscall"
@@ -14,14 +14,17 @@ import (
 	"unsafe"
 
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 
 	"golang.org/x/sys/windows"
 )
 
+// logVerbose logs a debug message, formatted only when debug is on
 func logVerbose(format string, args ...interface{}) {
-	if os.Getenv("LOGCHAT_VERBOSE") == "1" || os.Getenv("LOGCHAT_DEBUG") == "1" {
-		fmt.Printf("[eventlog] "+format+"\n", args...)
+	if l := logging.For("eventlog"); l.Enabled(context.Background(), slog.LevelDebug) {
+		l.Debug(fmt.Sprintf(format, args...))
 	}
 }
 
@@ -68,6 +71,11 @@ type EventLogCollector struct {
 	config         config.EventLogCollectorConfig
 	handles        map[string]windows.Handle
 	lastRecordNums map[string]uint32
+
+	checkpoints *eventlogCheckpoints // Nil unless checkpoint_dir is set
+
+	pubMu      sync.Mutex
+	publishers publisherCache // Message sources for rendering messages
 }
 
 // NewEventLogCollector creates a new Windows Event Log collector
@@ -76,10 +84,20 @@ func NewEventLogCollector(cfg config.EventLogCollectorConfig, snd *sender.Sender
 		BaseCollector: BaseCollector{
 			name:   "eventlog",
 			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
 		},
 		config:         cfg,
 		handles:        make(map[string]windows.Handle),
 		lastRecordNums: make(map[string]uint32),
+		publishers: publisherCache{
+			evt:    make(map[string]evtHandle),
+			legacy: make(map[string]windows.Handle),
+		},
 	}
 }
 
@@ -94,30 +112,55 @@ func (ec *EventLogCollector) Start(ctx context.Context) {
 	ec.running = true
 	ec.mu.Unlock()
 
-	channels := ec.config.Channels
-	if len(channels) == 0 {
-		channels = []string{"Application", "System", "Security"}
-	}
+	channels := ec.channels()
+	ec.logger().Info("Starting Windows Event Log collector", "channels", channels)
 
-	fmt.Printf("  [eventlog] Starting Windows Event Log collector for: %v\n", channels)
+	ec.checkpoints = newEventlogCheckpoints(ec.config.CheckpointDir, channels)
+	if ec.checkpoints != nil {
+		go ec.saveCheckpoints(ctx)
+		defer ec.checkpoints.save()
+	}
 
-	// Open event logs
+	// Subscribe through the Event Log API, which reads any channel and
+	// honors the XPath query. Channels it can't subscribe to fall back to
+	// the legacy reader, which only knows the classic logs.
+	var wg sync.WaitGroup
 	for _, channel := range channels {
+		if ec.startSubscription(ctx, &wg, channel) {
+			continue
+		}
+
 		handle, err := ec.openEventLog(channel)
 		if err != nil {
-			fmt.Printf("  [eventlog] Error opening %s: %v\n", channel, err)
+			ec.logger().Error("Error opening channel", "channel", channel, "error", err)
 			continue
 		}
+		ec.mu.Lock()
 		ec.handles[channel] = handle
+		ec.mu.Unlock()
 
 		// Get current record number to start from
 		var oldest, total uint32
 		procGetOldestEventLogRecord.Call(uintptr(handle), uintptr(unsafe.Pointer(&oldest)))
 		procGetNumberOfEventLogRecords.Call(uintptr(handle), uintptr(unsafe.Pointer(&total)))
 		ec.lastRecordNums[channel] = oldest + total
+
+		// Resume after the saved record unless the log was cleared and
+		// its numbering restarted below it
+		if cp, ok := ec.checkpoints.get(channel); ok && cp.Record > 0 && cp.Record < oldest+total {
+			ec.logger().Info("Resuming channel", "channel", channel, "after_record", cp.Record)
+			ec.lastRecordNums[channel] = cp.Record
+		} else if ec.config.BackfillCount > 0 && total > 0 {
+			// The newest existing record is oldest+total-1; the forward
+			// reader skips everything up to it, so nothing is read twice.
+			newest := oldest + total - 1
+			ec.backfill(channel, newest, ec.config.BackfillCount)
+			ec.lastRecordNums[channel] = newest
+		}
+		ec.checkpoints.setRecord(channel, ec.lastRecordNums[channel])
 	}
 
-	// Poll for new events
+	// Poll the legacy readers for new events
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -125,17 +168,128 @@ func (ec *EventLogCollector) Start(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			ec.Stop()
+			wg.Wait()
+			ec.closePublishers()
 			return
 
 		case <-ticker.C:
+			ec.mu.RLock()
+			handles := make(map[string]windows.Handle, len(ec.handles))
 			for channel, handle := range ec.handles {
+				handles[channel] = handle
+			}
+			ec.mu.RUnlock()
+
+			for channel, handle := range handles {
 				ec.readEvents(channel, handle)
 			}
 		}
 	}
 }
 
+// startSubscription follows a channel through the Event Log API in a new
+// goroutine, resuming after its saved bookmark or else backfilling it
+// first. It reports false if the channel can't be subscribed to.
+func (ec *EventLogCollector) startSubscription(ctx context.Context, wg *sync.WaitGroup, channel string) bool {
+	signal, err := windows.CreateEvent(nil, 1, 1, nil)
+	if err != nil {
+		return false
+	}
+
+	var bookmark evtHandle
+	var saved string
+	if ec.checkpoints != nil {
+		if cp, ok := ec.checkpoints.get(channel); ok {
+			saved = cp.Bookmark
+		}
+		if bookmark, err = newEvtBookmark(saved); err != nil && saved != "" {
+			ec.logger().Warn("Saved bookmark is invalid, starting from now", "channel", channel, "error", err)
+			saved = ""
+			bookmark, err = newEvtBookmark("")
+		}
+		if err != nil {
+			bookmark = 0
+		}
+	}
+
+	sub, err := ec.subscribe(channel, signal, bookmark, saved != "")
+	if err != nil {
+		windows.CloseHandle(signal)
+		if bookmark != 0 {
+			procEvtClose.Call(uintptr(bookmark))
+		}
+		ec.logger().Warn("Subscribing failed, using the legacy reader", "channel", channel, "error", err)
+		return false
+	}
+
+	// Events written since subscribing are in both the backfill and the
+	// subscription; the subscription skips them by record number
+	var backfilled uint64
+	switch {
+	case saved != "":
+		ec.logger().Info("Resuming channel after its bookmark", "channel", channel)
+	case ec.config.BackfillCount > 0:
+		if backfilled, err = ec.backfillEvt(channel, ec.config.BackfillCount, bookmark); err != nil {
+			logVerbose("Backfill of %s failed: %v", channel, err)
+		}
+	case bookmark != 0:
+		ec.seedBookmark(channel, bookmark)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if bookmark != 0 {
+			defer procEvtClose.Call(uintptr(bookmark))
+		}
+		ec.followSubscription(ctx, channel, sub, signal, bookmark, backfilled)
+	}()
+	return true
+}
+
+// saveCheckpoints flushes channel positions to disk periodically
+func (ec *EventLogCollector) saveCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ec.checkpoints.save(); err != nil {
+				ec.logger().Error("Failed to save checkpoints", "error", err)
+			}
+		}
+	}
+}
+
 // openEventLog opens an event log channel
+// channels returns the configured channels, or the classic logs
+func (ec *EventLogCollector) channels() []string {
+	if len(ec.config.Channels) == 0 {
+		return []string{"Application", "System", "Security"}
+	}
+	return ec.config.Channels
+}
+
+// check verifies every channel can be opened, by the Event Log API or the
+// legacy reader
+func (ec *EventLogCollector) check() (string, error) {
+	channels := ec.channels()
+	for _, channel := range channels {
+		if ec.checkEvtChannel(channel) == nil {
+			continue
+		}
+		handle, err := ec.openEventLog(channel)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", channel, err)
+		}
+		procCloseEventLog.Call(uintptr(handle))
+	}
+	return fmt.Sprintf("%d channels readable", len(channels)), nil
+}
+
 func (ec *EventLogCollector) openEventLog(channel string) (windows.Handle, error) {
 	channelPtr, _ := syscall.UTF16PtrFromString(channel)
 
@@ -195,7 +349,58 @@ func (ec *EventLogCollector) readEvents(channel string, handle windows.Handle) {
 	}
 
 	if eventsProcessed > 0 {
-		fmt.Printf("  [eventlog] Collected %d events from %s\n", eventsProcessed, channel)
+		ec.logger().Info("Collected events", "channel", channel, "count", eventsProcessed)
+		ec.checkpoints.setRecord(channel, ec.lastRecordNums[channel])
+	}
+}
+
+// backfill ships the most recent count events up to newest, oldest first
+func (ec *EventLogCollector) backfill(channel string, newest uint32, count int) {
+	handle, err := ec.openEventLog(channel)
+	if err != nil {
+		ec.logger().Error("Error opening channel for backfill", "channel", channel, "error", err)
+		return
+	}
+	defer procCloseEventLog.Call(uintptr(handle))
+
+	buffer := make([]byte, 64*1024)
+	var records [][]byte
+
+	for len(records) < count {
+		var bytesRead, minBytes uint32
+		ret, _, _ := procReadEventLogW.Call(
+			uintptr(handle),
+			uintptr(EVENTLOG_SEQUENTIAL_READ|EVENTLOG_BACKWARDS_READ),
+			0,
+			uintptr(unsafe.Pointer(&buffer[0])),
+			uintptr(len(buffer)),
+			uintptr(unsafe.Pointer(&bytesRead)),
+			uintptr(unsafe.Pointer(&minBytes)),
+		)
+		if ret == 0 {
+			break
+		}
+
+		for offset := uint32(0); offset < bytesRead && len(records) < count; {
+			record := (*EVENTLOGRECORD)(unsafe.Pointer(&buffer[offset]))
+			// Events written since startup belong to the forward reader
+			if record.RecordNumber <= newest {
+				data := make([]byte, record.Length)
+				copy(data, buffer[offset:offset+record.Length])
+				records = append(records, data)
+			}
+			offset += record.Length
+		}
+	}
+
+	// Records were read newest first
+	for i := len(records) - 1; i >= 0; i-- {
+		record := (*EVENTLOGRECORD)(unsafe.Pointer(&records[i][0]))
+		ec.processEvent(channel, record, records[i])
+	}
+
+	if len(records) > 0 {
+		ec.logger().Info("Backfilled events", "channel", channel, "count", len(records))
 	}
 }
 
@@ -204,8 +409,8 @@ func (ec *EventLogCollector) processEvent(channel string, record *EVENTLOGRECORD
 	// Convert event type to level
 	level := eventTypeToLevel(record.EventType)
 
-	// Extract message strings
-	message := ec.extractMessage(record, data)
+	// Render the message, or fall back to the insertion strings
+	message := ec.extractMessage(channel, record, data)
 
 	// Convert timestamp
 	ts := time.Unix(int64(record.TimeGenerated), 0)
@@ -235,7 +440,7 @@ func (ec *EventLogCollector) processEvent(channel string, record *EVENTLOGRECORD
 		"category":      record.EventCategory,
 	}
 
-	if err := ec.sender.Send(entry); err != nil {
+	if err := ec.send(entry); err != nil {
 		ec.mu.Lock()
 		ec.errorsCount++
 		ec.mu.Unlock()
@@ -248,47 +453,67 @@ func (ec *EventLogCollector) processEvent(channel string, record *EVENTLOGRECORD
 	ec.mu.Unlock()
 }
 
-// extractMessage extracts the message from the event record
-func (ec *EventLogCollector) extractMessage(record *EVENTLOGRECORD, data []byte) string {
+// extractMessage renders the record's message from its source's message
+// file. Without one it joins the insertion strings; records without strings
+// yield an empty message, handled by the agent's empty_message policy.
+func (ec *EventLogCollector) extractMessage(channel string, record *EVENTLOGRECORD, data []byte) string {
+	inserts := insertionStrings(record, data)
+	if message := ec.formatLegacyMessage(channel, recordSource(data), record.EventID, inserts); message != "" {
+		return message
+	}
+
+	var messages []string
+	for _, str := range inserts {
+		if str != "" {
+			messages = append(messages, str)
+		}
+	}
+	return strings.Join(messages, " | ")
+}
+
+// insertionStrings returns the record's insertion strings, in order
+func insertionStrings(record *EVENTLOGRECORD, data []byte) []string {
 	if record.NumStrings == 0 {
-		return fmt.Sprintf("Event ID: %d", record.EventID&0xFFFF)
+		return nil
 	}
 
 	// Strings start at StringOffset
 	stringStart := record.StringOffset
 	if stringStart >= uint32(len(data)) {
-		return fmt.Sprintf("Event ID: %d", record.EventID&0xFFFF)
+		return nil
 	}
 
-	var messages []string
+	var inserts []string
 	offset := stringStart
 
 	for i := uint16(0); i < record.NumStrings && offset < uint32(len(data)); i++ {
-		// Find null terminator (UTF-16)
-		end := offset
-		for end+1 < uint32(len(data)) {
-			if data[end] == 0 && data[end+1] == 0 {
-				break
-			}
-			end += 2
-		}
-
-		if end > offset {
-			// Convert UTF-16 to string
-			str := utf16ToString(data[offset:end])
-			if str != "" {
-				messages = append(messages, str)
-			}
-		}
-
+		end := utf16End(data, offset)
+		inserts = append(inserts, utf16ToString(data[offset:end]))
 		offset = end + 2
 	}
 
-	if len(messages) == 0 {
-		return fmt.Sprintf("Event ID: %d", record.EventID&0xFFFF)
+	return inserts
+}
+
+// recordSource returns the source name that follows the fixed record header
+func recordSource(data []byte) string {
+	start := uint32(unsafe.Sizeof(EVENTLOGRECORD{}))
+	if start >= uint32(len(data)) {
+		return ""
 	}
+	return utf16ToString(data[start:utf16End(data, start)])
+}
 
-	return strings.Join(messages, " | ")
+// utf16End finds the null terminator of the UTF-16 string at offset
+func utf16End(data []byte, offset uint32) uint32 {
+	end := offset
+	for end+1 < uint32(len(data)) {
+		if data[end] == 0 && data[end+1] == 0 {
+			break
+		}
+		end += 2
+	}
+	return end
 }
 
 // utf16ToString converts UTF-16 bytes to string
@@ -340,23 +565,25 @@ func (ec *EventLogCollector) Stats() map[string]any {
 	defer ec.mu.RUnlock()
 
 	return map[string]any{
-		"name":           ec.name,
-		"logs_collected": ec.logsCollected,
-		"errors_count":   ec.errorsCount,
-		"last_collected": ec.lastCollected,
-		"running":        ec.running,
-		"channels":       ec.config.Channels,
+		"name":              ec.name,
+		"logs_collected":    ec.logsCollected,
+		"errors_count":      ec.errorsCount,
+		"last_collected":    ec.lastCollected,
+		"running":           ec.running,
+		"channels":          ec.config.Channels,
+		"rate_limited":      ec.limiter.Dropped(),
+		"repeats_collapsed": ec.repeats.collapsedCount(),
 	}
 }
 
-// InitializeWindows adds Windows-specific collectors
-func InitializeWindows(cfg config.CollectorsConfig, snd *sender.Sender) []Collector {
-	var collectors []Collector
+// windowsSpecs lists the Windows-specific collectors
+func windowsSpecs(cfg config.CollectorsConfig, snd *sender.Sender) []collectorSpec {
+	var specs []collectorSpec
 
 	// Add event log collector
 	if cfg.EventLog != nil && cfg.EventLog.Enabled {
-		collectors = append(collectors, NewEventLogCollector(*cfg.EventLog, snd))
+		specs = append(specs, newSpec("eventlog", *cfg.EventLog, func() Collector { return NewEventLogCollector(*cfg.EventLog, snd) }))
 	}
 
-	return collectors
+	return specs
 }
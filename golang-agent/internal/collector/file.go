@@ -2,17 +2,27 @@ package collector
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"logchat/agent/internal/buffer"
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 
 	"github.com/nxadm/tail"
@@ -23,11 +33,38 @@ type FileCollector struct {
 	BaseCollector
 	mu sync.RWMutex
 
-	config   config.FileCollectorConfig
-	tails    map[string]*tail.Tail
-	patterns []*regexp.Regexp
-	excludes []*regexp.Regexp
-	parser   *regexp.Regexp
+	config     config.FileCollectorConfig
+	tails      map[string]bool     // Paths with an open tail
+	tailing    map[string]bool     // Paths with a running tailer, open or not
+	read       map[string]bool     // Rotated and compressed files read to the end
+	csvHeaders map[string][]string // Header row per file for the csv parser
+
+	// Files waiting for a slot under max_open_files, true for ones to read
+	// once rather than tail, and whether the cap warnings were logged since
+	// the count last dropped
+	deferred      map[string]bool
+	nearCapWarned bool
+	capWarned     bool
+
+	// Release signals of tailed paths, closed once a re-scan no longer
+	// finds the path, so its tail ends without waiting out the grace period
+	released map[string]chan struct{}
+
+	timestampErrors int64 // atomic; timestamp fields that failed to parse
+	patterns        []*regexp.Regexp
+	excludes        []*regexp.Regexp
+	parser          *regexp.Regexp
+	joiner          *regexp.Regexp // Multiline pattern
+	changes         *changeTracker // Set in on_change mode
+
+	checkpoints *checkpointStore // Nil unless checkpoint_dir is set
+
+	// cancel ends discovery and every tail started by Start
+	cancel context.CancelFunc
+
+	// enrich, when set, adds fields to each entry before it's sent, for
+	// collectors built on this one
+	enrich func(filePath string, entry *buffer.LogEntry)
 }
 
 // NewFileCollector creates a new file collector
@@ -36,23 +73,33 @@ func NewFileCollector(cfg config.FileCollectorConfig, snd *sender.Sender) *FileC
 		BaseCollector: BaseCollector{
 			name:   fmt.Sprintf("file:%s", cfg.Service),
 			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
 		},
-		config: cfg,
-		tails:  make(map[string]*tail.Tail),
+		config:     cfg,
+		tails:      make(map[string]bool),
+		tailing:    make(map[string]bool),
+		read:       make(map[string]bool),
+		csvHeaders: make(map[string][]string),
+		deferred:   make(map[string]bool),
+		released:   make(map[string]chan struct{}),
 	}
 
-	// Compile patterns
+	// Compile patterns, one per path (nil if invalid)
 	for _, path := range cfg.Paths {
-		if pattern, err := regexp.Compile(globToRegex(path)); err == nil {
-			fc.patterns = append(fc.patterns, pattern)
-		}
+		pattern, _ := regexp.Compile(globToRegex(filepath.ToSlash(path)))
+		fc.patterns = append(fc.patterns, pattern)
 	}
 
-	// Compile excludes
+	// Compile excludes, one per pattern (nil if invalid)
 	for _, excl := range cfg.Exclude {
-		if pattern, err := regexp.Compile(globToRegex(excl)); err == nil {
-			fc.excludes = append(fc.excludes, pattern)
-		}
+		pattern, _ := regexp.Compile(globToRegex(filepath.ToSlash(excl)))
+		fc.excludes = append(fc.excludes, pattern)
 	}
 
 	// Compile parser regex
@@ -62,6 +109,20 @@ func NewFileCollector(cfg config.FileCollectorConfig, snd *sender.Sender) *FileC
 		}
 	}
 
+	if cfg.Multiline != nil && cfg.Multiline.Pattern != "" {
+		if pattern, err := regexp.Compile(cfg.Multiline.Pattern); err == nil {
+			fc.joiner = pattern
+		} else {
+			fc.logger().Error("Invalid multiline pattern", "error", err)
+		}
+	}
+
+	if cfg.OnChange != nil {
+		fc.changes = newChangeTracker(cfg.OnChange.Field)
+	}
+
+	fc.checkpoints = newCheckpointStore(cfg.CheckpointDir, fc.name, cfg.CheckpointReportInterval > 0)
+
 	return fc
 }
 
@@ -72,36 +133,205 @@ func (fc *FileCollector) Name() string {
 
 // Start starts the file collector
 func (fc *FileCollector) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	fc.mu.Lock()
 	fc.running = true
+	fc.cancel = cancel
 	fc.mu.Unlock()
 
 	// Find files matching patterns
 	files := fc.findFiles()
-	fmt.Printf("  [%s] Found %d files to monitor\n", fc.name, len(files))
+	fc.logger().Info("Found files to monitor", "count", len(files))
+
+	if fc.changes != nil {
+		fc.watchChanges(ctx, files)
+		return
+	}
+
+	if fc.checkpoints != nil {
+		fc.checkpoints.load()
+		go fc.saveCheckpoints(ctx)
+		defer fc.checkpoints.save()
+	}
+	if fc.config.CheckpointReportInterval > 0 {
+		go fc.reportCheckpoints(ctx)
+	}
 
 	// Start tailing each file
 	var wg sync.WaitGroup
 	for _, file := range files {
-		wg.Add(1)
-		go func(filePath string) {
-			defer wg.Done()
-			fc.tailFile(ctx, filePath)
-		}(file)
+		fc.startTail(ctx, &wg, file, false)
 	}
+	if fc.config.IncludeRotated {
+		for _, file := range fc.findRotated(files) {
+			fc.startRead(ctx, &wg, file)
+		}
+	}
+
+	fc.discoverFiles(ctx, &wg)
 
 	// Wait for all tailers to finish
 	wg.Wait()
 }
 
+// discoverFiles re-scans the paths until the context ends and tails files
+// that appeared since the last scan. Tails of existing files are left
+// alone, and those of files that disappeared are released.
+func (fc *FileCollector) discoverFiles(ctx context.Context, wg *sync.WaitGroup) {
+	ticker := time.NewTicker(fc.discoveryInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			files := fc.findFiles()
+			fc.releaseMissing(files)
+			for _, file := range files {
+				fc.startTail(ctx, wg, file, true)
+			}
+			for _, file := range fc.deferredReads() {
+				fc.startRead(ctx, wg, file)
+			}
+		}
+	}
+}
+
+// releaseMissing signals the tails of paths missing from files. Each reads
+// what is left of its file and ends without the deleted-file grace period.
+func (fc *FileCollector) releaseMissing(files []string) {
+	found := make(map[string]bool, len(files))
+	for _, file := range files {
+		found[file] = true
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for path, release := range fc.released {
+		if !found[path] {
+			close(release)
+			delete(fc.released, path)
+		}
+	}
+}
+
+// startTail tails filePath in a new goroutine unless it is already tailed.
+// Compressed files don't grow, so they are read once instead.
+func (fc *FileCollector) startTail(ctx context.Context, wg *sync.WaitGroup, filePath string, discovered bool) {
+	if isCompressed(filePath) {
+		fc.startRead(ctx, wg, filePath)
+		return
+	}
+
+	fc.mu.Lock()
+	if fc.tailing[filePath] || !fc.reserveSlot(filePath, false) {
+		fc.mu.Unlock()
+		return
+	}
+	fc.tailing[filePath] = true
+	release := make(chan struct{})
+	fc.released[filePath] = release
+	// A file that waited for a slot isn't new, it was found at startup
+	if _, ok := fc.deferred[filePath]; ok {
+		delete(fc.deferred, filePath)
+		discovered = false
+	}
+	fc.mu.Unlock()
+
+	if discovered {
+		fc.logger().Info("Discovered file", "path", filePath)
+	}
+	location := fc.startLocation(filePath, discovered)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer fc.releaseSlot(filePath)
+		fc.tailFile(ctx, filePath, location, release)
+	}()
+}
+
+// reserveSlot reports whether filePath may be opened under max_open_files,
+// deferring it otherwise, and warns as the cap nears. Called with fc.mu held.
+func (fc *FileCollector) reserveSlot(filePath string, readOnce bool) bool {
+	limit := fc.config.MaxOpenFiles
+	if limit <= 0 {
+		return true
+	}
+
+	open := len(fc.tailing)
+	if open >= limit {
+		if _, ok := fc.deferred[filePath]; !ok {
+			fc.deferred[filePath] = readOnce
+			if !fc.capWarned {
+				fc.capWarned = true
+				fc.logger().Warn("Reached max_open_files, deferring more files until tails end", "max_open_files", limit)
+			}
+		}
+		return false
+	}
+	if (open+1)*10 >= limit*9 && !fc.nearCapWarned {
+		fc.nearCapWarned = true
+		fc.logger().Warn("Nearing max_open_files", "open", open+1, "max_open_files", limit)
+	}
+	return true
+}
+
+// releaseSlot marks filePath as no longer open, re-arming the cap
+// warnings once well below it
+func (fc *FileCollector) releaseSlot(filePath string) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	delete(fc.tailing, filePath)
+	delete(fc.released, filePath)
+	if len(fc.tailing)*10 < fc.config.MaxOpenFiles*8 {
+		fc.nearCapWarned, fc.capWarned = false, false
+	}
+}
+
+// deferredReads returns the read-once files waiting for a slot, such as
+// rotated copies found at startup, which re-scans don't find again
+func (fc *FileCollector) deferredReads() []string {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	var files []string
+	for file, readOnce := range fc.deferred {
+		if readOnce {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// tooManyOpenFiles reports whether err means the process or system is out
+// of file descriptors
+func tooManyOpenFiles(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) ||
+		strings.Contains(err.Error(), "too many open files")
+}
+
+// discoveryInterval is how often paths are re-scanned for new files
+func (fc *FileCollector) discoveryInterval() time.Duration {
+	if fc.config.DiscoveryInterval > 0 {
+		return fc.config.DiscoveryInterval
+	}
+	return 30 * time.Second
+}
+
 // Stop stops the file collector
 func (fc *FileCollector) Stop() {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 
 	fc.running = false
-	for _, t := range fc.tails {
-		t.Stop()
+	if fc.cancel != nil {
+		fc.cancel()
 	}
 }
 
@@ -111,21 +341,59 @@ func (fc *FileCollector) Stats() map[string]any {
 	defer fc.mu.RUnlock()
 
 	return map[string]any{
-		"name":           fc.name,
-		"logs_collected": fc.logsCollected,
-		"errors_count":   fc.errorsCount,
-		"last_collected": fc.lastCollected,
-		"files_watched":  len(fc.tails),
-		"running":        fc.running,
+		"name":              fc.name,
+		"logs_collected":    fc.logsCollected,
+		"errors_count":      fc.errorsCount,
+		"logs_filtered":     atomic.LoadInt64(&fc.logsFiltered),
+		"rate_limited":      fc.limiter.Dropped(),
+		"repeats_collapsed": fc.repeats.collapsedCount(),
+		"timestamp_errors":  atomic.LoadInt64(&fc.timestampErrors),
+		"last_collected":    fc.lastCollected,
+		"files_watched":     len(fc.tails),
+		"files_open":        len(fc.tailing),
+		"files_deferred":    len(fc.deferred),
+		"max_open_files":    fc.config.MaxOpenFiles,
+		"running":           fc.running,
 	}
 }
 
-// findFiles finds all files matching the configured patterns
+// findFiles finds all files matching the configured patterns. Paths with
+// "**", or any path when recursive is set, are matched at any depth.
 func (fc *FileCollector) findFiles() []string {
 	var files []string
 	seen := make(map[string]bool)
 
-	for _, path := range fc.config.Paths {
+	add := func(match string) {
+		if !fc.isExcluded(match) && !seen[match] {
+			seen[match] = true
+			files = append(files, match)
+		}
+	}
+
+	for i, path := range fc.config.Paths {
+		if strings.Contains(path, "**") {
+			if fc.patterns[i] != nil {
+				fc.walkFiles(globRoot(path), func(p string) bool {
+					return fc.patterns[i].MatchString(filepath.ToSlash(p))
+				}, add)
+			}
+			continue
+		}
+
+		if fc.config.Recursive {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				fc.walkFiles(path, func(string) bool { return true }, add)
+			} else {
+				// Match the file name part at any depth below the pattern's directory
+				base := filepath.Base(path)
+				fc.walkFiles(globRoot(path), func(p string) bool {
+					ok, _ := filepath.Match(base, filepath.Base(p))
+					return ok
+				}, add)
+			}
+			continue
+		}
+
 		matches, err := filepath.Glob(path)
 		if err != nil {
 			continue
@@ -137,74 +405,595 @@ func (fc *FileCollector) findFiles() []string {
 			if err != nil || info.IsDir() {
 				continue
 			}
+			add(match)
+		}
+	}
 
-			// Check excludes
-			excluded := false
-			for _, excl := range fc.excludes {
-				if excl.MatchString(match) {
-					excluded = true
-					break
-				}
+	return files
+}
+
+// isExcluded checks a path against the exclude patterns. A pattern with a
+// "/" matches the whole path, one without matches the file name.
+func (fc *FileCollector) isExcluded(path string) bool {
+	path = filepath.ToSlash(path)
+	base := filepath.Base(path)
+	for i, excl := range fc.excludes {
+		if excl == nil {
+			continue
+		}
+		target := base
+		if strings.Contains(filepath.ToSlash(fc.config.Exclude[i]), "/") {
+			target = path
+		}
+		if excl.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFiles calls add for every regular file under root accepted by match.
+// Unreadable directories are skipped.
+func (fc *FileCollector) walkFiles(root string, match func(string) bool, add func(string)) {
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && p != root {
+				return fs.SkipDir
 			}
+			return nil
+		}
+		if d.Type().IsRegular() && match(p) {
+			add(p)
+		}
+		return nil
+	})
+}
+
+// globRoot returns the directory before the first wildcard of a pattern
+func globRoot(pattern string) string {
+	idx := strings.IndexAny(pattern, "*?[")
+	if idx < 0 {
+		return filepath.Dir(pattern)
+	}
+	return filepath.Dir(pattern[:idx+1])
+}
+
+// tailResult is how one tail of a file ended
+type tailResult int
+
+const (
+	tailStopped  tailResult = iota // Context done or the tail failed
+	tailReleased                   // Gone for longer than the grace period
+	tailMoved                      // Moved or deleted; may be recreated
+	tailRotated                    // Replaced; read the new file from the start
+)
+
+// rotationCheckInterval is how often a tailed path is checked for being
+// moved or replaced
+const rotationCheckInterval = time.Second
 
-			if !excluded && !seen[match] {
-				seen[match] = true
-				files = append(files, match)
+// tailFile tails a single file until the context ends or the file is
+// deleted and not recreated within the grace period, or before release is
+// closed. A rotated file is followed to its replacement. A released file's
+// position is forgotten; if it is recreated, discovery tails it again.
+func (fc *FileCollector) tailFile(ctx context.Context, filePath string, location *tail.SeekInfo, release <-chan struct{}) {
+	for {
+		result := fc.tailOnce(ctx, filePath, location)
+		if result == tailMoved {
+			result = fc.awaitReplacement(ctx, filePath, release)
+		}
+
+		switch result {
+		case tailRotated:
+			location = &tail.SeekInfo{Offset: 0, Whence: io.SeekStart}
+		case tailReleased:
+			if fc.checkpoints != nil {
+				fc.checkpoints.remove(filePath)
 			}
+			fc.mu.Lock()
+			delete(fc.csvHeaders, filePath)
+			fc.mu.Unlock()
+			return
+		default:
+			return
 		}
 	}
+}
 
-	return files
+// awaitReplacement waits for a moved or deleted file to be recreated,
+// releasing it once the grace period passes or release is closed
+func (fc *FileCollector) awaitReplacement(ctx context.Context, filePath string, release <-chan struct{}) tailResult {
+	grace := fc.deletedGracePeriod()
+	ticker := time.NewTicker(min(grace, rotationCheckInterval))
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(grace)
+	for {
+		if ctx.Err() != nil {
+			return tailStopped
+		}
+		if _, err := os.Stat(filePath); err == nil {
+			fc.logger().Info("File was rotated, reading the new file", "path", filePath)
+			return tailRotated
+		}
+		if time.Now().After(deadline) {
+			fc.logger().Info("File deleted, releasing it", "path", filePath, "grace", grace)
+			return tailReleased
+		}
+
+		select {
+		case <-ctx.Done():
+			return tailStopped
+		case <-release:
+			fc.logger().Info("File no longer found, releasing it", "path", filePath)
+			return tailReleased
+		case <-ticker.C:
+		}
+	}
 }
 
-// tailFile tails a single file
-func (fc *FileCollector) tailFile(ctx context.Context, filePath string) {
-	t, err := tail.TailFile(filePath, tail.Config{
-		Follow:    true,
-		ReOpen:    true,
-		MustExist: false,
-		Location:  &tail.SeekInfo{Offset: 0, Whence: 2}, // Start at end
-		Logger:    tail.DiscardingLogger,
-	})
+// headSize is how much of the start of a file is compared to detect it
+// being truncated and rewritten
+const headSize = 64
+
+// readHead returns up to headSize bytes from the start of f
+func readHead(f *os.File) []byte {
+	buf := make([]byte, headSize)
+	n, _ := f.ReadAt(buf, 0)
+	return buf[:n]
+}
+
+// deletedGracePeriod is how long a deleted file is waited for before its
+// tail is released
+func (fc *FileCollector) deletedGracePeriod() time.Duration {
+	if fc.config.DeletedGracePeriod > 0 {
+		return fc.config.DeletedGracePeriod
+	}
+	return 5 * time.Minute
+}
+
+// startLocation decides where tailing begins. A file with a checkpoint for
+// the same inode resumes at the saved offset, or from the start if it shrank
+// below it. A different inode means the file was rotated while the agent was
+// down, so it is read from the start. Files never seen before start at the
+// end unless read_from is "beginning", and ones discovered after startup
+// always start at the beginning: all of their content is new.
+func (fc *FileCollector) startLocation(filePath string, discovered bool) *tail.SeekInfo {
+	unseen := &tail.SeekInfo{Offset: 0, Whence: 2}
+	if discovered || fc.config.ReadFrom == "beginning" {
+		unseen = &tail.SeekInfo{Offset: 0, Whence: 0}
+	}
+	if fc.checkpoints == nil {
+		return unseen
+	}
+
+	cp, ok := fc.checkpoints.get(filePath)
+	if !ok {
+		return unseen
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return unseen
+	}
+
+	if inode := fileInode(info); inode != cp.Inode {
+		fc.logger().Info("File was replaced since the last run, reading from the start", "path", filePath)
+		return &tail.SeekInfo{Offset: 0, Whence: 0}
+	}
+	if cp.Offset > info.Size() {
+		fc.logger().Info("File was truncated below its checkpoint, reading from the start",
+			"path", filePath, "checkpoint", cp.Offset, "size", info.Size())
+		return &tail.SeekInfo{Offset: 0, Whence: 0}
+	}
+
+	fc.logger().Info("Resuming file", "path", filePath, "offset", cp.Offset)
+	return &tail.SeekInfo{Offset: cp.Offset, Whence: 0}
+}
+
+// saveCheckpoints flushes file offsets to disk periodically
+func (fc *FileCollector) saveCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Save right away: the agent may exit before the tailers finish
+			fc.checkpoints.save()
+			return
+		case <-ticker.C:
+			if err := fc.checkpoints.save(); err != nil {
+				fc.logger().Error("Failed to save checkpoints", "error", err)
+			}
+		}
+	}
+}
+
+// reportCheckpoints periodically emits each tailed file's position and how
+// far it lags behind the file's size, so a stuck or backpressured tailer
+// shows up centrally
+func (fc *FileCollector) reportCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(fc.config.CheckpointReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fc.mu.RLock()
+			paths := make([]string, 0, len(fc.tails))
+			for path := range fc.tails {
+				paths = append(paths, path)
+			}
+			fc.mu.RUnlock()
+			sort.Strings(paths)
+
+			for _, path := range paths {
+				fc.reportCheckpoint(path)
+			}
+		}
+	}
+}
+
+// reportCheckpoint emits the checkpoint entry for one file
+func (fc *FileCollector) reportCheckpoint(filePath string) {
+	cp, ok := fc.checkpoints.get(filePath)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(filePath)
 	if err != nil {
-		fmt.Printf("  [%s] Error tailing %s: %v\n", fc.name, filePath, err)
 		return
 	}
 
+	// After rotation the offset belongs to the old file, so all of the
+	// current file is still unread
+	lag := info.Size() - cp.Offset
+	if fileInode(info) != cp.Inode {
+		lag = info.Size()
+	}
+	lag = max(lag, 0)
+
+	entry := createLogEntry("INFO",
+		fmt.Sprintf("Checkpoint %s: offset %d of %d (lag %d bytes)", filePath, cp.Offset, info.Size(), lag),
+		"logchat-agent", "logchat-agent",
+		map[string]string{"report": "checkpoint", "collector": fc.name})
+	entry.Metadata = map[string]any{
+		"file_path": filePath,
+		"offset":    cp.Offset,
+		"file_size": info.Size(),
+		"lag_bytes": lag,
+	}
+	fc.sender.Send(entry)
+}
+
+// pollInterval is how often a tailed file is checked for new lines
+const pollInterval = 250 * time.Millisecond
+
+// tailOnce tails filePath until the context ends, the file is rotated, or
+// it is moved or deleted. Lines are read from a handle held on the file, so
+// lines written to it after it was renamed are still read before the
+// rotation is reported.
+func (fc *FileCollector) tailOnce(ctx context.Context, filePath string, location *tail.SeekInfo) tailResult {
+	held, err := openShared(filePath)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return tailMoved
+		case tooManyOpenFiles(err):
+			fc.mu.Lock()
+			fc.errorsCount++
+			fc.mu.Unlock()
+			fc.logger().Error("Out of file descriptors, skipping file until the next scan; raise the fd limit or set max_open_files", "path", filePath)
+		default:
+			fc.logger().Error("Error tailing file", "path", filePath, "error", err)
+		}
+		return tailStopped
+	}
+	defer held.Close()
+
+	heldInfo, err := held.Stat()
+	if err != nil {
+		fc.logger().Error("Error tailing file", "path", filePath, "error", err)
+		return tailStopped
+	}
+	inode := fileInode(heldInfo)
+
 	fc.mu.Lock()
-	fc.tails[filePath] = t
+	fc.tails[filePath] = true
 	fc.mu.Unlock()
 
 	defer func() {
 		fc.mu.Lock()
 		delete(fc.tails, filePath)
 		fc.mu.Unlock()
-		t.Stop()
 	}()
 
-	for {
-		select {
-		case <-ctx.Done():
+	// A file truncated and refilled past lastEnd between reads is told
+	// apart from one that only grew by its first bytes changing. They also
+	// hold the byte order mark, if any.
+	head := readHead(held)
+	decoder := newLineDecoder(fc.config.Encoding, head)
+
+	checkTicker := time.NewTicker(min(fc.deletedGracePeriod(), rotationCheckInterval))
+	defer checkTicker.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	// Multiline state is per file so concurrent tails never interleave
+	joined := newMultiline(fc.config.Multiline, fc.joiner)
+	partials := newCRIPartials(fc.config.Parser)
+	idle := time.NewTimer(time.Hour)
+	idle.Stop()
+	defer idle.Stop()
+
+	// lastEnd is the offset just past the last line read. The checkpoint is
+	// the start of the oldest line not yet emitted, so a pending multiline
+	// entry is re-read after a restart rather than lost.
+	var lastEnd int64
+	checkpoint := func() {
+		if fc.checkpoints == nil {
 			return
+		}
+		pos := lastEnd
+		if partials != nil {
+			if offset, ok := partials.pending(); ok {
+				pos = offset
+			}
+		}
+		if joined != nil && joined.pending() {
+			pos = joined.offset
+		}
+		fc.checkpoints.set(filePath, inode, pos)
+	}
+
+	// Record the starting point too, so lines written to a file that stays
+	// quiet until the next restart aren't skipped by starting at its end again
+	if location.Whence == io.SeekEnd {
+		lastEnd = heldInfo.Size()
+	} else {
+		lastEnd = location.Offset
+	}
+	checkpoint()
+
+	flushJoined := func() {
+		if text, offset := joined.flush(); text != "" {
+			fc.processLine(filePath, text, offset)
+		}
+		checkpoint()
+	}
+	if joined != nil {
+		// Don't lose a partial entry when the tail ends
+		defer flushJoined()
+	}
+
+	// handleLine emits one line, with its newline if any, that started at
+	// offset and ended at end
+	handleLine := func(line string, offset, end int64) {
+		lastEnd = end
+		text := decoder.decode(line)
 
-		case line, ok := <-t.Lines:
-			if !ok {
+		if partials != nil {
+			var ok bool
+			if text, offset, ok = partials.add(text, offset); !ok {
+				checkpoint()
 				return
 			}
-			if line.Err != nil {
-				fc.mu.Lock()
-				fc.errorsCount++
-				fc.mu.Unlock()
+		}
+
+		if joined == nil {
+			fc.processLine(filePath, text, offset)
+			checkpoint()
+			return
+		}
+
+		if text, start, ok := joined.add(text, offset); ok {
+			fc.processLine(filePath, text, start)
+		}
+		checkpoint()
+		if joined.pending() {
+			idle.Reset(fc.multilineTimeout())
+		}
+	}
+
+	// readLines emits the complete lines written since the last read and
+	// returns how many there were. A file shorter than what was read, or
+	// whose first bytes changed, was truncated and is read from the start;
+	// checking before every read keeps lines written after the truncation
+	// from being read from the old offset.
+	readLines := func() int {
+		info, err := held.Stat()
+		if err != nil {
+			return 0
+		}
+		current := readHead(held)
+		if info.Size() < lastEnd || !bytes.HasPrefix(current, head) {
+			fc.logger().Info("File was truncated, reading from the start",
+				"path", filePath, "offset", lastEnd, "size", info.Size())
+			if joined != nil {
+				flushJoined()
+			}
+			partials = newCRIPartials(fc.config.Parser)
+			lastEnd = 0
+		}
+		head = current
+
+		// A file read from its start, including one written since the tail
+		// started, may begin with a byte order mark
+		if lastEnd == 0 {
+			decoder = newLineDecoder(fc.config.Encoding, head)
+		}
+
+		// An unterminated last line is left for a later read, once the
+		// writer has finished it
+		lines := 0
+		reader := bufio.NewReader(io.NewSectionReader(held, lastEnd, info.Size()-lastEnd))
+		for ctx.Err() == nil {
+			line, err := decoder.readLine(reader)
+			if err != nil {
+				break
+			}
+			handleLine(line, lastEnd, lastEnd+int64(len(line)))
+			lines++
+		}
+		return lines
+	}
+
+	// readRest reads a moved or deleted file to its end. The writer may not
+	// have reopened its log yet, so reading continues until the file stops
+	// growing; then an unterminated last line is emitted as is.
+	readRest := func() bool {
+		lines := 0
+		for {
+			lines += readLines()
+			info, err := held.Stat()
+			if err != nil {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(rotationCheckInterval):
+			}
+			if after, err := held.Stat(); err != nil || after.Size() == info.Size() {
+				break
+			}
+		}
+
+		if info, err := held.Stat(); err == nil && info.Size() > lastEnd {
+			partial := make([]byte, info.Size()-lastEnd)
+			if n, _ := held.ReadAt(partial, lastEnd); n > 0 {
+				handleLine(string(partial[:n]), lastEnd, lastEnd+int64(n))
+				lines++
+			}
+		}
+		if lines > 0 {
+			fc.logger().Info("Read lines written before rotation", "path", filePath, "lines", lines)
+		}
+		return true
+	}
+
+	for {
+		readLines()
+
+		select {
+		case <-ctx.Done():
+			return tailStopped
+
+		case <-idle.C:
+			flushJoined()
+
+		case <-poll.C:
+
+		case <-checkTicker.C:
+			// A missing path or a new file at it means a rotation; the held
+			// file is read to its end first
+			info, err := os.Stat(filePath)
+			moved := errors.Is(err, os.ErrNotExist)
+			rotated := err == nil && !os.SameFile(info, heldInfo)
+			if !moved && !rotated {
 				continue
 			}
+			if !readRest() {
+				return tailStopped
+			}
+			if moved {
+				return tailMoved
+			}
+			fc.logger().Info("File was rotated, reading the new file", "path", filePath)
+			return tailRotated
+		}
+	}
+}
+
+// watchChanges polls whole files and emits an entry when the tracked value changes
+func (fc *FileCollector) watchChanges(ctx context.Context, files []string) {
+	interval := fc.config.OnChange.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	scanned := time.Now()
+
+	for {
+		for _, filePath := range files {
+			fc.checkChange(filePath)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
-			fc.processLine(filePath, line.Text)
+		if time.Since(scanned) >= fc.discoveryInterval() {
+			files = fc.findFiles()
+			scanned = time.Now()
 		}
 	}
 }
 
-// processLine processes a single log line
-func (fc *FileCollector) processLine(filePath, text string) {
+// checkChange reads a file and emits an entry if its tracked value changed
+func (fc *FileCollector) checkChange(filePath string) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		fc.mu.Lock()
+		fc.errorsCount++
+		fc.mu.Unlock()
+		return
+	}
+
+	value, ok := fc.changes.extract(string(data))
+	if !ok {
+		return
+	}
+
+	old, changed := fc.changes.update(filePath, value)
+	if !changed {
+		return
+	}
+
+	entry := createLogEntry(
+		"INFO",
+		fc.changes.changeMessage(filePath, old, value),
+		fc.config.Service,
+		filePath,
+		fc.config.Tags,
+	)
+	entry.Metadata = map[string]any{
+		"field":     fc.config.OnChange.Field,
+		"old_value": old,
+		"new_value": value,
+	}
+
+	if err := fc.send(entry); err != nil {
+		fc.mu.Lock()
+		fc.errorsCount++
+		fc.mu.Unlock()
+		return
+	}
+
+	fc.mu.Lock()
+	fc.logsCollected++
+	fc.lastCollected = time.Now()
+	fc.mu.Unlock()
+}
+
+// multilineTimeout is how long a pending multiline entry may sit idle
+func (fc *FileCollector) multilineTimeout() time.Duration {
+	if fc.config.Multiline.Timeout > 0 {
+		return fc.config.Multiline.Timeout
+	}
+	return 2 * time.Second
+}
+
+// processLine processes a single log line. offset is the byte position of
+// the line in the file, or -1 when unknown.
+func (fc *FileCollector) processLine(filePath, text string, offset int64) {
 	if text == "" {
 		return
 	}
@@ -223,9 +1012,33 @@ func (fc *FileCollector) processLine(filePath, text string) {
 		fc.parseJSON(text, &entry)
 	case "regex":
 		fc.parseRegex(text, &entry)
+	case "bracketed":
+		fc.parseBracketed(text, &entry)
+	case "logfmt":
+		fc.parseLogfmt(text, &entry)
+	case "csv":
+		if !fc.parseCSV(filePath, text, offset, &entry) {
+			return
+		}
+	case "cri":
+		fc.parseCRI(text, &entry)
+	case "docker-json":
+		fc.parseDockerJSON(text, &entry)
+	}
+
+	if fc.enrich != nil {
+		fc.enrich(filePath, &entry)
 	}
 
-	if err := fc.sender.Send(entry); err != nil {
+	if fc.config.IncludeOffset && offset >= 0 {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]any)
+		}
+		entry.Metadata["file_path"] = filePath
+		entry.Metadata["file_offset"] = offset
+	}
+
+	if err := fc.send(entry); err != nil {
 		fc.mu.Lock()
 		fc.errorsCount++
 		fc.mu.Unlock()
@@ -256,9 +1069,10 @@ func (fc *FileCollector) parseJSON(text string, entry *buffer.LogEntry) {
 	} else if msg, ok := data["msg"].(string); ok {
 		entry.Message = msg
 	}
-	if ts, ok := data["timestamp"].(string); ok {
-		if t, err := time.Parse(time.RFC3339, ts); err == nil {
-			entry.Timestamp = t
+	for _, key := range []string{"timestamp", "time", "ts"} {
+		if ts, ok := data[key]; ok {
+			fc.setTimestamp(entry, ts)
+			break
 		}
 	}
 }
@@ -288,9 +1102,7 @@ func (fc *FileCollector) parseRegex(text string, entry *buffer.LogEntry) {
 			case "message", "msg":
 				entry.Message = matches[i]
 			case "timestamp", "time":
-				if t, err := time.Parse(time.RFC3339, matches[i]); err == nil {
-					entry.Timestamp = t
-				}
+				fc.setTimestamp(entry, matches[i])
 			}
 		}
 	}
@@ -298,6 +1110,118 @@ func (fc *FileCollector) parseRegex(text string, entry *buffer.LogEntry) {
 	entry.Metadata = metadata
 }
 
+// parseBracketed parses lines with positional [...] prefixes, e.g.
+// "[2024-01-02 10:00:00] [ERROR] [module] message here"
+func (fc *FileCollector) parseBracketed(text string, entry *buffer.LogEntry) {
+	fields := fc.config.BracketFields
+	if len(fields) == 0 {
+		fields = []string{"timestamp", "level"}
+	}
+
+	values := make([]string, 0, len(fields))
+	rest := text
+	for range fields {
+		rest = strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(rest, "[") {
+			break
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			break
+		}
+		values = append(values, strings.TrimSpace(rest[1:end]))
+		rest = rest[end+1:]
+	}
+
+	// Prefix shape doesn't match: keep the line as plain text
+	if len(values) < len(fields) {
+		return
+	}
+
+	metadata := make(map[string]any)
+	for i, name := range fields {
+		value := values[i]
+		metadata[name] = value
+
+		switch name {
+		case "level":
+			entry.Level = strings.ToUpper(value)
+		case "timestamp", "time":
+			fc.setTimestamp(entry, value)
+		}
+	}
+
+	entry.Message = strings.TrimLeft(rest, " \t")
+	entry.Metadata = metadata
+}
+
+// timestampLayouts are the layouts tried when parsing free-form timestamps
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+}
+
+// setTimestamp sets the entry time from a parsed timestamp field. A value
+// that can't be parsed keeps the collection time and is counted.
+func (fc *FileCollector) setTimestamp(entry *buffer.LogEntry, value any) {
+	if t, ok := fc.parseTime(value); ok {
+		entry.Timestamp = t
+		return
+	}
+	atomic.AddInt64(&fc.timestampErrors, 1)
+}
+
+// parseTime parses a timestamp field with the time_format layouts, or the
+// known layouts when none are configured. JSON numbers are accepted for
+// the epoch formats.
+func (fc *FileCollector) parseTime(value any) (time.Time, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return time.Time{}, false
+	}
+
+	if len(fc.config.TimeFormat) == 0 {
+		return parseTimestamp(s)
+	}
+
+	for _, layout := range fc.config.TimeFormat {
+		switch layout {
+		case "unix", "unix_ms":
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			if layout == "unix_ms" {
+				return time.UnixMicro(int64(n * 1e3)), true
+			}
+			return time.UnixMicro(int64(n * 1e6)), true
+		default:
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimestamp parses a timestamp using the known layouts
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
 // globToRegex converts a glob pattern to regex
 func globToRegex(glob string) string {
 	result := ""
@@ -305,7 +1229,11 @@ func globToRegex(glob string) string {
 		c := glob[i]
 		switch c {
 		case '*':
-			if i+1 < len(glob) && glob[i+1] == '*' {
+			if strings.HasPrefix(glob[i:], "**/") {
+				// "**/" matches zero or more directories
+				result += "(?:.*/)?"
+				i += 2
+			} else if i+1 < len(glob) && glob[i+1] == '*' {
 				result += ".*"
 				i++
 			} else {
@@ -342,3 +1270,19 @@ func ReadExistingLines(filePath string, maxLines int) ([]string, error) {
 
 	return lines, scanner.Err()
 }
+
+// check verifies the paths match at least one file that can be opened
+func (fc *FileCollector) check() (string, error) {
+	files := fc.findFiles()
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files match %s", strings.Join(fc.config.Paths, ", "))
+	}
+	for _, filePath := range files {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return "", err
+		}
+		f.Close()
+	}
+	return fmt.Sprintf("%d file(s) matched", len(files)), nil
+}
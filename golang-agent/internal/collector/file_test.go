@@ -0,0 +1,375 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/sender"
+)
+
+// newTestSender creates a sender that isn't started, so sent entries stay
+// in the returned memory buffer
+func newTestSender(t *testing.T) (*sender.Sender, buffer.Buffer) {
+	t.Helper()
+
+	buf, err := buffer.New(config.BufferConfig{Type: "memory", MaxItems: 100000, MaxSize: 1 << 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snd, err := sender.New(config.ServerConfig{URL: "http://127.0.0.1:0"}, config.AgentConfig{}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snd, buf
+}
+
+// newTestFileCollector creates a file collector whose entries stay in a
+// memory buffer, as the sender isn't started
+func newTestFileCollector(t *testing.T, cfg config.FileCollectorConfig) (*FileCollector, buffer.Buffer) {
+	t.Helper()
+
+	snd, buf := newTestSender(t)
+	cfg.Enabled = true
+	if cfg.ReadFrom == "" {
+		cfg.ReadFrom = "beginning"
+	}
+	return NewFileCollector(cfg, snd), buf
+}
+
+// startCollector runs c until the returned stop function is called
+func startCollector(t *testing.T, c Collector) (stop func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Start(ctx)
+	}()
+
+	stopped := false
+	stop = func() {
+		if !stopped {
+			stopped = true
+			cancel()
+			wg.Wait()
+		}
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// bufferedMessages returns the messages in buf, in order
+func bufferedMessages(t *testing.T, buf buffer.Buffer) []string {
+	t.Helper()
+
+	entries, err := buf.Peek(buf.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make([]string, len(entries))
+	for i, e := range entries {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+// waitForCount waits until buf holds n entries, failing after timeout
+func waitForCount(t *testing.T, buf buffer.Buffer, n int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for buf.Len() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out with %d of %d entries", buf.Len(), n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// writeLines appends lines formatted with format and index from..to-1
+func writeLines(t *testing.T, f *os.File, format string, from, to int) []string {
+	t.Helper()
+
+	var lines []string
+	for i := from; i < to; i++ {
+		line := fmt.Sprintf(format, i)
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// assertMessages checks that exactly want was delivered, once each
+func assertMessages(t *testing.T, got, want []string) {
+	t.Helper()
+
+	got = append([]string(nil), got...)
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if strings.Join(got, "\n") == strings.Join(want, "\n") {
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, m := range got {
+		counts[m]++
+	}
+	var missing, extra []string
+	for _, m := range want {
+		if counts[m] == 0 {
+			missing = append(missing, m)
+		}
+		counts[m]--
+	}
+	for m, n := range counts {
+		for ; n > 0; n-- {
+			extra = append(extra, m)
+		}
+	}
+	sort.Strings(extra)
+	t.Fatalf("delivered %d lines, want %d\nmissing (%d): %v\nextra (%d): %v",
+		len(got), len(want), len(missing), firstFew(missing), len(extra), firstFew(extra))
+}
+
+// firstFew shortens a list of lines for a failure message
+func firstFew(lines []string) []string {
+	if len(lines) > 10 {
+		return append(lines[:10:10], "...")
+	}
+	return lines
+}
+
+func TestFileCollectorCopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := writeLines(t, f, "old %03d", 0, 100)
+
+	fc, buf := newTestFileCollector(t, config.FileCollectorConfig{Paths: []string{path}})
+	stop := startCollector(t, fc)
+	waitForCount(t, buf, 100, 5*time.Second)
+
+	// copytruncate: the copy is taken, then the writer's file is emptied
+	// and refilled past the old read offset
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, writeLines(t, f, "new %03d "+strings.Repeat("x", 40), 0, 300)...)
+
+	waitForCount(t, buf, len(want), 10*time.Second)
+	time.Sleep(2 * rotationCheckInterval)
+	stop()
+	assertMessages(t, bufferedMessages(t, buf), want)
+}
+
+func TestFileCollectorRenameCreate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := writeLines(t, f, "first %03d", 0, 100)
+
+	fc, buf := newTestFileCollector(t, config.FileCollectorConfig{Paths: []string{path}})
+	stop := startCollector(t, fc)
+	waitForCount(t, buf, 100, 5*time.Second)
+
+	// The writer keeps appending to the renamed file until it reopens
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, writeLines(t, f, "late %03d", 0, 20)...)
+
+	g, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	want = append(want, writeLines(t, g, "second %03d", 0, 50)...)
+
+	waitForCount(t, buf, len(want), 10*time.Second)
+	time.Sleep(2 * rotationCheckInterval)
+	stop()
+	assertMessages(t, bufferedMessages(t, buf), want)
+}
+
+func TestFileCollectorStopWithoutCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	want := writeLines(t, f, "line %03d", 0, 50)
+
+	fc, buf := newTestFileCollector(t, config.FileCollectorConfig{
+		Paths:             []string{path},
+		DiscoveryInterval: 100 * time.Millisecond,
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fc.Start(context.Background())
+	}()
+	waitForCount(t, buf, len(want), 5*time.Second)
+
+	fc.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't return after Stop")
+	}
+
+	// Nothing is tailed again once stopped
+	writeLines(t, f, "after %03d", 0, 10)
+	time.Sleep(2 * rotationCheckInterval)
+	assertMessages(t, bufferedMessages(t, buf), want)
+}
+
+func TestFileCollectorReleasesVanishedFiles(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.log")
+	gone := filepath.Join(dir, "gone.log")
+
+	var want []string
+	for _, path := range []string{kept, gone} {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, writeLines(t, f, filepath.Base(path)+" %03d", 0, 20)...)
+		f.Close()
+	}
+
+	fc, buf := newTestFileCollector(t, config.FileCollectorConfig{
+		Paths:              []string{filepath.Join(dir, "*.log")},
+		DiscoveryInterval:  100 * time.Millisecond,
+		DeletedGracePeriod: time.Hour,
+	})
+	stop := startCollector(t, fc)
+	waitForCount(t, buf, len(want), 5*time.Second)
+
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tail ends well before the grace period
+	deadline := time.Now().Add(5 * time.Second)
+	for fc.Stats()["files_open"] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("files_open = %v, want 1", fc.Stats()["files_open"])
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stop()
+	assertMessages(t, bufferedMessages(t, buf), want)
+}
+
+func TestFindFilesNestedWithExclude(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"top.log",
+		"top.log.gz",
+		"app/a.log",
+		"app/a.txt",
+		"app/old/a.log.old",
+		"app/deep/er/b.log",
+		"app/cache/c.log",
+		"other/d.log",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("line\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.ToSlash(dir)
+
+	for _, tc := range []struct {
+		name      string
+		paths     []string
+		exclude   []string
+		recursive bool
+		want      []string
+	}{
+		{
+			name:  "flat glob",
+			paths: []string{root + "/*.log*"},
+			want:  []string{"top.log", "top.log.gz"},
+		},
+		{
+			name:    "flat glob, name exclude",
+			paths:   []string{root + "/*.log*"},
+			exclude: []string{"*.gz"},
+			want:    []string{"top.log"},
+		},
+		{
+			name:  "double star",
+			paths: []string{root + "/app/**/*.log"},
+			want:  []string{"app/a.log", "app/deep/er/b.log", "app/cache/c.log"},
+		},
+		{
+			name:    "double star, directory exclude",
+			paths:   []string{root + "/**/*.log"},
+			exclude: []string{"**/cache/**"},
+			want:    []string{"top.log", "app/a.log", "app/deep/er/b.log", "other/d.log"},
+		},
+		{
+			name:      "recursive directory",
+			paths:     []string{root + "/app"},
+			exclude:   []string{"*.old", "*.txt"},
+			recursive: true,
+			want:      []string{"app/a.log", "app/deep/er/b.log", "app/cache/c.log"},
+		},
+		{
+			name:      "recursive name pattern, path exclude",
+			paths:     []string{root + "/*.log"},
+			exclude:   []string{root + "/app/deep/**"},
+			recursive: true,
+			want:      []string{"top.log", "app/a.log", "app/cache/c.log", "other/d.log"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fc, _ := newTestFileCollector(t, config.FileCollectorConfig{
+				Paths:     tc.paths,
+				Exclude:   tc.exclude,
+				Recursive: tc.recursive,
+			})
+
+			var got []string
+			for _, f := range fc.findFiles() {
+				rel, err := filepath.Rel(dir, f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			assertMessages(t, got, tc.want)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readFrame reads one message from a stream using RFC 6587 framing: octet
+// counting ("MSG-LEN SP MSG") or non-transparent newline-delimited frames.
+// "auto" picks per frame, since an octet count starts with a digit and a
+// syslog message with "<". Messages over maxLen are truncated, reporting
+// truncated.
+func readFrame(r *bufio.Reader, framing string, maxLen int) ([]byte, bool, error) {
+	octet := false
+	switch strings.ToLower(framing) {
+	case "octet":
+		octet = true
+	case "newline":
+	default:
+		b, err := r.Peek(1)
+		if err != nil {
+			return nil, false, err
+		}
+		octet = b[0] >= '0' && b[0] <= '9'
+	}
+
+	if !octet {
+		return readLimitedLine(r, maxLen)
+	}
+
+	header, err := r.ReadString(' ')
+	if err != nil {
+		return nil, false, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || length < 0 || len(header) > 11 {
+		return nil, false, fmt.Errorf("invalid octet count %q", strings.TrimSpace(header))
+	}
+
+	truncated := false
+	keep := length
+	if keep > maxLen {
+		keep = maxLen
+		truncated = true
+	}
+
+	frame := make([]byte, keep)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, false, err
+	}
+	if truncated {
+		if _, err := r.Discard(length - keep); err != nil {
+			return frame, true, err
+		}
+	}
+	return bytes.TrimRight(frame, "\r\n"), truncated, nil
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// octetFramed frames each message as "MSG-LEN SP MSG"
+func octetFramed(messages ...string) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "%d %s", len(m), m)
+	}
+	return b.String()
+}
+
+// readFrames reads frames from stream until EOF
+func readFrames(t *testing.T, stream io.Reader, framing string, maxLen int) (frames []string, truncated int) {
+	t.Helper()
+
+	r := bufio.NewReaderSize(stream, 16)
+	for {
+		frame, cut, err := readFrame(r, framing, maxLen)
+		if cut {
+			truncated++
+		}
+		if len(frame) > 0 {
+			frames = append(frames, string(frame))
+		}
+		if err == io.EOF {
+			return frames, truncated
+		}
+		if err != nil {
+			t.Fatalf("readFrame: %v after %q", err, frames)
+		}
+	}
+}
+
+func TestReadFrameFragmentedStreams(t *testing.T) {
+	// Messages longer than the reader's buffer span several reads, and one
+	// holds a newline only octet counting can carry
+	messages := []string{
+		"<34>1 2024-01-02T03:04:05Z host app - - - first",
+		"<13>Jan  2 03:04:05 host app: second " + strings.Repeat("x", 40),
+		"<14>third",
+	}
+	multiline := "<14>1 - host app - - - line one\nline two"
+
+	for _, tc := range []struct {
+		name    string
+		framing string
+		stream  string
+		want    []string
+	}{
+		{"octet", "octet", octetFramed(append(messages, multiline)...), append(messages, multiline)},
+		{"newline", "newline", strings.Join(messages, "\n") + "\r\n", messages},
+		{"auto octet", "auto", octetFramed(messages...), messages},
+		{"auto newline", "", strings.Join(messages, "\n") + "\n", messages},
+		{"auto mixed", "auto", octetFramed(messages[0]) + messages[1] + "\n" + octetFramed(messages[2]), messages},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, split := range []struct {
+				name string
+				wrap func(io.Reader) io.Reader
+			}{
+				{"whole", func(r io.Reader) io.Reader { return r }},
+				{"one byte", iotest.OneByteReader},
+				{"halves", iotest.HalfReader},
+			} {
+				frames, truncated := readFrames(t, split.wrap(strings.NewReader(tc.stream)), tc.framing, 1024)
+				if truncated != 0 {
+					t.Errorf("%s: %d frames truncated", split.name, truncated)
+				}
+				if strings.Join(frames, "|") != strings.Join(tc.want, "|") {
+					t.Errorf("%s: frames = %q, want %q", split.name, frames, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFrameTruncatesAndResyncs(t *testing.T) {
+	long := "<14>" + strings.Repeat("y", 100)
+	for _, tc := range []struct {
+		framing string
+		stream  string
+	}{
+		{"octet", octetFramed(long, "<14>next")},
+		{"newline", long + "\n<14>next\n"},
+	} {
+		t.Run(tc.framing, func(t *testing.T) {
+			frames, truncated := readFrames(t, iotest.OneByteReader(strings.NewReader(tc.stream)), tc.framing, 32)
+			if truncated != 1 {
+				t.Errorf("truncated = %d, want 1", truncated)
+			}
+			want := []string{long[:32], "<14>next"}
+			if strings.Join(frames, "|") != strings.Join(want, "|") {
+				t.Errorf("frames = %q, want %q", frames, want)
+			}
+		})
+	}
+}
+
+func TestReadFrameBadOctetCount(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("12x <14>message"))
+	if _, _, err := readFrame(r, "octet", 1024); err == nil {
+		t.Fatal("invalid octet count accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/sender"
+)
+
+// HeartbeatCollector emits a small periodic entry so the server can tell a
+// quiet but healthy host from a dead agent
+type HeartbeatCollector struct {
+	BaseCollector
+	mu sync.RWMutex
+
+	config     config.HeartbeatConfig
+	collectors []Collector // The data collectors reported on
+	started    time.Time
+}
+
+// NewHeartbeatCollector creates a heartbeat reporting on the given collectors
+func NewHeartbeatCollector(cfg config.HeartbeatConfig, collectors []Collector, snd *sender.Sender) *HeartbeatCollector {
+	return &HeartbeatCollector{
+		BaseCollector: BaseCollector{
+			name:   "heartbeat",
+			sender: snd,
+			class:  cfg.Class,
+
+			fingerprint: configHash(cfg),
+		},
+		config:     cfg,
+		collectors: collectors,
+	}
+}
+
+// Name returns the collector name
+func (hc *HeartbeatCollector) Name() string {
+	return hc.name
+}
+
+// Start emits a heartbeat immediately and then every interval
+func (hc *HeartbeatCollector) Start(ctx context.Context) {
+	hc.mu.Lock()
+	hc.running = true
+	hc.started = time.Now()
+	hc.mu.Unlock()
+
+	interval := hc.config.Interval
+	if interval == 0 {
+		interval = 60 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	hc.beat()
+
+	for {
+		select {
+		case <-ctx.Done():
+			hc.mu.Lock()
+			hc.running = false
+			hc.mu.Unlock()
+			return
+
+		case <-ticker.C:
+			hc.beat()
+		}
+	}
+}
+
+// Stop stops the heartbeat
+func (hc *HeartbeatCollector) Stop() {
+	hc.mu.Lock()
+	hc.running = false
+	hc.mu.Unlock()
+}
+
+// Stats returns collector statistics
+func (hc *HeartbeatCollector) Stats() map[string]any {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	return map[string]any{
+		"name":           hc.name,
+		"logs_collected": hc.logsCollected,
+		"errors_count":   hc.errorsCount,
+		"last_collected": hc.lastCollected,
+		"running":        hc.running,
+	}
+}
+
+// setCollectors replaces the collectors reported on, after a reload
+func (hc *HeartbeatCollector) setCollectors(collectors []Collector) {
+	hc.mu.Lock()
+	hc.collectors = collectors
+	hc.mu.Unlock()
+}
+
+// beat sends one heartbeat entry
+func (hc *HeartbeatCollector) beat() {
+	hc.mu.RLock()
+	collectors := hc.collectors
+	uptime := time.Since(hc.started).Truncate(time.Second)
+	hc.mu.RUnlock()
+
+	var running int
+	var collected int64
+	for _, c := range collectors {
+		stats := c.Stats()
+		if r, _ := stats["running"].(bool); r {
+			running++
+		}
+		if n, ok := stats["logs_collected"].(int64); ok {
+			collected += n
+		}
+	}
+
+	entry := createLogEntry("INFO",
+		fmt.Sprintf("Heartbeat: up %v, %d/%d collectors running", uptime, running, len(collectors)),
+		"logchat-agent", "logchat-agent", hc.config.Tags)
+	entry.Tags["report"] = "heartbeat"
+	entry.Metadata = map[string]any{
+		"uptime_seconds":     int64(uptime.Seconds()),
+		"collectors":         len(collectors),
+		"collectors_running": running,
+		"logs_collected":     collected,
+	}
+
+	err := hc.send(entry)
+
+	hc.mu.Lock()
+	if err != nil {
+		hc.errorsCount++
+	} else {
+		hc.logsCollected++
+		hc.lastCollected = time.Now()
+	}
+	hc.mu.Unlock()
+}
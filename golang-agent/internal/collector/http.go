@@ -0,0 +1,357 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
+	"logchat/agent/internal/sender"
+)
+
+// HTTPCollector receives JSON log entries POSTed by local apps
+type HTTPCollector struct {
+	BaseCollector
+	mu sync.RWMutex
+
+	config  config.HTTPCollectorConfig
+	address string
+	path    string
+	maxBody int64
+}
+
+// NewHTTPCollector creates a new HTTP listener collector
+func NewHTTPCollector(cfg config.HTTPCollectorConfig, snd *sender.Sender) *HTTPCollector {
+	address := cfg.Address
+	if address == "" {
+		address = "127.0.0.1:9880"
+	}
+	path := cfg.Path
+	if path == "" {
+		path = "/logs"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	maxBody := cfg.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = 1024 * 1024
+	}
+	if cfg.Service == "" {
+		cfg.Service = "http"
+	}
+
+	return &HTTPCollector{
+		BaseCollector: BaseCollector{
+			name:   fmt.Sprintf("http:%s", address),
+			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
+		},
+		config:  cfg,
+		address: address,
+		path:    path,
+		maxBody: maxBody,
+	}
+}
+
+// Name returns the collector name
+func (hc *HTTPCollector) Name() string {
+	return hc.name
+}
+
+// Start listens for pushed logs until the context is cancelled
+func (hc *HTTPCollector) Start(ctx context.Context) {
+	ln, err := net.Listen("tcp", hc.address)
+	if err != nil {
+		hc.logger().Error("Failed to listen", "address", hc.address, "error", err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(hc.path, hc.handle)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	hc.mu.Lock()
+	hc.running = true
+	hc.mu.Unlock()
+
+	hc.logger().Info("Listening", "url", "http://"+ln.Addr().String()+hc.path)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			hc.logger().Error("Server error", "error", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Let requests in flight finish so their entries aren't lost
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		server.Shutdown(shutdownCtx)
+		cancel()
+		<-done
+	case <-done:
+	}
+
+	hc.mu.Lock()
+	hc.running = false
+	hc.mu.Unlock()
+}
+
+// handle accepts one JSON entry or an array of entries per request
+func (hc *HTTPCollector) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !hc.authorized(r) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, hc.maxBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("body exceeds %d bytes", hc.maxBody), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	records, err := decodeHTTPRecords(body)
+	if err != nil {
+		hc.mu.Lock()
+		hc.errorsCount++
+		hc.mu.Unlock()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	remote, _, _ := net.SplitHostPort(r.RemoteAddr)
+	accepted := 0
+	for _, record := range records {
+		entry := hc.createEntry(record, remote)
+		if err := hc.send(entry); err != nil {
+			hc.mu.Lock()
+			hc.errorsCount++
+			hc.mu.Unlock()
+			continue
+		}
+		accepted++
+	}
+
+	if accepted > 0 {
+		hc.mu.Lock()
+		hc.logsCollected += int64(accepted)
+		hc.lastCollected = time.Now()
+		hc.mu.Unlock()
+	}
+
+	status := http.StatusAccepted
+	if accepted < len(records) {
+		// The buffer refused some entries; tell the client to back off
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]int{
+		"accepted": accepted,
+		"rejected": len(records) - accepted,
+	})
+}
+
+// authorized checks the API key the way the server does: X-API-Key or a
+// Bearer token, plus the password of HTTP basic auth for clients that only
+// support that
+func (hc *HTTPCollector) authorized(r *http.Request) bool {
+	if hc.config.APIKey == "" {
+		return true
+	}
+
+	var keys []string
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		keys = append(keys, key)
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		keys = append(keys, strings.TrimPrefix(auth, "Bearer "))
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		keys = append(keys, password)
+	}
+
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(hc.config.APIKey)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeHTTPRecords parses a body holding one JSON object or an array of them
+func decodeHTTPRecords(body []byte) ([]map[string]any, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	if body[0] == '[' {
+		var records []map[string]any
+		if err := dec.Decode(&records); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return records, nil
+	}
+
+	var record map[string]any
+	if err := dec.Decode(&record); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %w", err)
+	}
+	return []map[string]any{record}, nil
+}
+
+// createEntry maps a pushed record onto a log entry. The usual fields fill
+// the entry; anything else is kept as metadata.
+func (hc *HTTPCollector) createEntry(record map[string]any, remote string) buffer.LogEntry {
+	message := httpString(record, "message", "msg")
+	service := httpString(record, "service")
+	if service == "" {
+		service = hc.config.Service
+	}
+
+	level := strings.ToUpper(httpString(record, "level", "severity"))
+	if level == "" {
+		level = parseLevel(message)
+	}
+
+	entry := createLogEntry(level, message, service, "http", hc.config.Tags)
+	entry.Metadata = make(map[string]any)
+	if remote != "" {
+		entry.Metadata["remote_addr"] = remote
+	}
+
+	for key, value := range record {
+		switch key {
+		case "message", "msg", "service", "level", "severity":
+		case "timestamp", "time", "ts":
+			if ts, ok := httpTimestamp(value); ok {
+				entry.Timestamp = ts
+			} else {
+				entry.Metadata[key] = value
+			}
+		case "tags":
+			if tags, ok := value.(map[string]any); ok {
+				for k, v := range tags {
+					entry.Tags[k] = fmt.Sprint(v)
+				}
+			}
+		case "metadata":
+			if meta, ok := value.(map[string]any); ok {
+				for k, v := range meta {
+					entry.Metadata[k] = v
+				}
+			}
+		default:
+			entry.Metadata[key] = value
+		}
+	}
+
+	return entry
+}
+
+// httpString returns the first of keys present as a string
+func httpString(record map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := record[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// httpTimestamp reads an RFC 3339 string or a Unix epoch in seconds or
+// milliseconds
+func httpTimestamp(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts, true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n > 1e12 {
+				return time.UnixMilli(n), true
+			}
+			return time.Unix(n, 0), true
+		}
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(0, int64(f*1e9)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Stop marks the collector stopped. The server itself is shut down by Start
+// when its context ends, which lets requests in flight finish.
+func (hc *HTTPCollector) Stop() {
+	hc.mu.Lock()
+	hc.running = false
+	hc.mu.Unlock()
+}
+
+// Stats returns collector statistics
+func (hc *HTTPCollector) Stats() map[string]any {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	return map[string]any{
+		"name":              hc.name,
+		"logs_collected":    hc.logsCollected,
+		"errors_count":      hc.errorsCount,
+		"logs_filtered":     atomic.LoadInt64(&hc.logsFiltered),
+		"rate_limited":      hc.limiter.Dropped(),
+		"repeats_collapsed": hc.repeats.collapsedCount(),
+		"last_collected":    hc.lastCollected,
+		"running":           hc.running,
+		"address":           hc.address,
+		"path":              hc.path,
+	}
+}
+
+// check verifies the listen address is free, releasing it right away
+func (hc *HTTPCollector) check() (string, error) {
+	ln, err := net.Listen("tcp", hc.address)
+	if err != nil {
+		return "", err
+	}
+	ln.Close()
+	return "can listen on " + hc.address, nil
+}
@@ -12,27 +12,66 @@ func init() {
 	// Register Windows-specific collector initializer
 }
 
-// Initialize creates collectors based on configuration (Windows version)
-func Initialize(cfg config.CollectorsConfig, snd *sender.Sender) []Collector {
-	var collectors []Collector
+// collectorSpecs lists the configured collectors (Windows version)
+func collectorSpecs(cfg config.CollectorsConfig, snd *sender.Sender) []collectorSpec {
+	var specs []collectorSpec
 
 	// File collectors
 	for _, fileCfg := range cfg.Files {
 		if fileCfg.Enabled {
-			collectors = append(collectors, NewFileCollector(fileCfg, snd))
+			fileCfg := fileCfg
+			specs = append(specs, newSpec("file", fileCfg, func() Collector { return NewFileCollector(fileCfg, snd) }))
 		}
 	}
 
 	// Command collectors
 	for _, cmdCfg := range cfg.Command {
 		if cmdCfg.Enabled {
-			collectors = append(collectors, NewCommandCollector(cmdCfg, snd))
+			cmdCfg := cmdCfg
+			specs = append(specs, newSpec("command", cmdCfg, func() Collector { return NewCommandCollector(cmdCfg, snd) }))
 		}
 	}
 
+	// Docker collector
+	if cfg.Docker != nil && cfg.Docker.Enabled {
+		specs = append(specs, newSpec("docker", *cfg.Docker, func() Collector { return NewDockerCollector(*cfg.Docker, snd) }))
+	}
+
+	// Podman collector
+	if cfg.Podman != nil && cfg.Podman.Enabled {
+		specs = append(specs, newSpec("podman", *cfg.Podman, func() Collector { return NewPodmanCollector(*cfg.Podman, snd) }))
+	}
+
+	// Stdin collector; stdin can't be reopened, so a reload never replaces it
+	if cfg.Stdin != nil && cfg.Stdin.Enabled {
+		specs = append(specs, newSpec("stdin", nil, func() Collector { return NewStdinCollector(*cfg.Stdin, snd) }))
+	}
+
+	// HTTP listener collector
+	if cfg.HTTP != nil && cfg.HTTP.Enabled {
+		specs = append(specs, newSpec("http", *cfg.HTTP, func() Collector { return NewHTTPCollector(*cfg.HTTP, snd) }))
+	}
+
+	// TCP/UDP line receivers
+	for _, netCfg := range cfg.Net {
+		if netCfg.Enabled {
+			netCfg := netCfg
+			specs = append(specs, newSpec("net", netCfg, func() Collector { return NewNetCollector(netCfg, snd) }))
+		}
+	}
+
+	// Kubernetes pod log collector
+	if cfg.Kubernetes != nil && cfg.Kubernetes.Enabled {
+		specs = append(specs, newSpec("kubernetes", *cfg.Kubernetes, func() Collector { return NewKubernetesCollector(*cfg.Kubernetes, snd) }))
+	}
+
 	// Add Windows-specific collectors
-	windowsCollectors := InitializeWindows(cfg, snd)
-	collectors = append(collectors, windowsCollectors...)
+	specs = append(specs, windowsSpecs(cfg, snd)...)
+
+	// Heartbeat last; it's linked to the collectors above once they're built
+	if cfg.Heartbeat != nil && cfg.Heartbeat.Enabled {
+		specs = append(specs, newSpec("heartbeat", *cfg.Heartbeat, func() Collector { return NewHeartbeatCollector(*cfg.Heartbeat, nil, snd) }))
+	}
 
-	return collectors
+	return specs
 }
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package collector
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode of an open or stat'd file, or 0 if unknown
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
+
+// openShared opens a file for reading without blocking its rotation
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,33 @@
+//go:build windows
+// +build windows
+
+package collector
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns 0 on Windows, where os.FileInfo carries no file index;
+// checkpoints are then matched by path and size alone
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// openShared opens a file for reading without blocking its rotation. Unlike
+// os.Open it shares delete access, so the file can still be renamed or
+// deleted while held.
+func openShared(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -8,11 +8,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 )
 
@@ -23,6 +28,11 @@ type JournaldCollector struct {
 
 	config config.JournaldCollectorConfig
 	cmd    *exec.Cmd
+
+	truncatedLines int64
+
+	cursor      string // Cursor of the last processed entry
+	cursorDirty bool
 }
 
 // NewJournaldCollector creates a new journald collector
@@ -31,6 +41,12 @@ func NewJournaldCollector(cfg config.JournaldCollectorConfig, snd *sender.Sender
 		BaseCollector: BaseCollector{
 			name:   "journald",
 			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
 		},
 		config: cfg,
 	}
@@ -41,26 +57,55 @@ func (jc *JournaldCollector) Name() string {
 	return jc.name
 }
 
-// Start starts the journald collector
+// Start starts the journald collector. With a saved cursor it resumes just
+// after the last processed entry; otherwise it starts from since. The native
+// journal API is used when built in, and journalctl otherwise.
 func (jc *JournaldCollector) Start(ctx context.Context) {
 	jc.mu.Lock()
 	jc.running = true
 	jc.mu.Unlock()
 
-	fmt.Printf("  [journald] Starting systemd journal collector\n")
+	jc.logger().Info("Starting systemd journal collector")
+
+	var cursor string
+	if jc.config.CheckpointDir != "" {
+		go jc.saveCursors(ctx)
+		defer jc.saveCursor()
+		cursor = jc.loadCursor()
+	}
+
+	if jc.followNative(ctx, cursor) {
+		return
+	}
+
+	if cursor != "" {
+		jc.logger().Info("Resuming after the saved cursor")
+		// A cursor rotated out of the journal resumes at the oldest entry
+		// still available; only a cursor journalctl can't parse fails
+		if !jc.follow(ctx, "--after-cursor="+cursor) || ctx.Err() != nil {
+			return
+		}
+		jc.logger().Warn("journalctl rejected the saved cursor, starting from since")
+	}
+
+	// Add since parameter
+	since := "--since=now"
+	if jc.config.Since != "" {
+		since = fmt.Sprintf("--since=%s", jc.config.Since)
+	}
+	jc.follow(ctx, since)
+}
 
+// follow runs journalctl from the given position and processes its output
+// until it exits. It reports whether journalctl failed before producing
+// any output, as it does for an invalid cursor.
+func (jc *JournaldCollector) follow(ctx context.Context, position string) bool {
 	// Build journalctl command
 	args := []string{
 		"--follow",
 		"--output=json",
 		"--no-pager",
-	}
-
-	// Add since parameter
-	if jc.config.Since != "" {
-		args = append(args, fmt.Sprintf("--since=%s", jc.config.Since))
-	} else {
-		args = append(args, "--since=now")
+		position,
 	}
 
 	// Add priority filter
@@ -73,37 +118,67 @@ func (jc *JournaldCollector) Start(ctx context.Context) {
 		args = append(args, fmt.Sprintf("--unit=%s", unit))
 	}
 
-	jc.cmd = exec.CommandContext(ctx, "journalctl", args...)
+	// Add slice matches (repeated matches on one field are OR'ed)
+	for _, slice := range jc.config.Slices {
+		args = append(args, fmt.Sprintf("_SYSTEMD_SLICE=%s", slice))
+	}
+
+	// Add field matches; "+" is passed through to start an OR group
+	args = append(args, jc.config.Matches...)
+
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	jc.mu.Lock()
+	jc.cmd = cmd
+	jc.mu.Unlock()
 
-	stdout, err := jc.cmd.StdoutPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		fmt.Printf("  [journald] Error creating pipe: %v\n", err)
-		return
+		jc.logger().Error("Error creating pipe", "error", err)
+		return false
 	}
 
-	if err := jc.cmd.Start(); err != nil {
-		fmt.Printf("  [journald] Error starting journalctl: %v\n", err)
-		return
+	if err := cmd.Start(); err != nil {
+		jc.logger().Error("Error starting journalctl", "error", err)
+		return false
+	}
+
+	maxLine := jc.config.MaxLineSize
+	if maxLine <= 0 {
+		maxLine = 4 * 1024 * 1024
 	}
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+
+	lines := 0
+	for {
+		line, truncated, err := readLimitedLine(reader, maxLine)
+		if truncated {
+			jc.mu.Lock()
+			jc.truncatedLines++
+			jc.mu.Unlock()
+			jc.logger().Warn("Truncated journal record", "max_bytes", maxLine)
+		}
 
-	scanner := bufio.NewScanner(stdout)
-	// Increase buffer size for long log lines
-	buf := make([]byte, 0, 1024*1024)
-	scanner.Buffer(buf, 1024*1024)
+		if len(line) > 0 {
+			lines++
+			select {
+			case <-ctx.Done():
+				jc.Stop()
+				cmd.Wait()
+				return false
+			default:
+				jc.processLine(string(line))
+			}
+		}
 
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			jc.Stop()
-			return
-		default:
-			jc.processLine(scanner.Text())
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				jc.logger().Error("Read error", "error", err)
+			}
+			break
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Printf("  [journald] Scanner error: %v\n", err)
-	}
+	return cmd.Wait() != nil && lines == 0 && ctx.Err() == nil
 }
 
 // Stop stops the journald collector
@@ -122,12 +197,15 @@ func (jc *JournaldCollector) Stats() map[string]any {
 	defer jc.mu.RUnlock()
 
 	return map[string]any{
-		"name":           jc.name,
-		"logs_collected": jc.logsCollected,
-		"errors_count":   jc.errorsCount,
-		"last_collected": jc.lastCollected,
-		"running":        jc.running,
-		"units":          jc.config.Units,
+		"name":              jc.name,
+		"logs_collected":    jc.logsCollected,
+		"errors_count":      jc.errorsCount,
+		"last_collected":    jc.lastCollected,
+		"running":           jc.running,
+		"units":             jc.config.Units,
+		"truncated":         jc.truncatedLines,
+		"rate_limited":      jc.limiter.Dropped(),
+		"repeats_collapsed": jc.repeats.collapsedCount(),
 	}
 }
 
@@ -169,7 +247,24 @@ func (jc *JournaldCollector) processLine(text string) {
 			"journald",
 			nil,
 		)
-		jc.sender.Send(entry)
+		jc.send(entry)
+		return
+	}
+
+	jc.processEntry(jEntry)
+}
+
+// processEntry converts a journal entry and sends it
+func (jc *JournaldCollector) processEntry(jEntry JournaldEntry) {
+	if jEntry.Cursor != "" {
+		jc.mu.Lock()
+		jc.cursor = jEntry.Cursor
+		jc.cursorDirty = true
+		jc.mu.Unlock()
+	}
+
+	// journalctl can only match cgroups exactly, so prefixes are checked here
+	if !jc.matchesCGroup(jEntry.SystemdCGroup) {
 		return
 	}
 
@@ -212,15 +307,20 @@ func (jc *JournaldCollector) processLine(text string) {
 
 	// Add metadata
 	entry.Metadata = map[string]any{
-		"comm":          jEntry.Comm,
-		"exe":           jEntry.Exe,
-		"uid":           jEntry.UID,
-		"gid":           jEntry.GID,
-		"transport":     jEntry.Transport,
-		"systemd_slice": jEntry.SystemdSlice,
+		"comm":           jEntry.Comm,
+		"exe":            jEntry.Exe,
+		"uid":            jEntry.UID,
+		"gid":            jEntry.GID,
+		"transport":      jEntry.Transport,
+		"systemd_slice":  jEntry.SystemdSlice,
+		"systemd_cgroup": jEntry.SystemdCGroup,
+	}
+	if jEntry.Cursor != "" {
+		// Tells apart identical lines logged at the same time
+		entry.Metadata["journal_cursor"] = jEntry.Cursor
 	}
 
-	if err := jc.sender.Send(entry); err != nil {
+	if err := jc.send(entry); err != nil {
 		jc.mu.Lock()
 		jc.errorsCount++
 		jc.mu.Unlock()
@@ -233,6 +333,73 @@ func (jc *JournaldCollector) processLine(text string) {
 	jc.mu.Unlock()
 }
 
+// cursorPath is the file holding the last processed cursor
+func (jc *JournaldCollector) cursorPath() string {
+	return filepath.Join(jc.config.CheckpointDir, "journald.cursor")
+}
+
+// loadCursor reads the saved cursor, or returns "" when there is none
+func (jc *JournaldCollector) loadCursor() string {
+	data, err := os.ReadFile(jc.cursorPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// saveCursors saves the cursor periodically until the context ends
+func (jc *JournaldCollector) saveCursors(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := jc.saveCursor(); err != nil {
+				jc.logger().Error("Failed to save cursor", "error", err)
+			}
+		}
+	}
+}
+
+// saveCursor writes the cursor if it changed since the last save. The file
+// is replaced atomically so a crash never leaves a partial cursor.
+func (jc *JournaldCollector) saveCursor() error {
+	jc.mu.Lock()
+	cursor, dirty := jc.cursor, jc.cursorDirty
+	jc.cursorDirty = false
+	jc.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(jc.config.CheckpointDir, 0755); err != nil {
+		return err
+	}
+	tmp := jc.cursorPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cursor+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, jc.cursorPath())
+}
+
+// matchesCGroup reports whether cgroup is under one of the configured prefixes
+func (jc *JournaldCollector) matchesCGroup(cgroup string) bool {
+	if len(jc.config.CGroups) == 0 {
+		return true
+	}
+
+	for _, prefix := range jc.config.CGroups {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if cgroup == prefix || strings.HasPrefix(cgroup, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // priorityToLevel converts syslog priority to log level
 func priorityToLevel(priority string) string {
 	switch priority {
@@ -257,19 +424,34 @@ func priorityToLevel(priority string) string {
 	}
 }
 
-// InitializeLinux adds Linux-specific collectors
-func InitializeLinux(cfg config.CollectorsConfig, snd *sender.Sender) []Collector {
-	var collectors []Collector
+// linuxSpecs lists the Linux-specific collectors
+func linuxSpecs(cfg config.CollectorsConfig, snd *sender.Sender) []collectorSpec {
+	var specs []collectorSpec
 
 	// Add journald collector
 	if cfg.Journald != nil && cfg.Journald.Enabled {
-		collectors = append(collectors, NewJournaldCollector(*cfg.Journald, snd))
+		specs = append(specs, newSpec("journald", *cfg.Journald, func() Collector { return NewJournaldCollector(*cfg.Journald, snd) }))
 	}
 
-	// Add syslog collector
-	if cfg.Syslog != nil && cfg.Syslog.Enabled {
-		collectors = append(collectors, NewSyslogCollector(*cfg.Syslog, snd))
+	// Add one syslog collector per listener
+	for _, syslogCfg := range cfg.Syslog {
+		if syslogCfg.Enabled {
+			syslogCfg := syslogCfg
+			specs = append(specs, newSpec("syslog", syslogCfg, func() Collector { return NewSyslogCollector(syslogCfg, snd) }))
+		}
 	}
 
-	return collectors
+	return specs
+}
+
+// check verifies the journal can be read, through journalctl or the native
+// API
+func (jc *JournaldCollector) check() (string, error) {
+	if path, err := exec.LookPath("journalctl"); err == nil {
+		return "reads through " + path, nil
+	}
+	if jc.nativeAvailable() {
+		return "reads through the native journal API", nil
+	}
+	return "", fmt.Errorf("journalctl not found and the native journal API is unavailable")
 }
@@ -0,0 +1,18 @@
+//go:build linux && !(cgo && sdjournal)
+// +build linux
+// +build !cgo !sdjournal
+
+package collector
+
+import "context"
+
+// followNative is unavailable without the sdjournal build tag and cgo, so
+// the journal is always read through journalctl
+func (jc *JournaldCollector) followNative(ctx context.Context, cursor string) bool {
+	return false
+}
+
+// nativeAvailable reports whether the native journal API can be used
+func (jc *JournaldCollector) nativeAvailable() bool {
+	return false
+}
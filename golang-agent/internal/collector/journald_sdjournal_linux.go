@@ -0,0 +1,202 @@
+//go:build linux && cgo && sdjournal
+// +build linux,cgo,sdjournal
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/sdjournal"
+)
+
+// followNative reads the journal through libsystemd instead of a journalctl
+// process. It reports false without reading anything when libsystemd can't
+// be loaded or since isn't understood, leaving the journalctl path to run.
+func (jc *JournaldCollector) followNative(ctx context.Context, cursor string) bool {
+	since, ok := nativeSince(jc.config.Since, time.Now())
+	if !ok {
+		jc.logger().Info("since needs journalctl, not using the native journal API", "since", jc.config.Since)
+		return false
+	}
+
+	j, err := sdjournal.NewJournal()
+	if err != nil {
+		jc.logger().Info("Native journal API unavailable, using journalctl", "error", err)
+		return false
+	}
+	defer j.Close()
+
+	// Matches on one field are OR'ed, different fields are AND'ed, and "+"
+	// starts an alternative group. Priority and units then apply to every
+	// group, as with journalctl.
+	var matches []string
+	for _, slice := range jc.config.Slices {
+		matches = append(matches, "_SYSTEMD_SLICE="+slice)
+	}
+	matches = append(matches, jc.config.Matches...)
+
+	var filters []string
+	if jc.config.Priority > 0 && jc.config.Priority <= 7 {
+		for p := 0; p <= jc.config.Priority; p++ {
+			filters = append(filters, fmt.Sprintf("PRIORITY=%d", p))
+		}
+	}
+	for _, unit := range jc.config.Units {
+		filters = append(filters, "_SYSTEMD_UNIT="+unit)
+	}
+	err = addNativeMatches(j, matches)
+	if err == nil && len(matches) > 0 && len(filters) > 0 {
+		err = j.AddConjunction()
+	}
+	if err == nil {
+		err = addNativeMatches(j, filters)
+	}
+	if err != nil {
+		jc.logger().Warn("Native journal API rejected the matches, using journalctl", "error", err)
+		return false
+	}
+
+	maxLine := jc.config.MaxLineSize
+	if maxLine <= 0 {
+		maxLine = 4 * 1024 * 1024
+	}
+	j.SetDataThreshold(uint64(maxLine))
+
+	jc.logger().Info("Reading the journal through the native API")
+	if !jc.seekNative(j, cursor, since) {
+		return true
+	}
+
+	for ctx.Err() == nil {
+		n, err := j.Next()
+		if err != nil {
+			jc.logger().Error("Read error", "error", err)
+			return true
+		}
+		if n == 0 {
+			// Caught up: wait for new entries, waking up to notice cancellation
+			j.Wait(time.Second)
+			continue
+		}
+
+		entry, err := j.GetEntry()
+		if err != nil {
+			jc.mu.Lock()
+			jc.errorsCount++
+			jc.mu.Unlock()
+			continue
+		}
+		jc.processEntry(nativeEntry(entry))
+	}
+	return true
+}
+
+// addNativeMatches adds journalctl-style matches, where "+" starts an
+// alternative group
+func addNativeMatches(j *sdjournal.Journal, matches []string) error {
+	for _, match := range matches {
+		var err error
+		if match == "+" {
+			err = j.AddDisjunction()
+		} else {
+			err = j.AddMatch(match)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", match, err)
+		}
+	}
+	return nil
+}
+
+// seekNative positions the journal just after the saved cursor, or at
+// since without one. A cursor rotated out of the journal lands on the
+// nearest entry still available, which is then read too.
+func (jc *JournaldCollector) seekNative(j *sdjournal.Journal, cursor string, since time.Time) bool {
+	if cursor != "" {
+		if err := j.SeekCursor(cursor); err == nil {
+			jc.logger().Info("Resuming after the saved cursor")
+			n, err := j.Next()
+			if err == nil && n > 0 && j.TestCursor(cursor) != nil {
+				// Not the saved entry itself: step back so it's read next
+				j.Previous()
+			}
+			return true
+		}
+		jc.logger().Warn("Saved cursor is invalid, starting from since")
+	}
+
+	if err := j.SeekRealtimeUsec(uint64(since.UnixMicro())); err != nil {
+		jc.logger().Error("Error seeking the journal", "error", err)
+		return false
+	}
+	return true
+}
+
+// nativeSince resolves the since setting to a time. It understands "now",
+// "today", "yesterday", relative durations such as "-1h" and absolute dates;
+// other forms journalctl accepts are reported as unknown.
+func nativeSince(since string, now time.Time) (time.Time, bool) {
+	since = strings.TrimSpace(since)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch since {
+	case "", "now":
+		return now, true
+	case "today":
+		return midnight, true
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), true
+	}
+
+	if strings.HasPrefix(since, "-") || strings.HasPrefix(since, "+") {
+		if d, err := time.ParseDuration(since); err == nil {
+			return now.Add(d), true
+		}
+		return time.Time{}, false
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nativeEntry maps a native journal entry onto the journalctl JSON fields
+func nativeEntry(e *sdjournal.JournalEntry) JournaldEntry {
+	f := e.Fields
+	return JournaldEntry{
+		Timestamp:        int64(e.RealtimeTimestamp),
+		Message:          f[sdjournal.SD_JOURNAL_FIELD_MESSAGE],
+		Priority:         f[sdjournal.SD_JOURNAL_FIELD_PRIORITY],
+		SyslogIdentifier: f[sdjournal.SD_JOURNAL_FIELD_SYSLOG_IDENTIFIER],
+		Unit:             f[sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT],
+		Hostname:         f[sdjournal.SD_JOURNAL_FIELD_HOSTNAME],
+		PID:              f[sdjournal.SD_JOURNAL_FIELD_PID],
+		UID:              f[sdjournal.SD_JOURNAL_FIELD_UID],
+		GID:              f[sdjournal.SD_JOURNAL_FIELD_GID],
+		Comm:             f[sdjournal.SD_JOURNAL_FIELD_COMM],
+		Exe:              f[sdjournal.SD_JOURNAL_FIELD_EXE],
+		CmdLine:          f[sdjournal.SD_JOURNAL_FIELD_CMDLINE],
+		SystemdSlice:     f[sdjournal.SD_JOURNAL_FIELD_SYSTEMD_SLICE],
+		SystemdCGroup:    f[sdjournal.SD_JOURNAL_FIELD_SYSTEMD_CGROUP],
+		MachineID:        f[sdjournal.SD_JOURNAL_FIELD_MACHINE_ID],
+		BootID:           f[sdjournal.SD_JOURNAL_FIELD_BOOT_ID],
+		Transport:        f[sdjournal.SD_JOURNAL_FIELD_TRANSPORT],
+		Cursor:           e.Cursor,
+	}
+}
+
+// nativeAvailable reports whether libsystemd loads and opens the journal
+func (jc *JournaldCollector) nativeAvailable() bool {
+	j, err := sdjournal.NewJournal()
+	if err != nil {
+		return false
+	}
+	j.Close()
+	return true
+}
@@ -0,0 +1,330 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/sender"
+)
+
+// Service account files mounted into every pod
+const (
+	k8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	k8sCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// KubernetesCollector reads container logs from the kubelet's CRI log files
+// and tags each entry with the pod it came from
+type KubernetesCollector struct {
+	config config.KubernetesCollectorConfig
+	lines  *FileCollector // Reuses the file collector's tailing and checkpoints
+	client *http.Client   // Nil unless labels are fetched
+
+	mu     sync.Mutex
+	labels map[string]k8sLabels // Pod UID -> labels
+}
+
+// k8sLabels are a pod's labels and when they were fetched. Failed lookups
+// are cached too, so a missing pod isn't looked up for every line.
+type k8sLabels struct {
+	labels  map[string]string
+	fetched time.Time
+}
+
+// k8sPod is where a log file sits: /var/log/pods/<namespace>_<pod>_<uid>/<container>/<n>.log
+type k8sPod struct {
+	namespace string
+	name      string
+	uid       string
+	container string
+}
+
+// NewKubernetesCollector creates a new Kubernetes pod log collector
+func NewKubernetesCollector(cfg config.KubernetesCollectorConfig, snd *sender.Sender) *KubernetesCollector {
+	paths := cfg.Paths
+	if len(paths) == 0 {
+		paths = []string{"/var/log/pods/*/*/*.log"}
+	}
+
+	lines := NewFileCollector(config.FileCollectorConfig{
+		Paths:         paths,
+		Exclude:       cfg.Exclude,
+		Service:       cfg.Service,
+		Class:         cfg.Class,
+		SchemaVersion: cfg.SchemaVersion,
+		MinLevel:      cfg.MinLevel,
+		RateLimit:     cfg.RateLimit,
+		RepeatWindow:  cfg.RepeatWindow,
+		Multiline:     cfg.Multiline,
+		Parser:        "cri",
+		ReadFrom:      cfg.ReadFrom,
+		Tags:          cfg.Tags,
+		CheckpointDir: cfg.CheckpointDir,
+		MaxOpenFiles:  cfg.MaxOpenFiles,
+	}, snd)
+	lines.name = "kubernetes"
+	lines.fingerprint = configHash(cfg)
+	lines.checkpoints = newCheckpointStore(cfg.CheckpointDir, lines.name, false)
+
+	kc := &KubernetesCollector{
+		config: cfg,
+		lines:  lines,
+		labels: make(map[string]k8sLabels),
+	}
+	lines.enrich = kc.enrich
+
+	if cfg.Labels {
+		kc.client = newK8sClient(cfg)
+	}
+
+	return kc
+}
+
+// newK8sClient creates the client for label lookups, trusting the service
+// account CA unless another is configured
+func newK8sClient(cfg config.KubernetesCollectorConfig) *http.Client {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	caFile := cfg.CAFile
+	if caFile == "" {
+		caFile = k8sCAFile
+	}
+	if pem, err := os.ReadFile(caFile); err == nil {
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM(pem) {
+			tlsConfig.RootCAs = pool
+		}
+	} else if cfg.CAFile != "" {
+		logging.For("kubernetes").Error("Failed to read ca_file", "error", err)
+	}
+
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			DialContext:     (&net.Dialer{Timeout: 5 * time.Second}).DialContext,
+		},
+	}
+}
+
+// Name returns the collector name
+func (kc *KubernetesCollector) Name() string {
+	return kc.lines.Name()
+}
+
+// Start tails the pod log files until the context is cancelled
+func (kc *KubernetesCollector) Start(ctx context.Context) {
+	kc.lines.Start(ctx)
+}
+
+// Fingerprint returns the hash of the collector's config
+func (kc *KubernetesCollector) Fingerprint() string {
+	return kc.lines.Fingerprint()
+}
+
+// Stop stops tailing
+func (kc *KubernetesCollector) Stop() {
+	kc.lines.Stop()
+}
+
+// Drain ships repeated lines still held back
+func (kc *KubernetesCollector) Drain(ctx context.Context) error {
+	return kc.lines.Drain(ctx)
+}
+
+// Stats returns collector statistics
+func (kc *KubernetesCollector) Stats() map[string]any {
+	stats := kc.lines.Stats()
+	if kc.client != nil {
+		kc.mu.Lock()
+		stats["pods_labeled"] = len(kc.labels)
+		kc.mu.Unlock()
+	}
+	return stats
+}
+
+// enrich tags an entry with the pod and container its file belongs to
+func (kc *KubernetesCollector) enrich(filePath string, entry *buffer.LogEntry) {
+	pod, ok := parseK8sPath(filePath)
+	if !ok {
+		return
+	}
+
+	entry.Tags["k8s_namespace"] = pod.namespace
+	entry.Tags["k8s_pod"] = pod.name
+	entry.Tags["k8s_container"] = pod.container
+	if kc.config.Service == "" {
+		entry.Service = pod.container
+	}
+
+	if labels := kc.podLabels(pod); len(labels) > 0 {
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]any)
+		}
+		entry.Metadata["k8s_labels"] = labels
+	}
+}
+
+// parseK8sPath reads the pod from a kubelet log path. Namespaces and pod
+// names can't contain "_", so the directory name splits unambiguously.
+func parseK8sPath(filePath string) (k8sPod, bool) {
+	containerDir := filepath.Dir(filePath)
+	podDir := filepath.Base(filepath.Dir(containerDir))
+
+	parts := strings.SplitN(podDir, "_", 3)
+	if len(parts) != 3 {
+		return k8sPod{}, false
+	}
+	return k8sPod{
+		namespace: parts[0],
+		name:      parts[1],
+		uid:       parts[2],
+		container: filepath.Base(containerDir),
+	}, true
+}
+
+// podLabels returns a pod's labels, fetching them when not cached or stale
+func (kc *KubernetesCollector) podLabels(pod k8sPod) map[string]string {
+	if kc.client == nil {
+		return nil
+	}
+
+	ttl := kc.config.LabelsTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+
+	kc.mu.Lock()
+	cached, ok := kc.labels[pod.uid]
+	kc.mu.Unlock()
+	if ok && time.Since(cached.fetched) < ttl {
+		return cached.labels
+	}
+
+	var labels map[string]string
+	var err error
+	if kc.config.KubeletURL != "" {
+		labels, err = kc.fetchKubeletLabels(pod)
+	} else {
+		labels, err = kc.fetchAPILabels(pod)
+	}
+	if err != nil {
+		kc.lines.logger().Warn("Failed to fetch pod labels", "namespace", pod.namespace, "pod", pod.name, "error", err)
+		// Keep serving the last known labels until the next attempt
+		labels = cached.labels
+	}
+
+	kc.mu.Lock()
+	kc.labels[pod.uid] = k8sLabels{labels: labels, fetched: time.Now()}
+	// Forget pods that stopped logging, most likely deleted
+	for uid, l := range kc.labels {
+		if time.Since(l.fetched) > 2*ttl {
+			delete(kc.labels, uid)
+		}
+	}
+	kc.mu.Unlock()
+	return labels
+}
+
+// k8sPodMeta is the part of a pod object label lookups need
+type k8sPodMeta struct {
+	Metadata struct {
+		Namespace string            `json:"namespace"`
+		Name      string            `json:"name"`
+		UID       string            `json:"uid"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+// fetchAPILabels looks one pod up on the API server
+func (kc *KubernetesCollector) fetchAPILabels(pod k8sPod) (map[string]string, error) {
+	server := kc.config.APIServer
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, fmt.Errorf("api_server not set and not running in a cluster")
+		}
+		if port == "" {
+			port = "443"
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+
+	var p k8sPodMeta
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s", strings.TrimRight(server, "/"),
+		url.PathEscape(pod.namespace), url.PathEscape(pod.name))
+	if err := kc.get(endpoint, &p); err != nil {
+		return nil, err
+	}
+	return p.Metadata.Labels, nil
+}
+
+// fetchKubeletLabels lists the node's pods from the kubelet, caching the
+// labels of all of them since they come in one response
+func (kc *KubernetesCollector) fetchKubeletLabels(pod k8sPod) (map[string]string, error) {
+	var list struct {
+		Items []k8sPodMeta `json:"items"`
+	}
+	if err := kc.get(strings.TrimRight(kc.config.KubeletURL, "/")+"/pods", &list); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var labels map[string]string
+	kc.mu.Lock()
+	for _, item := range list.Items {
+		kc.labels[item.Metadata.UID] = k8sLabels{labels: item.Metadata.Labels, fetched: now}
+		if item.Metadata.UID == pod.uid {
+			labels = item.Metadata.Labels
+		}
+	}
+	kc.mu.Unlock()
+	return labels, nil
+}
+
+// get fetches a JSON document with the service account token
+func (kc *KubernetesCollector) get(endpoint string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	tokenFile := kc.config.TokenFile
+	if tokenFile == "" {
+		tokenFile = k8sTokenFile
+	}
+	// Read on every request: projected tokens are rotated by the kubelet
+	if token, err := os.ReadFile(tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := kc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// check verifies pod log files are present
+func (kc *KubernetesCollector) check() (string, error) {
+	return kc.lines.check()
+}
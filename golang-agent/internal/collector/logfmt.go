@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"strings"
+
+	"logchat/agent/internal/buffer"
+)
+
+// parseLogfmt parses logfmt lines such as
+// `level=info msg="request done" ts=2024-01-02T10:00:00Z status=200`
+func (fc *FileCollector) parseLogfmt(text string, entry *buffer.LogEntry) {
+	pairs := splitLogfmt(text)
+	if len(pairs) == 0 {
+		return
+	}
+
+	metadata := make(map[string]any, len(pairs))
+	for _, p := range pairs {
+		metadata[p.key] = p.value
+
+		// Extract common fields
+		switch p.key {
+		case "level", "lvl":
+			entry.Level = strings.ToUpper(p.value)
+		case "msg", "message":
+			entry.Message = p.value
+		case "ts", "time", "timestamp":
+			fc.setTimestamp(entry, p.value)
+		}
+	}
+
+	entry.Metadata = metadata
+}
+
+// logfmtPair is one key=value pair of a logfmt line
+type logfmtPair struct {
+	key   string
+	value string
+}
+
+// splitLogfmt splits a line into its key=value pairs. Values may be quoted
+// with backslash escapes. Malformed input is tolerated: a key without "="
+// gets an empty value, a pair without a key is skipped and an unterminated
+// quote runs to the end of the line. A line without any "=" is not logfmt
+// and yields nothing.
+func splitLogfmt(text string) []logfmtPair {
+	if !strings.Contains(text, "=") {
+		return nil
+	}
+
+	var pairs []logfmtPair
+	i := 0
+	for i < len(text) {
+		// Skip separating whitespace
+		for i < len(text) && isLogfmtSpace(text[i]) {
+			i++
+		}
+		if i >= len(text) {
+			break
+		}
+
+		start := i
+		for i < len(text) && text[i] != '=' && !isLogfmtSpace(text[i]) {
+			i++
+		}
+		key := text[start:i]
+
+		var value string
+		if i < len(text) && text[i] == '=' {
+			i++
+			if i < len(text) && text[i] == '"' {
+				value, i = readLogfmtQuoted(text, i+1)
+			} else {
+				start := i
+				for i < len(text) && !isLogfmtSpace(text[i]) {
+					i++
+				}
+				value = text[start:i]
+			}
+		}
+
+		if key != "" {
+			pairs = append(pairs, logfmtPair{key: key, value: value})
+		}
+	}
+	return pairs
+}
+
+// readLogfmtQuoted reads a quoted value starting just after the opening
+// quote, returning it unescaped and the index after the closing quote
+func readLogfmtQuoted(text string, i int) (string, int) {
+	var b strings.Builder
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1
+		case c == '\\' && i+1 < len(text):
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(text[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+	return b.String(), i
+}
+
+// isLogfmtSpace reports whether c separates logfmt pairs
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+func TestSplitLogfmt(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		want []logfmtPair
+	}{
+		{
+			name: "plain",
+			text: "level=info status=200 path=/health",
+			want: []logfmtPair{{"level", "info"}, {"status", "200"}, {"path", "/health"}},
+		},
+		{
+			name: "quoted with spaces",
+			text: `msg="request done"  user="jane doe"	ok=true`,
+			want: []logfmtPair{{"msg", "request done"}, {"user", "jane doe"}, {"ok", "true"}},
+		},
+		{
+			name: "escaped quotes",
+			text: `msg="she said \"hi\"" path="C:\\logs" err="line1\nline2\ttab"`,
+			want: []logfmtPair{{"msg", `she said "hi"`}, {"path", `C:\logs`}, {"err", "line1\nline2\ttab"}},
+		},
+		{
+			name: "empty and equals in values",
+			text: `a= b="" query=x=1&y=2 c="="`,
+			want: []logfmtPair{{"a", ""}, {"b", ""}, {"query", "x=1&y=2"}, {"c", "="}},
+		},
+		{
+			name: "quote inside bare value",
+			text: `sql=it's msg=ok`,
+			want: []logfmtPair{{"sql", "it's"}, {"msg", "ok"}},
+		},
+		{
+			name: "malformed pairs",
+			text: `flag =orphan level=warn msg="unterminated \"quote`,
+			want: []logfmtPair{{"flag", ""}, {"level", "warn"}, {"msg", `unterminated "quote`}},
+		},
+		{
+			name: "not logfmt",
+			text: "just a plain sentence",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := splitLogfmt(tc.text); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("splitLogfmt(%q)\n got %q\nwant %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseLogfmtFields(t *testing.T) {
+	fc, _ := newTestFileCollector(t, config.FileCollectorConfig{Parser: "logfmt"})
+
+	text := `ts=2024-01-02T10:00:00Z lvl=warn msg="disk \"data\" at 91%" mount=/data`
+	entry := createLogEntry("INFO", text, "", "app.log", nil)
+	fc.parseLogfmt(text, &entry)
+
+	if entry.Level != "WARN" {
+		t.Errorf("level = %q, want WARN", entry.Level)
+	}
+	if want := `disk "data" at 91%`; entry.Message != want {
+		t.Errorf("message = %q, want %q", entry.Message, want)
+	}
+	if want := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC); !entry.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %s, want %s", entry.Timestamp, want)
+	}
+	if entry.Metadata["mount"] != "/data" || entry.Metadata["msg"] != `disk "data" at 91%` {
+		t.Errorf("metadata = %v", entry.Metadata)
+	}
+
+	// A line that isn't logfmt keeps its text and gets no metadata
+	plain := buffer.LogEntry{Message: "no pairs here"}
+	fc.parseLogfmt(plain.Message, &plain)
+	if plain.Message != "no pairs here" || plain.Metadata != nil {
+		t.Errorf("plain line became %q %v", plain.Message, plain.Metadata)
+	}
+}
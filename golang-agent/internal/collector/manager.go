@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/sender"
+)
+
+// log is the logger for collector lifecycle messages
+var log = logging.For("collectors")
+
+// reloadStopTimeout bounds how long a reload waits for replaced collectors
+// to exit before starting their successors
+const reloadStopTimeout = 10 * time.Second
+
+// Manager runs the active collectors and applies config reloads, restarting
+// only the collectors whose configuration changed
+type Manager struct {
+	mu  sync.Mutex
+	snd *sender.Sender
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	running []*managedCollector
+}
+
+// managedCollector is a started collector with its own cancellation
+type managedCollector struct {
+	Collector
+	key    string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewManager creates a manager for collectors sending through snd
+func NewManager(snd *sender.Sender) *Manager {
+	return &Manager{snd: snd}
+}
+
+// Start creates and starts the configured collectors. They run until ctx
+// is cancelled or a reload removes them.
+func (m *Manager) Start(ctx context.Context, cfg config.CollectorsConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ctx = ctx
+	for _, spec := range collectorSpecs(cfg, m.snd) {
+		m.running = append(m.running, m.start(spec.key, spec.build()))
+	}
+	m.linkHeartbeats()
+}
+
+// Reload applies a new collectors config. Collectors whose config is
+// unchanged keep running, and keep their file positions; the rest are
+// stopped and replaced. Only the replacements are built. It returns how
+// many were started and stopped.
+func (m *Manager) Reload(cfg config.CollectorsConfig) (started, stopped int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Index the running collectors; several can share a key
+	unmatched := make(map[string][]*managedCollector)
+	for _, mc := range m.running {
+		unmatched[mc.key] = append(unmatched[mc.key], mc)
+	}
+
+	// Keep running collectors that match a wanted one
+	wanted := collectorSpecs(cfg, m.snd)
+	next := make([]*managedCollector, len(wanted))
+	for i, spec := range wanted {
+		if olds := unmatched[spec.key]; len(olds) > 0 {
+			next[i] = olds[0]
+			unmatched[spec.key] = olds[1:]
+		}
+	}
+
+	// Stop the rest first, so listeners and checkpoint files are released
+	// before their replacements start
+	var stopping []*managedCollector
+	for _, olds := range unmatched {
+		for _, mc := range olds {
+			mc.cancel()
+			mc.Stop()
+			stopping = append(stopping, mc)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reloadStopTimeout)
+	defer cancel()
+	for _, mc := range stopping {
+		select {
+		case <-mc.done:
+			if err := mc.Drain(ctx); err != nil {
+				log.Warn("Collector did not drain in time", "collector", mc.Name(), "error", err)
+			}
+		case <-ctx.Done():
+			log.Warn("Collector did not stop in time", "collector", mc.Name(), "timeout", reloadStopTimeout)
+		}
+	}
+
+	for i, spec := range wanted {
+		if next[i] == nil {
+			next[i] = m.start(spec.key, spec.build())
+			started++
+		}
+	}
+
+	m.running = next
+	m.linkHeartbeats()
+	return started, len(stopping)
+}
+
+// Collectors returns the running collectors
+func (m *Manager) Collectors() []Collector {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	collectors := make([]Collector, len(m.running))
+	for i, mc := range m.running {
+		collectors[i] = mc.Collector
+	}
+	return collectors
+}
+
+// Stop stops every running collector. Cancel the context passed to Start
+// as well, then Wait for them to exit.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, mc := range m.running {
+		mc.cancel()
+		mc.Stop()
+	}
+}
+
+// Wait blocks until every started collector has exited
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Drain emits what the stopped collectors still hold. Call it after Wait
+// and before the sender's final flush.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, mc := range m.running {
+		if err := mc.Drain(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", mc.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// start runs a collector under its own cancellable context
+func (m *Manager) start(key string, c Collector) *managedCollector {
+	ctx, cancel := context.WithCancel(m.ctx)
+	mc := &managedCollector{
+		Collector: c,
+		key:       key,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(mc.done)
+		c.Start(ctx)
+	}()
+	return mc
+}
+
+// linkHeartbeats points heartbeats at the current data collectors, which
+// after a reload mixes kept and new instances
+func (m *Manager) linkHeartbeats() {
+	collectors := make([]Collector, len(m.running))
+	for i, mc := range m.running {
+		collectors[i] = mc.Collector
+	}
+	linkHeartbeats(collectors)
+}
+
+// linkHeartbeats points the heartbeats among collectors at the others
+func linkHeartbeats(collectors []Collector) {
+	var data []Collector
+	var heartbeats []*HeartbeatCollector
+	for _, c := range collectors {
+		if hc, ok := c.(*HeartbeatCollector); ok {
+			heartbeats = append(heartbeats, hc)
+		} else {
+			data = append(data, c)
+		}
+	}
+
+	for _, hc := range heartbeats {
+		hc.setCollectors(data)
+	}
+}
+
+// collectorSpec is a configured collector, built only when it's started
+type collectorSpec struct {
+	key   string // Kind and config hash, matching a collector across reloads
+	build func() Collector
+}
+
+// newSpec describes a collector of kind built from cfg
+func newSpec(kind string, cfg any, build func() Collector) collectorSpec {
+	return collectorSpec{key: kind + "@" + configHash(cfg), build: build}
+}
+
+// Initialize creates collectors based on configuration
+func Initialize(cfg config.CollectorsConfig, snd *sender.Sender) []Collector {
+	specs := collectorSpecs(cfg, snd)
+	collectors := make([]Collector, len(specs))
+	for i, spec := range specs {
+		collectors[i] = spec.build()
+	}
+	linkHeartbeats(collectors)
+	return collectors
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"logchat/agent/internal/config"
+)
+
+func TestManagerReloadKeepsUnchangedCollectors(t *testing.T) {
+	snd, _ := newTestSender(t)
+
+	dir := t.TempDir()
+	fileCfg := func(service string) config.FileCollectorConfig {
+		return config.FileCollectorConfig{
+			Enabled: true,
+			Service: service,
+			Paths:   []string{filepath.Join(dir, service+".log")},
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(snd)
+	defer func() {
+		cancel()
+		m.Stop()
+		m.Wait()
+	}()
+
+	m.Start(ctx, config.CollectorsConfig{
+		Files: []config.FileCollectorConfig{fileCfg("kept"), fileCfg("old")},
+	})
+	before := m.Collectors()
+
+	started, stopped := m.Reload(config.CollectorsConfig{
+		Files: []config.FileCollectorConfig{fileCfg("kept"), fileCfg("new")},
+	})
+	if started != 1 || stopped != 1 {
+		t.Errorf("started %d and stopped %d, want 1 and 1", started, stopped)
+	}
+
+	after := m.Collectors()
+	if len(after) != 2 {
+		t.Fatalf("%d collectors after reload, want 2", len(after))
+	}
+	if after[0] != before[0] {
+		t.Error("unchanged collector was replaced")
+	}
+	if after[1].Name() != "file:new" {
+		t.Errorf("second collector is %s, want file:new", after[1].Name())
+	}
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"logchat/agent/internal/config"
+)
+
+// multiline joins continuation lines into one entry. A line is a
+// continuation when it matches the pattern (inverted by negate). In "after"
+// mode continuations are appended to the preceding line; in "before" mode
+// they are held and prepended to the next non-continuation line.
+type multiline struct {
+	pattern  *regexp.Regexp
+	negate   bool
+	before   bool
+	maxLines int
+
+	lines  []string
+	offset int64 // Offset of the first pending line
+}
+
+// newMultiline creates per-file multiline state, nil when not configured
+func newMultiline(cfg *config.MultilineConfig, pattern *regexp.Regexp) *multiline {
+	if cfg == nil || pattern == nil {
+		return nil
+	}
+
+	maxLines := cfg.MaxLines
+	if maxLines <= 0 {
+		maxLines = 500
+	}
+
+	return &multiline{
+		pattern:  pattern,
+		negate:   cfg.Negate,
+		before:   cfg.Match == "before",
+		maxLines: maxLines,
+	}
+}
+
+// add feeds one line and returns a completed entry and its offset, if any
+func (m *multiline) add(line string, offset int64) (string, int64, bool) {
+	continuation := m.pattern.MatchString(line) != m.negate
+
+	if m.before {
+		m.push(line, offset)
+		if continuation && len(m.lines) < m.maxLines {
+			return "", 0, false
+		}
+		text, start := m.flush()
+		return text, start, true
+	}
+
+	if continuation && len(m.lines) > 0 && len(m.lines) < m.maxLines {
+		m.push(line, offset)
+		return "", 0, false
+	}
+
+	text, start := m.flush()
+	m.push(line, offset)
+	return text, start, text != ""
+}
+
+// push appends a line to the pending entry
+func (m *multiline) push(line string, offset int64) {
+	if len(m.lines) == 0 {
+		m.offset = offset
+	}
+	m.lines = append(m.lines, line)
+}
+
+// pending reports whether lines are waiting to be flushed
+func (m *multiline) pending() bool {
+	return len(m.lines) > 0
+}
+
+// flush returns and clears the pending entry
+func (m *multiline) flush() (string, int64) {
+	if len(m.lines) == 0 {
+		return "", 0
+	}
+	text := strings.Join(m.lines, "\n")
+	m.lines = m.lines[:0]
+	return text, m.offset
+}
@@ -0,0 +1,300 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
+	"logchat/agent/internal/sender"
+)
+
+// NetCollector receives newline-delimited text over TCP or UDP from devices
+// that don't speak syslog. Each line becomes one entry.
+type NetCollector struct {
+	BaseCollector
+	mu sync.RWMutex
+
+	config    config.NetCollectorConfig
+	listener  net.Listener
+	conn      net.PacketConn
+	conns     int   // Open TCP connections
+	truncated int64 // Lines cut at max_line_size
+
+	handlers sync.WaitGroup // TCP connection handlers, drained on shutdown
+}
+
+// NewNetCollector creates a new TCP/UDP line receiver
+func NewNetCollector(cfg config.NetCollectorConfig, snd *sender.Sender) *NetCollector {
+	if cfg.Service == "" {
+		cfg.Service = "net"
+	}
+
+	return &NetCollector{
+		BaseCollector: BaseCollector{
+			name:   fmt.Sprintf("net:%s", cfg.Address),
+			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
+		},
+		config: cfg,
+	}
+}
+
+// Name returns the collector name
+func (nc *NetCollector) Name() string {
+	return nc.name
+}
+
+// Start listens on the configured address until the context is cancelled
+func (nc *NetCollector) Start(ctx context.Context) {
+	nc.mu.Lock()
+	nc.running = true
+	nc.mu.Unlock()
+
+	defer func() {
+		nc.mu.Lock()
+		nc.running = false
+		nc.mu.Unlock()
+	}()
+
+	nc.logger().Info("Starting line receiver", "address", nc.config.Address)
+
+	if addr, ok := strings.CutPrefix(nc.config.Address, "udp://"); ok {
+		nc.startUDP(ctx, addr)
+		return
+	}
+	nc.startTCP(ctx, strings.TrimPrefix(nc.config.Address, "tcp://"))
+}
+
+// maxLine returns the longest line kept before truncating
+func (nc *NetCollector) maxLine() int {
+	if nc.config.MaxLineSize <= 0 {
+		return 64 * 1024
+	}
+	return nc.config.MaxLineSize
+}
+
+// startUDP reads datagrams, each holding one or more lines
+func (nc *NetCollector) startUDP(ctx context.Context, addr string) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		nc.logger().Error("Error listening", "error", err)
+		return
+	}
+	nc.mu.Lock()
+	nc.conn = conn
+	nc.mu.Unlock()
+	defer conn.Close()
+
+	maxLen := nc.maxLine()
+	buf := make([]byte, 65536)
+
+	for ctx.Err() == nil {
+		conn.SetReadDeadline(time.Now().Add(1 * time.Second))
+		n, remote, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			line = bytes.TrimRight(line, "\r")
+			if len(line) > maxLen {
+				line = line[:maxLen]
+				nc.mu.Lock()
+				nc.truncated++
+				nc.mu.Unlock()
+			}
+			nc.processLine(string(line), remote)
+		}
+	}
+}
+
+// startTCP accepts connections and reads lines from each
+func (nc *NetCollector) startTCP(ctx context.Context, addr string) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		nc.logger().Error("Error listening", "error", err)
+		return
+	}
+	nc.mu.Lock()
+	nc.listener = listener
+	nc.mu.Unlock()
+	defer listener.Close()
+
+	// Closing the listener unblocks Accept on shutdown
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		nc.handlers.Add(1)
+		go func() {
+			defer nc.handlers.Done()
+			nc.handleTCPConn(ctx, conn)
+		}()
+	}
+}
+
+// handleTCPConn reads newline-delimited lines from one connection. Memory
+// per connection is bounded by the read buffer plus max_line_size, since
+// longer lines are truncated rather than accumulated.
+func (nc *NetCollector) handleTCPConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	nc.mu.Lock()
+	nc.conns++
+	nc.mu.Unlock()
+	defer func() {
+		nc.mu.Lock()
+		nc.conns--
+		nc.mu.Unlock()
+	}()
+
+	// Closing the connection unblocks the read on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	maxLen := nc.maxLine()
+	size := 16 * 1024
+	if maxLen < size {
+		size = maxLen
+	}
+	reader := bufio.NewReaderSize(conn, size)
+
+	for {
+		line, truncated, err := readFrame(reader, "newline", maxLen)
+		if truncated {
+			nc.logger().Warn("Truncated line", "max_bytes", maxLen, "remote_addr", conn.RemoteAddr().String())
+			nc.mu.Lock()
+			nc.truncated++
+			nc.mu.Unlock()
+		}
+		if len(line) > 0 {
+			nc.processLine(string(line), conn.RemoteAddr())
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				nc.logger().Warn("Dropping connection", "remote_addr", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+	}
+}
+
+// processLine emits one received line, tagged with the sender's address
+func (nc *NetCollector) processLine(line string, remote net.Addr) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	entry := createLogEntry(parseLevel(line), line, nc.config.Service, "net", nc.config.Tags)
+	if host, _, err := net.SplitHostPort(remote.String()); err == nil {
+		entry.Tags["remote_addr"] = host
+	}
+
+	if err := nc.send(entry); err != nil {
+		nc.mu.Lock()
+		nc.errorsCount++
+		nc.mu.Unlock()
+		return
+	}
+
+	nc.mu.Lock()
+	nc.logsCollected++
+	nc.lastCollected = time.Now()
+	nc.mu.Unlock()
+}
+
+// Stop closes the listener
+func (nc *NetCollector) Stop() {
+	nc.mu.Lock()
+	nc.running = false
+	if nc.listener != nil {
+		nc.listener.Close()
+	}
+	if nc.conn != nil {
+		nc.conn.Close()
+	}
+	nc.mu.Unlock()
+}
+
+// Drain waits for connections to emit the lines they were reading, then
+// ships held repeated lines
+func (nc *NetCollector) Drain(ctx context.Context) error {
+	if err := waitCtx(ctx, &nc.handlers); err != nil {
+		return err
+	}
+	return nc.BaseCollector.Drain(ctx)
+}
+
+// Stats returns collector statistics
+func (nc *NetCollector) Stats() map[string]any {
+	nc.mu.RLock()
+	defer nc.mu.RUnlock()
+
+	return map[string]any{
+		"name":              nc.name,
+		"logs_collected":    nc.logsCollected,
+		"errors_count":      nc.errorsCount,
+		"logs_filtered":     atomic.LoadInt64(&nc.logsFiltered),
+		"rate_limited":      nc.limiter.Dropped(),
+		"repeats_collapsed": nc.repeats.collapsedCount(),
+		"last_collected":    nc.lastCollected,
+		"running":           nc.running,
+		"address":           nc.config.Address,
+		"connections":       nc.conns,
+		"truncated":         nc.truncated,
+	}
+}
+
+// check verifies the listen address is free, releasing it right away
+func (nc *NetCollector) check() (string, error) {
+	if addr, ok := strings.CutPrefix(nc.config.Address, "udp://"); ok {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+	} else {
+		ln, err := net.Listen("tcp", strings.TrimPrefix(nc.config.Address, "tcp://"))
+		if err != nil {
+			return "", err
+		}
+		ln.Close()
+	}
+	return "can listen on " + nc.config.Address, nil
+}
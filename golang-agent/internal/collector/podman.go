@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/sender"
+)
+
+// NewPodmanCollector creates a collector for Podman containers. Podman's API
+// is Docker-compatible, so this is the Docker collector with pod metadata
+// and Podman's socket locations.
+func NewPodmanCollector(cfg config.DockerCollectorConfig, snd *sender.Sender) *DockerCollector {
+	socket := cfg.Socket
+	if socket == "" {
+		socket = podmanSocket()
+	}
+
+	dc := newContainerCollector("podman", socket, cfg, snd)
+	dc.podman = true
+	dc.pods = make(map[string]string)
+	return dc
+}
+
+// podmanSocket finds the API socket for the running user. Rootless Podman
+// listens under the user's runtime directory, rootful Podman under /run.
+func podmanSocket() string {
+	var candidates []string
+	if uid := os.Getuid(); uid > 0 {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+		}
+		candidates = append(candidates, filepath.Join("/run/user", strconv.Itoa(uid), "podman", "podman.sock"))
+	}
+	candidates = append(candidates, "/run/podman/podman.sock")
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return candidates[0]
+}
+
+// inspectPodman fetches container details from the libpod API, which
+// unlike the compat API includes the container's pod
+func (dc *DockerCollector) inspectPodman(ctx context.Context, id string) (dockerContainer, error) {
+	resp, err := dc.get(ctx, "/libpod/containers/"+id+"/json", nil)
+	if err != nil {
+		return dockerContainer{}, err
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		ID        string `json:"Id"`
+		Name      string `json:"Name"`
+		ImageName string `json:"ImageName"`
+		Pod       string `json:"Pod"`
+		Config    struct {
+			Labels map[string]string `json:"Labels"`
+			Tty    bool              `json:"Tty"`
+		} `json:"Config"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return dockerContainer{}, fmt.Errorf("failed to decode container: %w", err)
+	}
+
+	c := dockerContainer{
+		ID:     info.ID,
+		Name:   strings.TrimPrefix(info.Name, "/"),
+		Image:  info.ImageName,
+		Labels: info.Config.Labels,
+		TTY:    info.Config.Tty,
+		PodID:  info.Pod,
+	}
+	if c.PodID != "" {
+		c.Pod = dc.podName(ctx, c.PodID)
+	}
+	return c, nil
+}
+
+// podName resolves and caches a pod's name, falling back to its short ID
+func (dc *DockerCollector) podName(ctx context.Context, podID string) string {
+	dc.mu.RLock()
+	name, ok := dc.pods[podID]
+	dc.mu.RUnlock()
+	if ok {
+		return name
+	}
+
+	name = shortID(podID)
+	if resp, err := dc.get(ctx, "/libpod/pods/"+podID+"/json", nil); err == nil {
+		var pod struct {
+			Name string `json:"Name"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&pod) == nil && pod.Name != "" {
+			name = pod.Name
+		}
+		resp.Body.Close()
+	}
+
+	dc.mu.Lock()
+	dc.pods[podID] = name
+	dc.mu.Unlock()
+	return name
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+// repeatCollapser folds runs of identical lines, the way syslog reports
+// "last message repeated N times". The first line of a run ships at once;
+// copies arriving within the window are held back and shipped as a single
+// entry with metadata.repeat_count when a different line arrives or the
+// window runs out.
+type repeatCollapser struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	emit    func(buffer.LogEntry) error
+	key     uint64
+	started time.Time        // When the run's first line shipped
+	held    *buffer.LogEntry // Latest copy, not shipped yet
+	count   int              // Copies held back
+	timer   *time.Timer
+
+	collapsed int64 // atomic, lines that didn't ship on their own
+}
+
+// newRepeatCollapser creates a collapser, nil when window is off
+func newRepeatCollapser(window time.Duration) *repeatCollapser {
+	if window <= 0 {
+		return nil
+	}
+	return &repeatCollapser{window: window}
+}
+
+// send ships entry through emit unless it repeats the current run
+func (r *repeatCollapser) send(entry buffer.LogEntry, emit func(buffer.LogEntry) error) error {
+	key := repeatKey(entry)
+
+	r.mu.Lock()
+	r.emit = emit
+	if key == r.key && !r.started.IsZero() && time.Since(r.started) < r.window {
+		r.count++
+		r.held = &entry
+		if r.timer == nil {
+			r.timer = time.AfterFunc(r.window-time.Since(r.started), r.expire)
+		}
+		r.mu.Unlock()
+		return nil
+	}
+
+	pending := r.take()
+	r.key = key
+	r.started = time.Now()
+	r.mu.Unlock()
+
+	if pending != nil {
+		emit(*pending)
+	}
+	return emit(entry)
+}
+
+// expire ends the run when its window is over
+func (r *repeatCollapser) expire() {
+	r.flush()
+}
+
+// flush ships any held copies and ends the run
+func (r *repeatCollapser) flush() {
+	r.mu.Lock()
+	pending := r.take()
+	r.started = time.Time{}
+	emit := r.emit
+	r.mu.Unlock()
+
+	if pending != nil {
+		emit(*pending)
+	}
+}
+
+// take returns the held copies as one entry and resets the run. Caller
+// holds r.mu.
+func (r *repeatCollapser) take() *buffer.LogEntry {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if r.count == 0 {
+		return nil
+	}
+
+	entry := *r.held
+	metadata := make(map[string]any, len(entry.Metadata)+1)
+	for k, v := range entry.Metadata {
+		metadata[k] = v
+	}
+	metadata["repeat_count"] = r.count
+	entry.Metadata = metadata
+
+	atomic.AddInt64(&r.collapsed, int64(r.count-1))
+	r.count = 0
+	r.held = nil
+	return &entry
+}
+
+// collapsedCount returns how many lines were folded into another entry
+func (r *repeatCollapser) collapsedCount() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.collapsed)
+}
+
+// repeatKey identifies a line by its level, service and message
+func repeatKey(entry buffer.LogEntry) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(entry.Level))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Service))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Message))
+	return h.Sum64()
+}
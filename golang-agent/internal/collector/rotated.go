@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// isCompressed reports whether a file is gzip-compressed, judged by name
+func isCompressed(path string) bool {
+	return strings.HasSuffix(path, ".gz")
+}
+
+// findRotated finds rotated copies next to the tailed files, such as
+// app.log.1, app.log.2.gz or app.log-20240601.gz
+func (fc *FileCollector) findRotated(tailed []string) []string {
+	seen := make(map[string]bool, len(tailed))
+	for _, file := range tailed {
+		seen[file] = true
+	}
+
+	var files []string
+	for _, file := range tailed {
+		if isCompressed(file) {
+			continue
+		}
+		for _, suffix := range []string{".*", "-*"} {
+			matches, _ := filepath.Glob(file + suffix)
+			for _, match := range matches {
+				if seen[match] || fc.isExcluded(match) {
+					continue
+				}
+				if info, err := os.Stat(match); err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files
+}
+
+// startRead reads filePath once in a new goroutine unless it is already
+// being read or has been read
+func (fc *FileCollector) startRead(ctx context.Context, wg *sync.WaitGroup, filePath string) {
+	fc.mu.Lock()
+	if fc.tailing[filePath] || fc.read[filePath] || !fc.reserveSlot(filePath, true) {
+		fc.mu.Unlock()
+		return
+	}
+	fc.tailing[filePath] = true
+	delete(fc.deferred, filePath)
+	fc.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fc.readFile(ctx, filePath)
+
+		fc.mu.Lock()
+		fc.read[filePath] = true
+		fc.mu.Unlock()
+		fc.releaseSlot(filePath)
+	}()
+}
+
+// readFile reads a file that no longer grows, such as a rotated or
+// compressed log, from the start to the end. A completed read is
+// checkpointed so a restart doesn't ingest the file again. A corrupt or
+// truncated archive is reported and skipped after the lines before the damage.
+func (fc *FileCollector) readFile(ctx context.Context, filePath string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	inode := fileInode(info)
+	if fc.checkpoints != nil {
+		if cp, ok := fc.checkpoints.get(filePath); ok && cp.Inode == inode && cp.Offset >= info.Size() {
+			return
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		fc.readError(filePath, err)
+		return
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	compressed := isCompressed(filePath)
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			fc.readError(filePath, err)
+			return
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	fc.logger().Info("Reading rotated file", "path", filePath)
+
+	joined := newMultiline(fc.config.Multiline, fc.joiner)
+	partials := newCRIPartials(fc.config.Parser)
+	emit := func(text string, offset int64) {
+		// Offsets into decompressed data don't locate anything on disk
+		if compressed {
+			offset = -1
+		}
+		if partials != nil {
+			var ok bool
+			if text, offset, ok = partials.add(text, offset); !ok {
+				return
+			}
+		}
+		if joined == nil {
+			fc.processLine(filePath, text, offset)
+			return
+		}
+		if text, start, ok := joined.add(text, offset); ok {
+			fc.processLine(filePath, text, start)
+		}
+	}
+	flush := func() {
+		if joined == nil {
+			return
+		}
+		if text, offset := joined.flush(); text != "" {
+			fc.processLine(filePath, text, offset)
+		}
+	}
+
+	reader := bufio.NewReader(r)
+	head, _ := reader.Peek(headSize)
+	decoder := newLineDecoder(fc.config.Encoding, head)
+	var offset int64
+	lines := 0
+	for ctx.Err() == nil {
+		line, err := decoder.readLine(reader)
+		if line != "" {
+			emit(strings.TrimRight(decoder.decode(line), "\r"), offset)
+			offset += int64(len(line))
+			lines++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			flush()
+			fc.readError(filePath, err)
+			return
+		}
+	}
+	flush()
+	if ctx.Err() != nil {
+		return
+	}
+
+	if fc.checkpoints != nil {
+		fc.checkpoints.set(filePath, inode, info.Size())
+	}
+	fc.logger().Info("Read rotated file", "path", filePath, "lines", lines)
+}
+
+// readError counts and reports a file that could not be read
+func (fc *FileCollector) readError(filePath string, err error) {
+	fc.mu.Lock()
+	fc.errorsCount++
+	fc.mu.Unlock()
+	fc.logger().Error("Error reading rotated file, skipping it", "path", filePath, "error", err)
+}
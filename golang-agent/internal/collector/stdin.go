@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/sender"
+)
+
+// StdinCollector reads log lines from standard input until EOF
+type StdinCollector struct {
+	config config.StdinCollectorConfig
+	lines  *FileCollector // Reuses the file collector's parsing
+	reader io.Reader
+	done   chan struct{}
+}
+
+// NewStdinCollector creates a new stdin collector
+func NewStdinCollector(cfg config.StdinCollectorConfig, snd *sender.Sender) *StdinCollector {
+	service := cfg.Service
+	if service == "" {
+		service = "stdin"
+	}
+
+	lines := NewFileCollector(config.FileCollectorConfig{
+		Service:       service,
+		Class:         cfg.Class,
+		SchemaVersion: cfg.SchemaVersion,
+		MinLevel:      cfg.MinLevel,
+		RateLimit:     cfg.RateLimit,
+		RepeatWindow:  cfg.RepeatWindow,
+		Parser:        cfg.Parser,
+		ParseRegex:    cfg.ParseRegex,
+		BracketFields: cfg.BracketFields,
+		TimeFormat:    cfg.TimeFormat,
+		Tags:          cfg.Tags,
+	}, snd)
+	lines.name = "stdin"
+
+	return &StdinCollector{
+		config: cfg,
+		lines:  lines,
+		reader: os.Stdin,
+		done:   make(chan struct{}),
+	}
+}
+
+// Name returns the collector name
+func (sc *StdinCollector) Name() string {
+	return "stdin"
+}
+
+// Done is closed once stdin reaches EOF
+func (sc *StdinCollector) Done() <-chan struct{} {
+	return sc.done
+}
+
+// Start reads stdin line by line until EOF or cancellation
+func (sc *StdinCollector) Start(ctx context.Context) {
+	defer close(sc.done)
+
+	sc.lines.mu.Lock()
+	sc.lines.running = true
+	sc.lines.mu.Unlock()
+
+	defer func() {
+		sc.lines.mu.Lock()
+		sc.lines.running = false
+		sc.lines.mu.Unlock()
+	}()
+
+	maxLine := sc.config.MaxLineSize
+	if maxLine <= 0 {
+		maxLine = 1024 * 1024
+	}
+
+	sc.lines.logger().Info("Reading from standard input")
+
+	reader := bufio.NewReaderSize(sc.reader, 64*1024)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		line, truncated, err := readLimitedLine(reader, maxLine)
+		if truncated {
+			sc.lines.logger().Warn("Truncated line", "max_bytes", maxLine)
+		}
+		if len(line) > 0 {
+			sc.lines.processLine("stdin", string(line), -1)
+		}
+
+		if err == io.EOF {
+			sc.lines.logger().Info("Reached end of input")
+			return
+		}
+		if err != nil {
+			sc.lines.logger().Error("Read error", "error", err)
+			return
+		}
+	}
+}
+
+// Fingerprint is fixed: stdin can't be reopened, so its config never
+// decides whether a reload replaces it
+func (sc *StdinCollector) Fingerprint() string {
+	return "stdin"
+}
+
+// Stop is a no-op; reading ends on EOF or context cancellation
+func (sc *StdinCollector) Stop() {}
+
+// Drain ships repeated lines still held back
+func (sc *StdinCollector) Drain(ctx context.Context) error {
+	return sc.lines.Drain(ctx)
+}
+
+// Stats returns collector statistics
+func (sc *StdinCollector) Stats() map[string]any {
+	stats := sc.lines.Stats()
+	delete(stats, "files_watched")
+	return stats
+}
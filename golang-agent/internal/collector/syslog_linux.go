@@ -4,14 +4,21 @@
 package collector
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/ratelimit"
 	"logchat/agent/internal/sender"
 )
 
@@ -20,17 +27,33 @@ type SyslogCollector struct {
 	BaseCollector
 	mu sync.RWMutex
 
-	config   config.SyslogCollectorConfig
-	listener net.Listener
-	conn     net.PacketConn
+	config    config.SyslogCollectorConfig
+	listener  net.Listener
+	conn      net.PacketConn
+	truncated int64 // TCP messages cut at max_message_size
+
+	handlers sync.WaitGroup // TCP connection handlers, drained on shutdown
 }
 
 // NewSyslogCollector creates a new syslog collector
 func NewSyslogCollector(cfg config.SyslogCollectorConfig, snd *sender.Sender) *SyslogCollector {
+	// Include the address so several listeners stay distinguishable
+	name := "syslog"
+	if cfg.Address != "" {
+		name = fmt.Sprintf("syslog:%s", cfg.Address)
+	}
+
 	return &SyslogCollector{
 		BaseCollector: BaseCollector{
-			name:   "syslog",
+			name:   name,
 			sender: snd,
+			class:  cfg.Class,
+			schema: resolveSchemaVersion(cfg.SchemaVersion, cfg),
+
+			fingerprint: configHash(cfg),
+			minLevel:    cfg.MinLevel,
+			limiter:     ratelimit.New(cfg.RateLimit),
+			repeats:     newRepeatCollapser(cfg.RepeatWindow),
 		},
 		config: cfg,
 	}
@@ -47,28 +70,8 @@ func (sc *SyslogCollector) Start(ctx context.Context) {
 	sc.running = true
 	sc.mu.Unlock()
 
-	address := sc.config.Address
-	if address == "" {
-		address = "unix:///dev/log"
-	}
-
-	fmt.Printf("  [syslog] Starting syslog listener on %s\n", address)
-
-	// Parse address
-	var network, addr string
-	if strings.HasPrefix(address, "unix://") {
-		network = "unixgram"
-		addr = strings.TrimPrefix(address, "unix://")
-	} else if strings.HasPrefix(address, "udp://") {
-		network = "udp"
-		addr = strings.TrimPrefix(address, "udp://")
-	} else if strings.HasPrefix(address, "tcp://") {
-		network = "tcp"
-		addr = strings.TrimPrefix(address, "tcp://")
-	} else {
-		network = "udp"
-		addr = address
-	}
+	network, addr := sc.listenAddress()
+	sc.logger().Info("Starting syslog listener", "network", network, "address", addr)
 
 	if network == "tcp" {
 		sc.startTCP(ctx, addr)
@@ -77,11 +80,30 @@ func (sc *SyslogCollector) Start(ctx context.Context) {
 	}
 }
 
+// listenAddress splits the configured address into a network and address
+// to listen on, defaulting to the local /dev/log socket
+func (sc *SyslogCollector) listenAddress() (network, addr string) {
+	address := sc.config.Address
+	if address == "" {
+		address = "unix:///dev/log"
+	}
+
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return "unixgram", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "udp://"):
+		return "udp", strings.TrimPrefix(address, "udp://")
+	case strings.HasPrefix(address, "tcp://"):
+		return "tcp", strings.TrimPrefix(address, "tcp://")
+	}
+	return "udp", address
+}
+
 // startUDP starts UDP/Unix listener
 func (sc *SyslogCollector) startUDP(ctx context.Context, network, addr string) {
 	conn, err := net.ListenPacket(network, addr)
 	if err != nil {
-		fmt.Printf("  [syslog] Error listening: %v\n", err)
+		sc.logger().Error("Error listening", "error", err)
 		return
 	}
 	sc.conn = conn
@@ -112,7 +134,7 @@ func (sc *SyslogCollector) startUDP(ctx context.Context, network, addr string) {
 func (sc *SyslogCollector) startTCP(ctx context.Context, addr string) {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		fmt.Printf("  [syslog] Error listening: %v\n", err)
+		sc.logger().Error("Error listening", "error", err)
 		return
 	}
 	sc.listener = listener
@@ -128,29 +150,54 @@ func (sc *SyslogCollector) startTCP(ctx context.Context, addr string) {
 				continue
 			}
 
-			go sc.handleTCPConn(ctx, conn)
+			sc.handlers.Add(1)
+			go func() {
+				defer sc.handlers.Done()
+				sc.handleTCPConn(ctx, conn)
+			}()
 		}
 	}
 }
 
-// handleTCPConn handles a TCP connection
+// handleTCPConn reads framed messages from a TCP connection. Frames are
+// reassembled across reads, so several per packet or one spanning packets
+// both work.
 func (sc *SyslogCollector) handleTCPConn(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
-	buf := make([]byte, 65536)
-
-	for {
+	// Closing the connection unblocks the read on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
 		select {
 		case <-ctx.Done():
-			return
-		default:
-			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-			n, err := conn.Read(buf)
-			if err != nil {
-				return
-			}
+			conn.Close()
+		case <-done:
+		}
+	}()
 
-			sc.processMessage(string(buf[:n]))
+	maxLen := sc.config.MaxMessageSize
+	if maxLen <= 0 {
+		maxLen = 64 * 1024
+	}
+	reader := bufio.NewReaderSize(conn, 64*1024)
+
+	for {
+		frame, truncated, err := readFrame(reader, sc.config.Framing, maxLen)
+		if truncated {
+			sc.logger().Warn("Truncated message", "max_bytes", maxLen, "remote_addr", conn.RemoteAddr().String())
+			sc.mu.Lock()
+			sc.truncated++
+			sc.mu.Unlock()
+		}
+		if len(frame) > 0 {
+			sc.processMessage(string(frame))
+		}
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				sc.logger().Warn("Dropping connection", "remote_addr", conn.RemoteAddr().String(), "error", err)
+			}
+			return
 		}
 	}
 }
@@ -168,18 +215,31 @@ func (sc *SyslogCollector) Stop() {
 	sc.mu.Unlock()
 }
 
+// Drain waits for connections to emit the frames they were reading, then
+// ships held repeated lines
+func (sc *SyslogCollector) Drain(ctx context.Context) error {
+	if err := waitCtx(ctx, &sc.handlers); err != nil {
+		return err
+	}
+	return sc.BaseCollector.Drain(ctx)
+}
+
 // Stats returns collector statistics
 func (sc *SyslogCollector) Stats() map[string]any {
 	sc.mu.RLock()
 	defer sc.mu.RUnlock()
 
 	return map[string]any{
-		"name":           sc.name,
-		"logs_collected": sc.logsCollected,
-		"errors_count":   sc.errorsCount,
-		"last_collected": sc.lastCollected,
-		"running":        sc.running,
-		"address":        sc.config.Address,
+		"name":              sc.name,
+		"logs_collected":    sc.logsCollected,
+		"errors_count":      sc.errorsCount,
+		"logs_filtered":     atomic.LoadInt64(&sc.logsFiltered),
+		"rate_limited":      sc.limiter.Dropped(),
+		"repeats_collapsed": sc.repeats.collapsedCount(),
+		"last_collected":    sc.lastCollected,
+		"running":           sc.running,
+		"address":           sc.config.Address,
+		"truncated":         sc.truncated,
 	}
 }
 
@@ -190,6 +250,12 @@ type SyslogMessage struct {
 	Hostname  string
 	Tag       string
 	Message   string
+
+	// RFC 5424 only
+	Version        int
+	ProcID         string
+	MsgID          string
+	StructuredData []sdElement
 }
 
 // processMessage processes a syslog message
@@ -234,7 +300,21 @@ func (sc *SyslogCollector) processMessage(text string) {
 		"severity": msg.Priority % 8,
 	}
 
-	if err := sc.sender.Send(entry); err != nil {
+	if msg.Version > 0 {
+		entry.Metadata["syslog_version"] = msg.Version
+		if msg.ProcID != "" {
+			entry.Metadata["proc_id"] = msg.ProcID
+		}
+		if msg.MsgID != "" {
+			entry.Metadata["msg_id"] = msg.MsgID
+		}
+	}
+
+	if len(msg.StructuredData) > 0 {
+		sc.applyStructuredData(&entry, msg.StructuredData)
+	}
+
+	if err := sc.send(entry); err != nil {
 		sc.mu.Lock()
 		sc.errorsCount++
 		sc.mu.Unlock()
@@ -247,7 +327,8 @@ func (sc *SyslogCollector) processMessage(text string) {
 	sc.mu.Unlock()
 }
 
-// parseSyslog parses a syslog message (RFC 3164)
+// parseSyslog parses a syslog message. The protocol setting picks RFC 3164
+// or RFC 5424; unset or "auto" detects 5424 by the version after the priority.
 func (sc *SyslogCollector) parseSyslog(text string) SyslogMessage {
 	msg := SyslogMessage{
 		Message: text,
@@ -264,6 +345,19 @@ func (sc *SyslogCollector) parseSyslog(text string) SyslogMessage {
 		}
 	}
 
+	switch strings.ToLower(sc.config.Protocol) {
+	case "rfc3164":
+	case "rfc5424":
+		// Fall back to RFC 3164 parsing for senders that don't comply
+		if parseSyslog5424(text, &msg) {
+			return msg
+		}
+	default:
+		if looksLike5424(text) && parseSyslog5424(text, &msg) {
+			return msg
+		}
+	}
+
 	// Try to parse timestamp (RFC 3164: "Jan  2 15:04:05")
 	if len(text) >= 15 {
 		if t, err := time.Parse("Jan  2 15:04:05", text[:15]); err == nil {
@@ -296,6 +390,63 @@ func (sc *SyslogCollector) parseSyslog(text string) SyslogMessage {
 	return msg
 }
 
+// looksLike5424 reports whether text, after the priority, starts with an
+// RFC 5424 version: one to three digits followed by a space
+func looksLike5424(text string) bool {
+	i := 0
+	for i < len(text) && i < 3 && text[i] >= '0' && text[i] <= '9' {
+		i++
+	}
+	return i > 0 && i < len(text) && text[i] == ' '
+}
+
+// parseSyslog5424 parses "VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+// STRUCTURED-DATA [MSG]" (after the priority) into msg, reporting whether
+// the header was valid. "-" is the nil value for any header field.
+func parseSyslog5424(text string, msg *SyslogMessage) bool {
+	fields := strings.SplitN(text, " ", 7)
+	if len(fields) < 7 {
+		return false
+	}
+
+	version, err := strconv.Atoi(fields[0])
+	if err != nil || version < 1 {
+		return false
+	}
+
+	sd, rest, ok := parseStructuredData(fields[6])
+	if !ok {
+		return false
+	}
+
+	if fields[1] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return false
+		}
+		msg.Timestamp = t
+	}
+
+	msg.Version = version
+	msg.Hostname = nilValue(fields[2])
+	msg.Tag = nilValue(fields[3])
+	msg.ProcID = nilValue(fields[4])
+	msg.MsgID = nilValue(fields[5])
+	msg.StructuredData = sd
+
+	// A UTF-8 message may start with a byte order mark
+	msg.Message = strings.TrimPrefix(rest, "\ufeff")
+	return true
+}
+
+// nilValue maps the RFC 5424 nil value "-" to an empty string
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
+
 // syslogPriorityToLevel converts syslog priority to log level
 func syslogPriorityToLevel(priority int) string {
 	severity := priority % 8
@@ -314,3 +465,27 @@ func syslogPriorityToLevel(priority int) string {
 		return "INFO"
 	}
 }
+
+// check verifies the listen address is free, releasing it right away
+func (sc *SyslogCollector) check() (string, error) {
+	network, addr := sc.listenAddress()
+	if network == "tcp" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return "", err
+		}
+		ln.Close()
+	} else {
+		conn, err := net.ListenPacket(network, addr)
+		if err != nil {
+			return "", err
+		}
+		conn.Close()
+		// Closing leaves the socket file behind, which would keep the
+		// real listener from binding
+		if network == "unixgram" {
+			os.Remove(addr)
+		}
+	}
+	return "can listen on " + network + " " + addr, nil
+}
@@ -0,0 +1,223 @@
+//go:build linux
+// +build linux
+
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// newTestSyslogCollector creates a syslog collector whose entries stay in
+// a memory buffer
+func newTestSyslogCollector(t *testing.T, cfg config.SyslogCollectorConfig) (*SyslogCollector, buffer.Buffer) {
+	t.Helper()
+
+	snd, buf := newTestSender(t)
+	cfg.Enabled = true
+	return NewSyslogCollector(cfg, snd), buf
+}
+
+// streamFragments writes stream to a TCP connection handler in pieces of
+// the given sizes, cycling through them, and waits for the handler to finish
+func streamFragments(t *testing.T, sc *SyslogCollector, stream string, sizes ...int) {
+	t.Helper()
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc.handleTCPConn(context.Background(), server)
+	}()
+
+	for i := 0; len(stream) > 0; i++ {
+		n := min(sizes[i%len(sizes)], len(stream))
+		if _, err := client.Write([]byte(stream[:n])); err != nil {
+			t.Fatal(err)
+		}
+		stream = stream[n:]
+		time.Sleep(time.Millisecond)
+	}
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection handler didn't return")
+	}
+}
+
+func TestSyslogTCPFragmentedFrames(t *testing.T) {
+	messages := []string{
+		"<34>1 2024-01-02T03:04:05Z host web 42 - - login failed",
+		"<13>Jan  2 03:04:05 host cron[7]: job " + strings.Repeat("z", 200),
+		"<14>1 - host web - - - short",
+	}
+	want := []string{"login failed", "job " + strings.Repeat("z", 200), "short"}
+
+	for _, tc := range []struct {
+		framing string
+		stream  string
+	}{
+		{"octet", octetFramed(messages...)},
+		{"newline", strings.Join(messages, "\n") + "\n"},
+	} {
+		t.Run(tc.framing, func(t *testing.T) {
+			sc, buf := newTestSyslogCollector(t, config.SyslogCollectorConfig{Framing: tc.framing})
+			// Splits land inside the octet count, the header and the message
+			streamFragments(t, sc, tc.stream, 1, 3, 7, 50)
+
+			assertMessages(t, bufferedMessages(t, buf), want)
+			if n := sc.Stats()["truncated"]; n != int64(0) {
+				t.Errorf("truncated = %v, want 0", n)
+			}
+		})
+	}
+}
+
+// processOne runs text through the collector and returns the entry it buffered
+func processOne(t *testing.T, sc *SyslogCollector, buf buffer.Buffer, text string) buffer.LogEntry {
+	t.Helper()
+
+	sc.processMessage(text)
+	entries, err := buf.Pop(buf.Len())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%q buffered %d entries, want 1", text, len(entries))
+	}
+	return entries[0]
+}
+
+func TestSyslogRFC5424Messages(t *testing.T) {
+	sc, buf := newTestSyslogCollector(t, config.SyslogCollectorConfig{})
+
+	for _, tc := range []struct {
+		name     string
+		text     string
+		level    string
+		message  string
+		service  string
+		host     string
+		time     time.Time
+		metadata map[string]any
+	}{
+		{
+			// RFC 5424 section 6.5, example 3
+			name:    "rfc example",
+			text:    `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] ` + "\ufeff" + `An application event log entry...`,
+			level:   "INFO",
+			message: "An application event log entry...",
+			service: "evntslog",
+			host:    "mymachine.example.com",
+			time:    time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+			metadata: map[string]any{
+				"facility": 20, "severity": 5, "msg_id": "ID47",
+				"structured_data": map[string]any{
+					"exampleSDID@32473": map[string]any{"iut": "3", "eventSource": "Application", "eventID": "1011"},
+				},
+			},
+		},
+		{
+			// rsyslog's RSYSLOG_SyslogProtocol23Format template
+			name:     "rsyslog",
+			text:     `<38>1 2024-03-05T10:11:12.345678+01:00 web01 sshd 1234 - - Accepted publickey for deploy from 10.0.0.7 port 52814 ssh2`,
+			level:    "INFO",
+			message:  "Accepted publickey for deploy from 10.0.0.7 port 52814 ssh2",
+			service:  "sshd",
+			host:     "web01",
+			time:     time.Date(2024, 3, 5, 9, 11, 12, 345678000, time.UTC),
+			metadata: map[string]any{"facility": 4, "severity": 6, "proc_id": "1234"},
+		},
+		{
+			// journald forwarded through rsyslog with its own SD elements,
+			// an escaped value and a repeated param
+			name:    "journald",
+			text:    `<27>1 2024-03-05T10:11:12Z db01 postgres 881 - [origin ip="10.0.0.9" software="rsyslogd"][journal unit="postgresql.service" note="a \"quoted\] value" cap="net" cap="sys"] FATAL: role "x" does not exist`,
+			level:   "ERROR",
+			message: `FATAL: role "x" does not exist`,
+			service: "postgres",
+			host:    "db01",
+			time:    time.Date(2024, 3, 5, 10, 11, 12, 0, time.UTC),
+			metadata: map[string]any{
+				"facility": 3, "severity": 3, "proc_id": "881",
+				"structured_data": map[string]any{
+					"origin":  map[string]any{"ip": "10.0.0.9", "software": "rsyslogd"},
+					"journal": map[string]any{"unit": "postgresql.service", "note": `a "quoted] value`, "cap": []string{"net", "sys"}},
+				},
+			},
+		},
+		{
+			name:     "nil header fields",
+			text:     `<15>1 - - - - - - booted`,
+			level:    "DEBUG",
+			message:  "booted",
+			service:  "syslog",
+			metadata: map[string]any{"facility": 1, "severity": 7},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := processOne(t, sc, buf, tc.text)
+
+			if e.Level != tc.level || e.Message != tc.message || e.Service != tc.service {
+				t.Errorf("entry = %s %q from %q, want %s %q from %q", e.Level, e.Message, e.Service, tc.level, tc.message, tc.service)
+			}
+			if got := e.Tags["syslog_hostname"]; got != tc.host {
+				t.Errorf("hostname = %q, want %q", got, tc.host)
+			}
+			if !tc.time.IsZero() && !e.Timestamp.Equal(tc.time) {
+				t.Errorf("timestamp = %s, want %s", e.Timestamp, tc.time)
+			}
+			if e.Metadata["syslog_version"] != 1 {
+				t.Errorf("syslog_version = %v, want 1", e.Metadata["syslog_version"])
+			}
+			for key, want := range tc.metadata {
+				if got := fmt.Sprint(e.Metadata[key]); got != fmt.Sprint(want) {
+					t.Errorf("metadata %s = %s, want %s", key, got, fmt.Sprint(want))
+				}
+			}
+			for _, key := range []string{"proc_id", "msg_id", "structured_data"} {
+				if _, want := tc.metadata[key]; !want && e.Metadata[key] != nil {
+					t.Errorf("unexpected metadata %s = %v", key, e.Metadata[key])
+				}
+			}
+		})
+	}
+}
+
+func TestSyslogStructuredDataTags(t *testing.T) {
+	sc, buf := newTestSyslogCollector(t, config.SyslogCollectorConfig{
+		SDTags: map[string]string{"origin.ip": "client_ip", "unit": ""},
+	})
+
+	e := processOne(t, sc, buf, `<14>1 2024-03-05T10:11:12Z host app - - [origin ip="10.0.0.9" software="rsyslogd"][journal unit="a.service" unit="b.service"] started`)
+
+	if e.Tags["client_ip"] != "10.0.0.9" || e.Tags["unit"] != "a.service,b.service" {
+		t.Errorf("tags = %v", e.Tags)
+	}
+	want := map[string]any{"origin": map[string]any{"software": "rsyslogd"}}
+	if got := fmt.Sprint(e.Metadata["structured_data"]); got != fmt.Sprint(want) {
+		t.Errorf("structured_data = %s, want %s", got, fmt.Sprint(want))
+	}
+}
+
+func TestSyslogRFC5424FallsBackTo3164(t *testing.T) {
+	sc, buf := newTestSyslogCollector(t, config.SyslogCollectorConfig{Protocol: "rfc5424"})
+
+	// A bad timestamp makes the header invalid
+	e := processOne(t, sc, buf, `<13>1 yesterday host app - - - text`)
+	if e.Metadata["syslog_version"] != nil {
+		t.Errorf("parsed as RFC 5424: %v", e.Metadata)
+	}
+	if e.Message == "" {
+		t.Error("message lost")
+	}
+}
@@ -0,0 +1,148 @@
+//go:build linux
+// +build linux
+
+package collector
+
+import (
+	"strings"
+
+	"logchat/agent/internal/buffer"
+)
+
+// sdElement is one RFC 5424 structured-data element, e.g.
+// [origin@123 app_id="web" tenant="acme"]. Params keep their order and may
+// repeat, as the RFC allows.
+type sdElement struct {
+	ID     string
+	Params []sdParam
+}
+
+type sdParam struct {
+	Name  string
+	Value string
+}
+
+// parseStructuredData parses the STRUCTURED-DATA field at the start of text
+// and returns the elements and the remaining text. A "-" is the nil value.
+func parseStructuredData(text string) ([]sdElement, string, bool) {
+	if strings.HasPrefix(text, "-") {
+		return nil, strings.TrimPrefix(text[1:], " "), true
+	}
+
+	var elements []sdElement
+	for strings.HasPrefix(text, "[") {
+		elem, rest, ok := parseSDElement(text[1:])
+		if !ok {
+			return nil, text, false
+		}
+		elements = append(elements, elem)
+		text = rest
+	}
+	if elements == nil {
+		return nil, text, false
+	}
+	return elements, strings.TrimPrefix(text, " "), true
+}
+
+// parseSDElement parses `SD-ID *(SP PARAM-NAME="value")]`, the opening
+// bracket already consumed
+func parseSDElement(text string) (sdElement, string, bool) {
+	end := strings.IndexAny(text, " ]")
+	if end <= 0 {
+		return sdElement{}, text, false
+	}
+	elem := sdElement{ID: text[:end]}
+	text = text[end:]
+
+	for {
+		if strings.HasPrefix(text, "]") {
+			return elem, text[1:], true
+		}
+		if !strings.HasPrefix(text, " ") {
+			return sdElement{}, text, false
+		}
+		text = text[1:]
+
+		eq := strings.Index(text, `="`)
+		if eq <= 0 {
+			return sdElement{}, text, false
+		}
+		name := text[:eq]
+		text = text[eq+2:]
+
+		// Values escape '"', '\' and ']' with a backslash
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) && strings.IndexByte(`"\]`, text[i+1]) >= 0 {
+				value.WriteByte(text[i+1])
+				i++
+				continue
+			}
+			if c == '"' {
+				text = text[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return sdElement{}, text, false
+		}
+		elem.Params = append(elem.Params, sdParam{Name: name, Value: value.String()})
+	}
+}
+
+// applyStructuredData promotes params selected by sd_tags to tags and
+// keeps the rest in metadata under "structured_data", keyed by SD-ID.
+// Repeated params become a list in metadata and a comma-joined tag.
+func (sc *SyslogCollector) applyStructuredData(entry *buffer.LogEntry, elements []sdElement) {
+	data := make(map[string]any)
+
+	for _, elem := range elements {
+		params := make(map[string]any)
+		for _, p := range elem.Params {
+			if tag, ok := sc.sdTag(elem.ID, p.Name); ok {
+				if prev, exists := entry.Tags[tag]; exists && prev != "" {
+					entry.Tags[tag] = prev + "," + p.Value
+				} else {
+					entry.Tags[tag] = p.Value
+				}
+				continue
+			}
+
+			switch prev := params[p.Name].(type) {
+			case nil:
+				params[p.Name] = p.Value
+			case string:
+				params[p.Name] = []string{prev, p.Value}
+			case []string:
+				params[p.Name] = append(prev, p.Value)
+			}
+		}
+		if len(params) > 0 || len(elem.Params) == 0 {
+			data[elem.ID] = params
+		}
+	}
+
+	if len(data) > 0 {
+		entry.Metadata["structured_data"] = data
+	}
+}
+
+// sdTag returns the tag name for a param if sd_tags selects it. An
+// "sd-id.param" key takes precedence over a bare param name.
+func (sc *SyslogCollector) sdTag(id, name string) (string, bool) {
+	tag, ok := sc.config.SDTags[id+"."+name]
+	if !ok {
+		tag, ok = sc.config.SDTags[name]
+	}
+	if !ok {
+		return "", false
+	}
+	if tag == "" {
+		tag = name
+	}
+	return tag, true
+}
@@ -1,13 +1,20 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,16 +24,174 @@ type Config struct {
 	Agent      AgentConfig      `yaml:"agent"`
 	Buffer     BufferConfig     `yaml:"buffer"`
 	Collectors CollectorsConfig `yaml:"collectors"`
+	Metrics    *MetricsConfig   `yaml:"metrics"`
+
+	// Include lists more config files or globs, relative to the including
+	// file, merged in sorted order after it. Their collectors are appended;
+	// other values override earlier ones.
+	Include []string `yaml:"include"`
+}
+
+// MetricsConfig for the Prometheus metrics endpoint
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"` // Listen address (default 127.0.0.1:9464)
+	Path    string `yaml:"path"`    // Default /metrics
 }
 
 // ServerConfig contains LogChat server connection settings
 type ServerConfig struct {
 	URL           string        `yaml:"url"`
+	FallbackURLs  []string      `yaml:"fallback_urls"` // Tried in order when the primary is down
+	Format        string        `yaml:"format"`        // "logchat" (default), "loki" or "otlp"
+	Loki          *LokiConfig   `yaml:"loki"`          // Push settings when format is loki
+	OTLP          *OTLPConfig   `yaml:"otlp"`          // Export settings when format is otlp
 	APIKey        string        `yaml:"api_key"`
+	APIKeyFile    string        `yaml:"api_key_file"` // Read the key from a file (e.g. a mounted secret)
 	Timeout       time.Duration `yaml:"timeout"`
-	Insecure      bool          `yaml:"insecure"` // Skip TLS verification
+	Insecure      bool          `yaml:"insecure"`  // Skip TLS verification
+	CAFile        string        `yaml:"ca_file"`   // PEM bundle to verify the server with, default system roots
+	CertFile      string        `yaml:"cert_file"` // Client certificate for mutual TLS
+	KeyFile       string        `yaml:"key_file"`  // Client certificate key
+	Proxy         string        `yaml:"proxy"`     // http://, https:// or socks5:// URL; "none" = direct; empty = HTTP(S)_PROXY env
 	BatchSize     int           `yaml:"batch_size"`
 	FlushInterval time.Duration `yaml:"flush_interval"`
+	MaxInFlight   int           `yaml:"max_in_flight"` // Concurrent ingest requests, 0 = unlimited
+
+	// SigningKey, when set, signs each ingest request with HMAC-SHA256 over
+	// "<timestamp>.<body>", sent as X-Signature (hex) along with the
+	// timestamp as X-Signature-Timestamp (Unix seconds). The body is the
+	// bytes sent, compressed if compression applies.
+	SigningKey string `yaml:"signing_key"`
+
+	// Basic auth is sent instead of the bearer token for gateways that want
+	// it. Headers are added to every ingest and health request, e.g. a
+	// tenant ID.
+	BasicAuthUser     string            `yaml:"basic_auth_user"`
+	BasicAuthPassword string            `yaml:"basic_auth_password"`
+	Headers           map[string]string `yaml:"headers"`
+
+	// SendConcurrency posts up to this many batches of a buffer in parallel
+	// (default 1). A failed batch is retried along with any later batch of
+	// the same round, even if that one was delivered: at least once, not
+	// exactly once, unless dedup is enabled.
+	SendConcurrency int `yaml:"send_concurrency"`
+
+	// MaxBatchBytes also ends a batch before it exceeds this many bytes of
+	// encoded entries, below batch_size if need be, 0 = no limit
+	MaxBatchBytes int `yaml:"max_batch_bytes"`
+
+	// Retry delay after a failed send, doubling per consecutive failure
+	BackoffBase time.Duration `yaml:"backoff_base"` // Default 1s
+	MaxBackoff  time.Duration `yaml:"max_backoff"`  // Default 2m
+
+	// MaxRetries gives up on a batch after this many failed retries, so a
+	// batch that can't be delivered doesn't block the buffer forever
+	// (0 = retry forever). GiveUp is dead_letter, the default when
+	// dead_letter_dir is set, or drop.
+	MaxRetries int    `yaml:"max_retries"`
+	GiveUp     string `yaml:"give_up"`
+
+	SortBatchByTime bool `yaml:"sort_batch_by_time"` // Order each batch by timestamp before sending
+
+	Compression      string `yaml:"compression"`        // "gzip" or "none" (default)
+	CompressMinBytes int    `yaml:"compress_min_bytes"` // Smaller payloads are sent as-is (default 1024)
+
+	// Connection handling
+	HTTP2           *bool         `yaml:"http2"`             // Enable/disable HTTP/2, unset = auto
+	KeepAlive       time.Duration `yaml:"keep_alive"`        // TCP keep-alive period, 0 = OS default
+	IdleConnTimeout time.Duration `yaml:"idle_conn_timeout"` // Close idle connections after (default 30s)
+	MaxConnAge      time.Duration `yaml:"max_conn_age"`      // Drop pooled connections this often, 0 = never
+
+	Dedup *DedupConfig `yaml:"dedup"` // Skip entries already delivered before a restart
+
+	Health *HealthConfig `yaml:"health"` // What counts as a healthy server
+
+	// IngestPath is where batches are POSTed on each server URL (default
+	// /api/logs/ingest, or the Loki and OTLP endpoints for those formats)
+	IngestPath string `yaml:"ingest_path"`
+
+	// HealthPath is the health endpoint probed on each server URL (default
+	// /api/health, or the Loki and OTLP endpoints for those formats).
+	// HealthInterval is how often it is probed, default 30s; 0 disables
+	// health checks and the server is assumed reachable.
+	HealthPath     string         `yaml:"health_path"`
+	HealthInterval *time.Duration `yaml:"health_interval"`
+
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuit_breaker"` // Pause sends while the server is down
+
+	Ledger *LedgerConfig `yaml:"ledger"` // Tamper-evident record of shipped batches
+
+	// DeadLetterDir receives entries the server refuses with a 4xx (other
+	// than 401, 403, 404, 408 and 429) as JSON files, so they stop blocking
+	// the buffer. Unset, refused batches are retried like any failure.
+	DeadLetterDir string `yaml:"dead_letter_dir"`
+
+	// Classes give collectors tagged with a delivery class their own batching
+	Classes map[string]DeliveryClassConfig `yaml:"classes"`
+
+	// AdditionalServers each receive a copy of every entry (fan-out), with
+	// their own buffer and retry state so a failing one only backs up itself
+	AdditionalServers []OutputConfig `yaml:"additional_servers"`
+}
+
+// OutputConfig is an additional destination. Batching, timeouts and
+// connection settings are taken from the primary server.
+type OutputConfig struct {
+	Name       string        `yaml:"name"` // Label in stats and logs, default the URL
+	URL        string        `yaml:"url"`
+	Format     string        `yaml:"format"` // "logchat" (default), "loki" or "otlp"
+	Loki       *LokiConfig   `yaml:"loki"`
+	OTLP       *OTLPConfig   `yaml:"otlp"`
+	APIKey     string        `yaml:"api_key"`
+	APIKeyFile string        `yaml:"api_key_file"`
+	SigningKey string        `yaml:"signing_key"`
+	Insecure   bool          `yaml:"insecure"`
+	CAFile     string        `yaml:"ca_file"`
+	CertFile   string        `yaml:"cert_file"`
+	KeyFile    string        `yaml:"key_file"`
+	IngestPath string        `yaml:"ingest_path"` // Default per format, not the primary's
+	HealthPath string        `yaml:"health_path"`
+	Buffer     *BufferConfig `yaml:"buffer"` // Default in-memory; unset limits follow the main buffer
+
+	// Auth and headers, not inherited from the primary
+	BasicAuthUser     string            `yaml:"basic_auth_user"`
+	BasicAuthPassword string            `yaml:"basic_auth_password"`
+	Headers           map[string]string `yaml:"headers"`
+}
+
+// LokiConfig shapes batches pushed to Grafana Loki's /loki/api/v1/push
+type LokiConfig struct {
+	// Labels become each stream's label set: service, level, hostname,
+	// source, environment, or the name of a tag. Every distinct combination
+	// is a separate stream, so keep high-cardinality values out.
+	// Default [service, hostname, level].
+	Labels []string `yaml:"labels"`
+
+	LineFormat string `yaml:"line_format"` // "message" (default) or "json" for the whole entry
+	TenantID   string `yaml:"tenant_id"`   // Sent as X-Scope-OrgID for multi-tenant Loki
+}
+
+// OTLPConfig for exporting to an OpenTelemetry collector's OTLP/HTTP
+// /v1/logs endpoint
+type OTLPConfig struct {
+	Encoding string `yaml:"encoding"` // "protobuf" (default) or "json"
+}
+
+// HealthConfig sets what a healthy health-endpoint response looks like
+type HealthConfig struct {
+	StatusCodes []int  `yaml:"status_codes"` // Accepted status codes (default [200])
+	BodyMatch   string `yaml:"body_match"`   // Substring the body must contain, empty = any
+}
+
+// DeliveryClassConfig overrides batching for entries of one delivery class
+type DeliveryClassConfig struct {
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	// Buffer gives the class its own buffer type, e.g. a durable file buffer
+	// for audit logs. Unset = in-memory buffer.
+	Buffer *BufferConfig `yaml:"buffer"`
 }
 
 // AgentConfig contains agent identification settings
@@ -34,89 +199,510 @@ type AgentConfig struct {
 	Hostname    string            `yaml:"hostname"`
 	Environment string            `yaml:"environment"`
 	Tags        map[string]string `yaml:"tags"`
-	LogLevel    string            `yaml:"log_level"`
+	LogLevel    string            `yaml:"log_level"`  // debug, info (default), warn, error
+	LogFormat   string            `yaml:"log_format"` // text (default) or json, for collecting the agent's own output
+	MinLevel    string            `yaml:"min_level"`  // Drop entries below DEBUG < INFO < WARN < ERROR < FATAL
+
+	Processors []ProcessorConfig `yaml:"processors"` // Applied to every entry before buffering
+
+	SchemaReport *SchemaReportConfig `yaml:"schema_report"` // Periodic metadata field report
+
+	EmptyMessage *EmptyMessageConfig `yaml:"empty_message"` // Handling of entries with no message
+
+	Redact *RedactConfig `yaml:"redact"` // Mask sensitive values before they leave the host
+
+	CPUThrottle *CPUThrottleConfig `yaml:"cpu_throttle"` // Shed work when the agent uses too much CPU
+
+	RateLimit *RateLimitConfig `yaml:"rate_limit"` // Cap on entries per second across all collectors
+
+	Sampling *SamplingConfig `yaml:"sampling"` // Keep only a share of chatty entries
+
+	// ShutdownTimeout bounds stopping and draining collectors and the final
+	// flush on exit
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // Default 30s
+
+	// PIDFile records the agent's PID while it runs; startup fails if a
+	// running process already holds it
+	PIDFile string `yaml:"pid_file"`
+}
+
+// CPUThrottleConfig for the agent's CPU self-throttle
+type CPUThrottleConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxPercent    float64       `yaml:"max_percent"`    // Percent of one core that starts throttling (default 50)
+	ResumePercent float64       `yaml:"resume_percent"` // Throttling stops below this (default 80% of max)
+	Interval      time.Duration `yaml:"interval"`       // CPU sample interval (default 5s)
+}
+
+// SamplingConfig keeps a share of entries to cut the volume of chatty
+// streams. Set percent or one_in; levels override it per level.
+type SamplingConfig struct {
+	Enabled bool               `yaml:"enabled"`
+	Percent float64            `yaml:"percent"` // Share of entries kept, 0-100
+	OneIn   int                `yaml:"one_in"`  // Or keep 1 in N entries
+	Levels  map[string]float64 `yaml:"levels"`  // Percent kept per level (default ERROR and FATAL: 100)
+}
+
+// EmptyMessageConfig sets what happens to entries with a blank message
+type EmptyMessageConfig struct {
+	Policy   string `yaml:"policy"`   // keep, drop, synthesize (default)
+	Template string `yaml:"template"` // synthesize: e.g. "{service} event {event_id}", empty = metadata key=value pairs
+}
+
+// RedactConfig masks sensitive values in entries before buffering
+type RedactConfig struct {
+	Presets     []string     `yaml:"presets"`     // Built-in rules: email, ipv4, credit_card
+	Rules       []RedactRule `yaml:"rules"`       // Custom regex rules, applied after the presets
+	Placeholder string       `yaml:"placeholder"` // Replacement for presets and rules without one (default "***")
+	Metadata    bool         `yaml:"metadata"`    // Also redact metadata string values
+}
+
+// RedactRule replaces matches of a regex
+type RedactRule struct {
+	Pattern     string `yaml:"pattern"`     // With capture groups, only the groups are replaced
+	Replacement string `yaml:"replacement"` // Literal text, default the placeholder
+}
+
+// SchemaReportConfig for the periodic metadata field-size report
+type SchemaReportConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Interval    time.Duration `yaml:"interval"`     // How often to emit the report (default 1h)
+	SampleEvery int           `yaml:"sample_every"` // Inspect one in N entries (default 10)
+}
+
+// ProcessorConfig configures an entry processor
+type ProcessorConfig struct {
+	// Type is split_by_field, normalize_level, cardinality_limit or compact_stack_traces
+	Type      string `yaml:"type"`
+	Field     string `yaml:"field"`      // Metadata field to operate on
+	MaxFanout int    `yaml:"max_fanout"` // split_by_field: max entries per record (default 100)
+
+	// normalize_level: extra variant -> canonical level mappings
+	Mapping map[string]string `yaml:"mapping"`
+
+	// cardinality_limit: max distinct values per dimension (service, tags.<name>)
+	Limits map[string]int `yaml:"limits"`
+
+	// compact_stack_traces: repeats within Window ship a reference instead of
+	// the frames; the full trace is resent every FullEvery occurrences
+	Window     time.Duration `yaml:"window"`      // Default 10m
+	FullEvery  int           `yaml:"full_every"`  // Default 100
+	MaxTracked int           `yaml:"max_tracked"` // Distinct traces remembered (default 1000)
 }
 
 // BufferConfig contains local buffer settings
 type BufferConfig struct {
-	Type     string `yaml:"type"`      // memory, file
-	Path     string `yaml:"path"`      // For file buffer
+	Type     string `yaml:"type"`      // memory, file, sqlite (needs -tags sqlite)
+	Path     string `yaml:"path"`      // Directory for file buffer, database file or directory for sqlite
 	MaxSize  int64  `yaml:"max_size"`  // Max buffer size in bytes
 	MaxItems int    `yaml:"max_items"` // Max number of items
+
+	// PersistInterval snapshots the memory buffer to Path periodically, 0 = disabled
+	PersistInterval time.Duration `yaml:"persist_interval"`
+
+	// MaxDiskBytes caps everything the agent writes under Path (buffer, ledger,
+	// backups, dead letters). Backups go first, then the oldest entries. 0 = unlimited
+	MaxDiskBytes int64 `yaml:"max_disk_bytes"`
+
+	// CompactThreshold rewrites the file buffer's append-only log once this
+	// many bytes of it are already delivered (default 8MB)
+	CompactThreshold int64 `yaml:"compact_threshold"`
+}
+
+// CircuitBreakerConfig stops flushing after repeated failures until a
+// health check sees the server back
+type CircuitBreakerConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Failures int  `yaml:"failures"` // Consecutive failed sends that open the breaker (default 5)
+}
+
+// DedupConfig for the persistent filter of delivered entries
+type DedupConfig struct {
+	Enabled           bool    `yaml:"enabled"`
+	Path              string  `yaml:"path"`                // Filter state file
+	Capacity          int     `yaml:"capacity"`            // Entries remembered per filter generation
+	FalsePositiveRate float64 `yaml:"false_positive_rate"` // Chance of dropping a new entry as a duplicate
+}
+
+// LedgerConfig for the hash-chained ledger of shipped batches
+type LedgerConfig struct {
+	Enabled          bool   `yaml:"enabled"`
+	Path             string `yaml:"path"`               // Ledger file (default <buffer path>/ledger.jsonl)
+	MaxSize          int64  `yaml:"max_size"`           // Rotate to .1 past this size (default 10MB)
+	IncludeInPayload bool   `yaml:"include_in_payload"` // Send each batch's record so the server can verify continuity
 }
 
 // CollectorsConfig contains all collector configurations
 type CollectorsConfig struct {
 	Files    []FileCollectorConfig    `yaml:"files"`
-	Syslog   *SyslogCollectorConfig   `yaml:"syslog"`
+	Syslog   SyslogCollectorConfigs   `yaml:"syslog"`
 	Journald *JournaldCollectorConfig `yaml:"journald"`
 	EventLog *EventLogCollectorConfig `yaml:"eventlog"`
 	Docker   *DockerCollectorConfig   `yaml:"docker"`
+	Podman   *DockerCollectorConfig   `yaml:"podman"` // Same options; socket auto-detected
 	Command  []CommandCollectorConfig `yaml:"command"`
+	Stdin    *StdinCollectorConfig    `yaml:"stdin"`
+	HTTP     *HTTPCollectorConfig     `yaml:"http"`
+	Net      []NetCollectorConfig     `yaml:"net"`
+
+	Kubernetes *KubernetesCollectorConfig `yaml:"kubernetes"`
+
+	Heartbeat *HeartbeatConfig `yaml:"heartbeat"` // Periodic liveness entry
 }
 
 // FileCollectorConfig for file-based log collection
 type FileCollectorConfig struct {
-	Enabled    bool              `yaml:"enabled"`
-	Paths      []string          `yaml:"paths"`
-	Exclude    []string          `yaml:"exclude"`
-	Recursive  bool              `yaml:"recursive"`
-	Service    string            `yaml:"service"`
-	Multiline  *MultilineConfig  `yaml:"multiline"`
-	Parser     string            `yaml:"parser"` // json, regex, plain
-	ParseRegex string            `yaml:"parse_regex"`
-	Tags       map[string]string `yaml:"tags"`
+	Enabled       bool              `yaml:"enabled"`
+	Paths         []string          `yaml:"paths"`
+	Exclude       []string          `yaml:"exclude"`
+	Recursive     bool              `yaml:"recursive"`
+	Service       string            `yaml:"service"`
+	Class         string            `yaml:"class"`          // Delivery class, see server.classes
+	SchemaVersion string            `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+	MinLevel      string            `yaml:"min_level"`      // Drop entries below this level
+	RateLimit     *RateLimitConfig  `yaml:"rate_limit"`     // Cap on entries per second
+	RepeatWindow  time.Duration     `yaml:"repeat_window"`  // Collapse identical consecutive lines (0 = off)
+	Multiline     *MultilineConfig  `yaml:"multiline"`
+	Parser        string            `yaml:"parser"` // json, logfmt, csv, cri, docker-json, regex, bracketed, plain
+	ParseRegex    string            `yaml:"parse_regex"`
+	Tags          map[string]string `yaml:"tags"`
+
+	// BracketFields names the leading [...] segments for the bracketed parser
+	BracketFields []string `yaml:"bracket_fields"`
+
+	// TimeFormat lists the Go layouts tried in order for parsed timestamp
+	// fields, or "unix" / "unix_ms" for numeric epochs. A single string is
+	// accepted. Unset, RFC 3339 and a few common layouts are tried.
+	TimeFormat StringList `yaml:"time_format"`
+
+	// Encoding is the files' character encoding: utf-8, utf-16le, utf-16be,
+	// latin1 (iso-8859-1) or windows-1252. Lines are converted to UTF-8 with
+	// invalid sequences replaced by U+FFFD. A byte order mark at the start of
+	// a file selects UTF-8 or UTF-16 either way; unset, other files are read
+	// as is.
+	Encoding string `yaml:"encoding"`
+
+	// CSV parser settings. Without csv_columns the first row of each file
+	// is read as its header. The column options fill the entry's core fields.
+	CSVColumns         []string `yaml:"csv_columns"`
+	CSVDelimiter       string   `yaml:"csv_delimiter"` // Single character, default ","
+	CSVMessageColumn   string   `yaml:"csv_message_column"`
+	CSVLevelColumn     string   `yaml:"csv_level_column"`
+	CSVTimestampColumn string   `yaml:"csv_timestamp_column"`
+
+	// OnChange polls whole files and emits only when a field's value changes
+	OnChange *OnChangeConfig `yaml:"on_change"`
+
+	// IncludeOffset stamps file_path and file_offset (the line's starting
+	// byte position) into each entry's metadata for traceability
+	IncludeOffset bool `yaml:"include_offset"`
+
+	// DeletedGracePeriod is how long a deleted file may stay missing before
+	// its tailer is closed to release the descriptor (default 5m)
+	DeletedGracePeriod time.Duration `yaml:"deleted_grace_period"`
+
+	// MaxOpenFiles caps the files tailed at once (0 = no limit). Files past
+	// the cap wait until a tail ends and a re-scan picks them up.
+	MaxOpenFiles int `yaml:"max_open_files"`
+
+	// ReadFrom is where files without a checkpoint start being read on the
+	// first scan: "end" (default) or "beginning" to ingest existing contents
+	ReadFrom string `yaml:"read_from"`
+
+	// IncludeRotated also reads the rotated copies found next to each tailed
+	// file at startup (app.log.1, app.log.2.gz), once, to backfill history
+	IncludeRotated bool `yaml:"include_rotated"`
+
+	// DiscoveryInterval is how often paths are re-scanned for files created
+	// after startup, such as date-stamped logs (default 30s)
+	DiscoveryInterval time.Duration `yaml:"discovery_interval"`
+
+	// CheckpointDir persists per-file read offsets so a restart resumes where
+	// the last run stopped instead of at the end of each file
+	CheckpointDir string `yaml:"checkpoint_dir"`
+
+	// CheckpointReportInterval emits each tailed file's offset and lag
+	// (size minus offset) as agent telemetry this often, 0 = off
+	CheckpointReportInterval time.Duration `yaml:"checkpoint_report_interval"`
+}
+
+// HTTPCollectorConfig for receiving JSON logs POSTed by local apps
+type HTTPCollectorConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Address       string            `yaml:"address"`       // Listen address (default "127.0.0.1:9880")
+	Path          string            `yaml:"path"`          // Default "/logs"
+	APIKey        string            `yaml:"api_key"`       // Required from clients when set
+	APIKeyFile    string            `yaml:"api_key_file"`  // Read the key from a file
+	MaxBodySize   int64             `yaml:"max_body_size"` // Bytes per request (default 1MB)
+	Service       string            `yaml:"service"`       // Default "http"
+	Class         string            `yaml:"class"`
+	SchemaVersion string            `yaml:"schema_version"`
+	MinLevel      string            `yaml:"min_level"`
+	RateLimit     *RateLimitConfig  `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow  time.Duration     `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+	Tags          map[string]string `yaml:"tags"`
+}
+
+// NetCollectorConfig for plain newline-delimited text streamed to a TCP or
+// UDP port, without syslog framing or priorities
+type NetCollectorConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Address       string            `yaml:"address"`       // tcp://0.0.0.0:5140 or udp://0.0.0.0:5140
+	MaxLineSize   int               `yaml:"max_line_size"` // Longer lines are truncated (default 64KB)
+	Service       string            `yaml:"service"`       // Default "net"
+	Class         string            `yaml:"class"`
+	SchemaVersion string            `yaml:"schema_version"`
+	MinLevel      string            `yaml:"min_level"`
+	RateLimit     *RateLimitConfig  `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow  time.Duration     `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+	Tags          map[string]string `yaml:"tags"`
+}
+
+// KubernetesCollectorConfig for container logs on a node, read from the
+// kubelet's CRI log files when the agent runs as a DaemonSet
+type KubernetesCollectorConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Paths         []string          `yaml:"paths"` // Default /var/log/pods/*/*/*.log
+	Exclude       []string          `yaml:"exclude"`
+	Service       string            `yaml:"service"` // Default: the container name
+	Class         string            `yaml:"class"`
+	SchemaVersion string            `yaml:"schema_version"`
+	MinLevel      string            `yaml:"min_level"`
+	RateLimit     *RateLimitConfig  `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow  time.Duration     `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+	Multiline     *MultilineConfig  `yaml:"multiline"`
+	ReadFrom      string            `yaml:"read_from"` // end (default) or beginning
+	Tags          map[string]string `yaml:"tags"`
+
+	// CheckpointDir persists per-file offsets so restarts don't duplicate
+	// (default <buffer path>/k8s-checkpoints)
+	CheckpointDir string `yaml:"checkpoint_dir"`
+
+	MaxOpenFiles int `yaml:"max_open_files"` // Cap on container logs tailed at once, 0 = no limit
+
+	// Labels fetches each pod's labels, from the kubelet when kubelet_url
+	// is set and otherwise from the API server using the in-cluster
+	// service account
+	Labels             bool          `yaml:"labels"`
+	APIServer          string        `yaml:"api_server"`  // Default https://$KUBERNETES_SERVICE_HOST:$KUBERNETES_SERVICE_PORT
+	KubeletURL         string        `yaml:"kubelet_url"` // e.g. https://${NODE_IP}:10250
+	TokenFile          string        `yaml:"token_file"`  // Default: the service account token
+	CAFile             string        `yaml:"ca_file"`     // Default: the service account CA
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	LabelsTTL          time.Duration `yaml:"labels_ttl"` // How long fetched labels are reused (default 5m)
+}
+
+// RateLimitConfig caps the entries per second from a collector, or from all
+// of them when set under agent
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate"`  // Entries per second, 0 = unlimited
+	Burst int     `yaml:"burst"` // Entries allowed at once above the rate (default: rate)
+	Mode  string  `yaml:"mode"`  // "drop" (default) excess entries, or "block" until allowed
+}
+
+// HeartbeatConfig for the periodic agent liveness entry
+type HeartbeatConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Interval time.Duration     `yaml:"interval"` // Default 60s
+	Class    string            `yaml:"class"`
+	Tags     map[string]string `yaml:"tags"`
+}
+
+// StdinCollectorConfig for reading log lines piped into the agent
+type StdinCollectorConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Service       string            `yaml:"service"` // Default "stdin"
+	Class         string            `yaml:"class"`
+	SchemaVersion string            `yaml:"schema_version"`
+	MinLevel      string            `yaml:"min_level"`
+	RateLimit     *RateLimitConfig  `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow  time.Duration     `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+	Parser        string            `yaml:"parser"`        // json, logfmt, csv, docker-json, regex, bracketed, plain
+	ParseRegex    string            `yaml:"parse_regex"`
+	BracketFields []string          `yaml:"bracket_fields"`
+	TimeFormat    StringList        `yaml:"time_format"`
+	Tags          map[string]string `yaml:"tags"`
+	MaxLineSize   int               `yaml:"max_line_size"` // Longer lines are truncated (default 1MB)
+}
+
+// OnChangeConfig turns state files or command output into change events
+type OnChangeConfig struct {
+	Field    string        `yaml:"field"`    // JSON path or key to compare, empty = whole content
+	Interval time.Duration `yaml:"interval"` // File poll interval (default 10s)
 }
 
 // MultilineConfig for handling multiline logs
 type MultilineConfig struct {
-	Pattern string `yaml:"pattern"`
-	Negate  bool   `yaml:"negate"`
-	Match   string `yaml:"match"` // after, before
+	Pattern  string        `yaml:"pattern"`   // Regex identifying continuation lines
+	Negate   bool          `yaml:"negate"`    // Lines NOT matching the pattern are continuations
+	Match    string        `yaml:"match"`     // after, before
+	Timeout  time.Duration `yaml:"timeout"`   // Flush a pending entry after this idle time (default 2s)
+	MaxLines int           `yaml:"max_lines"` // Lines per entry before forcing a flush (default 500)
 }
 
 // SyslogCollectorConfig for syslog collection (Linux)
 type SyslogCollectorConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	Address  string `yaml:"address"`  // unix:///dev/log, udp://0.0.0.0:514
-	Protocol string `yaml:"protocol"` // rfc3164, rfc5424
-	Service  string `yaml:"service"`
+	Enabled        bool   `yaml:"enabled"`
+	Address        string `yaml:"address"`          // unix:///dev/log, udp://0.0.0.0:514
+	Protocol       string `yaml:"protocol"`         // auto (default), rfc3164, rfc5424
+	Framing        string `yaml:"framing"`          // TCP framing: auto (default), octet, newline
+	MaxMessageSize int    `yaml:"max_message_size"` // Longer TCP messages are truncated (default 64KB)
+	Service        string `yaml:"service"`
+	Class          string `yaml:"class"`
+	SchemaVersion  string `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+	MinLevel       string `yaml:"min_level"`      // Drop entries below this level
+
+	RateLimit    *RateLimitConfig `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow time.Duration    `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+
+	// SDTags promotes RFC 5424 structured-data params to tags. Keys are a
+	// param name, or "sd-id.param" for one element; values rename the tag
+	// (empty keeps the param name). Other params stay in metadata.
+	SDTags map[string]string `yaml:"sd_tags"`
+}
+
+// SyslogCollectorConfigs holds one or more syslog listeners. It accepts
+// either a single mapping or a list in YAML.
+type SyslogCollectorConfigs []SyslogCollectorConfig
+
+// UnmarshalYAML decodes a single listener or a list of listeners
+func (s *SyslogCollectorConfigs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var list []SyslogCollectorConfig
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*s = list
+		return nil
+	}
+
+	var single SyslogCollectorConfig
+	if err := node.Decode(&single); err != nil {
+		return err
+	}
+	*s = SyslogCollectorConfigs{single}
+	return nil
+}
+
+// StringList is a list of strings that also accepts a single string in YAML
+type StringList []string
+
+// UnmarshalYAML decodes a single string or a list of strings
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+
+	var single string
+	if err := node.Decode(&single); err != nil {
+		return err
+	}
+	*l = StringList{single}
+	return nil
 }
 
 // JournaldCollectorConfig for systemd journal (Linux)
 type JournaldCollectorConfig struct {
-	Enabled  bool     `yaml:"enabled"`
-	Units    []string `yaml:"units"` // Specific units to collect
-	Since    string   `yaml:"since"` // How far back to collect
-	Service  string   `yaml:"service"`
-	Priority int      `yaml:"priority"` // 0-7, collect this level and above
+	Enabled       bool     `yaml:"enabled"`
+	Units         []string `yaml:"units"` // Specific units to collect
+	Since         string   `yaml:"since"` // How far back to collect
+	Service       string   `yaml:"service"`
+	Class         string   `yaml:"class"`
+	SchemaVersion string   `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+	Priority      int      `yaml:"priority"`       // 0-7, collect this level and above
+	Slices        []string `yaml:"slices"`         // _SYSTEMD_SLICE values, e.g. machine.slice
+	CGroups       []string `yaml:"cgroups"`        // _SYSTEMD_CGROUP prefixes, e.g. /machine.slice
+
+	RateLimit    *RateLimitConfig `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow time.Duration    `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+
+	// Matches are journal field matches such as "_COMM=sshd". Matches on
+	// the same field are OR'ed, different fields AND'ed, and a "+" entry
+	// starts an alternative group, as with journalctl.
+	Matches []string `yaml:"matches"`
+
+	// MaxLineSize caps a single journal record in bytes; longer records are
+	// truncated instead of stopping collection (default 4MB)
+	MaxLineSize int `yaml:"max_line_size"`
+
+	// CheckpointDir saves the last processed journal cursor so a restart
+	// resumes right after it; since then only applies to the first run
+	CheckpointDir string `yaml:"checkpoint_dir"`
 }
 
 // EventLogCollectorConfig for Windows Event Log
 type EventLogCollectorConfig struct {
-	Enabled  bool     `yaml:"enabled"`
-	Channels []string `yaml:"channels"` // Application, System, Security, etc.
-	Query    string   `yaml:"query"`    // XPath query
-	Service  string   `yaml:"service"`
+	Enabled       bool     `yaml:"enabled"`
+	Channels      []string `yaml:"channels"` // Application, System, Security, etc.
+	Query         string   `yaml:"query"`    // XPath query
+	Service       string   `yaml:"service"`
+	Class         string   `yaml:"class"`
+	SchemaVersion string   `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+
+	RateLimit    *RateLimitConfig `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow time.Duration    `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+
+	// BackfillCount ships the most recent N events per channel at startup
+	BackfillCount int `yaml:"backfill_count"`
+
+	// CheckpointDir saves each channel's position so a restart resumes
+	// after the last event read; backfill_count only applies without one
+	CheckpointDir string `yaml:"checkpoint_dir"`
 }
 
 // DockerCollectorConfig for Docker container logs
 type DockerCollectorConfig struct {
-	Enabled    bool     `yaml:"enabled"`
-	Socket     string   `yaml:"socket"`
-	Containers []string `yaml:"containers"` // Container names/IDs, empty = all
-	Labels     []string `yaml:"labels"`     // Filter by labels
-	Since      string   `yaml:"since"`
+	Enabled       bool     `yaml:"enabled"`
+	Socket        string   `yaml:"socket"`
+	Containers    []string `yaml:"containers"` // Container names/IDs, empty = all
+	Labels        []string `yaml:"labels"`     // "key" or "key=value", all must match
+	Since         string   `yaml:"since"`      // Backfill on startup: duration ("1h"), RFC3339 or unix seconds
+	Class         string   `yaml:"class"`
+	SchemaVersion string   `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+	MinLevel      string   `yaml:"min_level"`      // Drop entries below this level
+
+	RateLimit    *RateLimitConfig `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow time.Duration    `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
 }
 
 // CommandCollectorConfig for executing commands and parsing output
 type CommandCollectorConfig struct {
-	Enabled  bool          `yaml:"enabled"`
-	Command  string        `yaml:"command"`
-	Args     []string      `yaml:"args"`
-	Interval time.Duration `yaml:"interval"`
-	Service  string        `yaml:"service"`
-	Timeout  time.Duration `yaml:"timeout"`
+	Enabled       bool          `yaml:"enabled"`
+	Command       string        `yaml:"command"`
+	Args          []string      `yaml:"args"`
+	Interval      time.Duration `yaml:"interval"`
+	Service       string        `yaml:"service"`
+	Timeout       time.Duration `yaml:"timeout"`
+	Class         string        `yaml:"class"`
+	SchemaVersion string        `yaml:"schema_version"` // Stamped into metadata, "auto" = config hash
+	MinLevel      string        `yaml:"min_level"`      // Drop entries below this level
+
+	RateLimit    *RateLimitConfig `yaml:"rate_limit"`    // Cap on entries per second
+	RepeatWindow time.Duration    `yaml:"repeat_window"` // Collapse identical consecutive lines (0 = off)
+
+	// OnChange emits only when the tracked field of the output changes
+	OnChange *OnChangeConfig `yaml:"on_change"`
+
+	// DedupeUnchanged suppresses runs whose output matches the previous run;
+	// Keepalive still ships an unchanged run this often to prove liveness
+	DedupeUnchanged bool          `yaml:"dedupe_unchanged"`
+	Keepalive       time.Duration `yaml:"keepalive"`
+
+	// SplitLines emits one entry per output line, leveled by its content,
+	// instead of one entry for the whole output
+	SplitLines bool `yaml:"split_lines"`
+
+	// Shell runs command as a script through /bin/sh -c (cmd /c on Windows)
+	// so pipes and other shell features work. Off by default: direct exec
+	// can't be tricked into running extra commands.
+	Shell   bool              `yaml:"shell"`
+	Env     map[string]string `yaml:"env"`      // Added to the agent's environment
+	WorkDir string            `yaml:"work_dir"` // Default: the agent's working directory
 }
 
 // Load loads configuration from file or defaults
@@ -130,16 +716,21 @@ func Load(path string) (*Config, error) {
 	}
 
 	if configPath != "" {
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
+		if err := loadFile(configPath, cfg, nil); err != nil {
+			return nil, err
 		}
+	}
 
-		// Expand environment variables
-		expanded := os.ExpandEnv(string(data))
+	if err := applyEnvOverrides(reflect.ValueOf(cfg).Elem(), envPrefix); err != nil {
+		return nil, err
+	}
 
-		if err := yaml.Unmarshal([]byte(expanded), cfg); err != nil {
-			return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := cfg.Server.loadAPIKeyFile(); err != nil {
+		return nil, err
+	}
+	if h := cfg.Collectors.HTTP; h != nil {
+		if err := readAPIKeyFile("collectors.http", &h.APIKey, h.APIKeyFile); err != nil {
+			return nil, err
 		}
 	}
 
@@ -155,32 +746,248 @@ func Load(path string) (*Config, error) {
 	return cfg, nil
 }
 
+// loadFile merges a config file into cfg, then the files it includes.
+// including holds the absolute paths of the files being loaded above this
+// one, to reject include cycles.
+func loadFile(path string, cfg *Config, including []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	for _, p := range including {
+		if p == abs {
+			return fmt.Errorf("include cycle: %s", strings.Join(append(including, abs), " -> "))
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Expand environment variables
+	expanded := os.ExpandEnv(string(data))
+
+	// Decoding replaces lists, so set the collectors loaded so far aside
+	// and prepend them afterwards
+	collectors := reflect.ValueOf(&cfg.Collectors).Elem()
+	earlier := make([]reflect.Value, collectors.NumField())
+	for i := range earlier {
+		if f := collectors.Field(i); f.Kind() == reflect.Slice {
+			earlier[i] = reflect.ValueOf(f.Interface())
+			f.SetZero()
+		}
+	}
+	cfg.Include = nil
+
+	if err := parseConfig(path, []byte(expanded), cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for i, prev := range earlier {
+		if prev.IsValid() && prev.Len() > 0 {
+			f := collectors.Field(i)
+			f.Set(reflect.AppendSlice(prev, f))
+		}
+	}
+
+	includes := cfg.Include
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: include %q: %w", path, pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return fmt.Errorf("%s: include %q: file not found", path, pattern)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if err := loadFile(match, cfg, append(including, abs)); err != nil {
+				return err
+			}
+		}
+	}
+	cfg.Include = includes
+	return nil
+}
+
+// parseConfig decodes data into cfg, choosing the format by the file's
+// extension: .toml, .json, or YAML for anything else. TOML and JSON are
+// decoded into a generic tree first and then through the YAML decoder, so
+// all three formats share the yaml tags, duration strings like "30s" and
+// the custom unmarshalers.
+func parseConfig(path string, data []byte, cfg *Config) error {
+	var tree any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		var m map[string]any
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		tree = m
+	case ".json":
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return err
+		}
+	default:
+		return yaml.Unmarshal(data, cfg)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(tree); err != nil {
+		return err
+	}
+	err := node.Decode(cfg)
+	// The generated nodes have no positions, so drop the "line 0: " prefixes
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for i, e := range typeErr.Errors {
+			typeErr.Errors[i] = strings.TrimPrefix(e, "line 0: ")
+		}
+	}
+	return err
+}
+
+// envPrefix starts every config override variable
+const envPrefix = "LOGCHAT"
+
+// applyEnvOverrides sets config fields from environment variables named
+// after their YAML path: upper case, joined by underscores, e.g.
+// server.api_key is LOGCHAT_SERVER_API_KEY. String fields take the value
+// as is; any other field parses it as YAML, so durations read "30s" and
+// lists "[a, b]". Unset variables leave the field alone.
+func applyEnvOverrides(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if err := applyEnvOverride(v.Field(i), prefix+"_"+strings.ToUpper(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEnvOverride sets one field from its variable, or recurses into a
+// nested struct. A nil struct pointer is only allocated when one of its
+// variables is set.
+func applyEnvOverride(field reflect.Value, name string) error {
+	switch {
+	case field.Kind() == reflect.Struct:
+		return applyEnvOverrides(field, name)
+	case field.Kind() == reflect.Pointer && field.Type().Elem().Kind() == reflect.Struct:
+		target := field
+		if field.IsNil() {
+			if !envSetWithPrefix(name + "_") {
+				return nil
+			}
+			target = reflect.New(field.Type().Elem())
+		}
+		if err := applyEnvOverrides(target.Elem(), name); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+	parsed := reflect.New(field.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("%s: invalid value %q: %w", name, value, err)
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
+
+// envSetWithPrefix reports whether any environment variable starts with prefix
+func envSetWithPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAPIKeyFile reads api_key_file into APIKey, for the primary and each
+// additional server
+func (s *ServerConfig) loadAPIKeyFile() error {
+	if err := readAPIKeyFile("server", &s.APIKey, s.APIKeyFile); err != nil {
+		return err
+	}
+
+	for i := range s.AdditionalServers {
+		o := &s.AdditionalServers[i]
+		field := fmt.Sprintf("server.additional_servers[%d]", i)
+		if err := readAPIKeyFile(field, &o.APIKey, o.APIKeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readAPIKeyFile loads a key file into key, if one is set
+func readAPIKeyFile(field string, key *string, file string) error {
+	if file == "" {
+		return nil
+	}
+	if *key != "" {
+		return fmt.Errorf("%s.api_key and %s.api_key_file are mutually exclusive", field, field)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s.api_key_file: %w", field, err)
+	}
+
+	*key = strings.TrimSpace(string(data))
+	if *key == "" {
+		return fmt.Errorf("%s.api_key_file %s is empty", field, file)
+	}
+	return nil
+}
+
+// configExtensions are tried in order for each config file location
+var configExtensions = []string{".yaml", ".yml", ".toml", ".json"}
+
 // findConfigFile searches for config file in common locations
 func findConfigFile() string {
 	locations := []string{
-		"logchat-agent.yaml",
-		"logchat-agent.yml",
-		"config.yaml",
-		"config.yml",
+		"logchat-agent",
+		"config",
 	}
 
 	// Add platform-specific locations
 	if runtime.GOOS == "windows" {
 		locations = append(locations,
-			filepath.Join(os.Getenv("PROGRAMDATA"), "LogChat", "agent.yaml"),
-			filepath.Join(os.Getenv("APPDATA"), "LogChat", "agent.yaml"),
+			filepath.Join(os.Getenv("PROGRAMDATA"), "LogChat", "agent"),
+			filepath.Join(os.Getenv("APPDATA"), "LogChat", "agent"),
 		)
 	} else {
 		locations = append(locations,
-			"/etc/logchat/agent.yaml",
-			"/etc/logchat-agent.yaml",
-			filepath.Join(os.Getenv("HOME"), ".config", "logchat", "agent.yaml"),
+			"/etc/logchat/agent",
+			"/etc/logchat-agent",
+			filepath.Join(os.Getenv("HOME"), ".config", "logchat", "agent"),
 		)
 	}
 
 	for _, loc := range locations {
-		if _, err := os.Stat(loc); err == nil {
-			return loc
+		for _, ext := range configExtensions {
+			if _, err := os.Stat(loc + ext); err == nil {
+				return loc + ext
+			}
 		}
 	}
 
@@ -215,6 +1022,12 @@ func defaultConfig() *Config {
 	}
 }
 
+// deliveryClassPresets fill in unset batching for well-known class names
+var deliveryClassPresets = map[string]DeliveryClassConfig{
+	"urgent": {BatchSize: 10, FlushInterval: 1 * time.Second},
+	"bulk":   {BatchSize: 1000, FlushInterval: 30 * time.Second},
+}
+
 // applyDefaults applies default values to empty fields
 func (c *Config) applyDefaults() error {
 	if c.Agent.Hostname == "" {
@@ -226,6 +1039,10 @@ func (c *Config) applyDefaults() error {
 		}
 	}
 
+	if c.Agent.ShutdownTimeout <= 0 {
+		c.Agent.ShutdownTimeout = 30 * time.Second
+	}
+
 	if c.Server.BatchSize == 0 {
 		c.Server.BatchSize = 100
 	}
@@ -246,22 +1063,560 @@ func (c *Config) applyDefaults() error {
 		c.Buffer.MaxSize = 100 * 1024 * 1024
 	}
 
+	for name, class := range c.Server.Classes {
+		preset := deliveryClassPresets[name]
+		if class.BatchSize == 0 {
+			class.BatchSize = preset.BatchSize
+		}
+		if class.BatchSize == 0 {
+			class.BatchSize = c.Server.BatchSize
+		}
+		if class.FlushInterval == 0 {
+			class.FlushInterval = preset.FlushInterval
+		}
+		if class.FlushInterval == 0 {
+			class.FlushInterval = c.Server.FlushInterval
+		}
+		if b := class.Buffer; b != nil {
+			if b.MaxItems == 0 {
+				b.MaxItems = c.Buffer.MaxItems
+			}
+			if b.MaxSize == 0 {
+				b.MaxSize = c.Buffer.MaxSize
+			}
+			// Keep class data apart from the main buffer's files
+			if b.Path == "" {
+				dir := c.Buffer.Path
+				if dir == "" {
+					dir = filepath.Join(os.TempDir(), "logchat-buffer")
+				}
+				b.Path = filepath.Join(dir, "class-"+name)
+			}
+		}
+		c.Server.Classes[name] = class
+	}
+
+	for i := range c.Server.AdditionalServers {
+		b := c.Server.AdditionalServers[i].Buffer
+		if b == nil {
+			continue
+		}
+		if b.MaxItems == 0 {
+			b.MaxItems = c.Buffer.MaxItems
+		}
+		if b.MaxSize == 0 {
+			b.MaxSize = c.Buffer.MaxSize
+		}
+		// Keep output data apart from the main buffer's files
+		if b.Path == "" {
+			dir := c.Buffer.Path
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "logchat-buffer")
+			}
+			b.Path = filepath.Join(dir, fmt.Sprintf("output-%d", i))
+		}
+	}
+
+	if l := c.Server.Ledger; l != nil && l.Enabled && l.Path == "" {
+		dir := c.Buffer.Path
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "logchat-buffer")
+		}
+		l.Path = filepath.Join(dir, "ledger.jsonl")
+	}
+
+	if k := c.Collectors.Kubernetes; k != nil && k.Enabled && k.CheckpointDir == "" {
+		dir := c.Buffer.Path
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "logchat-buffer")
+		}
+		k.CheckpointDir = filepath.Join(dir, "k8s-checkpoints")
+	}
+
+	if d := c.Server.Dedup; d != nil && d.Enabled {
+		if d.Path == "" {
+			dir := c.Buffer.Path
+			if dir == "" {
+				dir = filepath.Join(os.TempDir(), "logchat-buffer")
+			}
+			d.Path = filepath.Join(dir, "delivered.bloom")
+		}
+		if d.Capacity == 0 {
+			d.Capacity = 100000
+		}
+		if d.FalsePositiveRate == 0 {
+			d.FalsePositiveRate = 0.001
+		}
+	}
+
 	return nil
 }
 
-// validate validates the configuration
+// validate validates the configuration, reporting every problem found
 func (c *Config) validate() error {
+	var errs []error
 	if c.Server.URL == "" {
-		return fmt.Errorf("server.url is required")
+		errs = append(errs, fmt.Errorf("server.url is required"))
+	} else if !strings.HasPrefix(c.Server.URL, "http://") && !strings.HasPrefix(c.Server.URL, "https://") {
+		errs = append(errs, fmt.Errorf("server.url must start with http:// or https://"))
+	}
+
+	for i, url := range c.Server.FallbackURLs {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			errs = append(errs, fmt.Errorf("server.fallback_urls[%d] must start with http:// or https://", i))
+		}
+	}
+
+	for i, o := range c.Server.AdditionalServers {
+		if !strings.HasPrefix(o.URL, "http://") && !strings.HasPrefix(o.URL, "https://") {
+			errs = append(errs, fmt.Errorf("server.additional_servers[%d].url must start with http:// or https://", i))
+		}
+		if (o.CertFile == "") != (o.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("server.additional_servers[%d]: cert_file and key_file must be set together", i))
+		}
+		if err := validateFormat(fmt.Sprintf("server.additional_servers[%d]", i), o.Format, o.Loki, o.OTLP); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := validateFormat("server", c.Server.Format, c.Server.Loki, c.Server.OTLP); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cb := c.Server.CircuitBreaker; cb != nil && cb.Failures < 0 {
+		errs = append(errs, fmt.Errorf("server.circuit_breaker.failures: must not be negative"))
+	}
+	if hi := c.Server.HealthInterval; hi != nil && *hi < 0 {
+		errs = append(errs, fmt.Errorf("server.health_interval: must not be negative"))
+	}
+	if hi := c.Server.HealthInterval; hi != nil && *hi == 0 && c.Server.CircuitBreaker != nil && c.Server.CircuitBreaker.Enabled {
+		errs = append(errs, fmt.Errorf("server.circuit_breaker: needs health checks to close, set server.health_interval"))
+	}
+	errs = append(errs, validateAuth("server", c.Server.BasicAuthUser, c.Server.BasicAuthPassword, c.Server.Headers)...)
+	for i, o := range c.Server.AdditionalServers {
+		errs = append(errs, validateAuth(fmt.Sprintf("server.additional_servers[%d]", i), o.BasicAuthUser, o.BasicAuthPassword, o.Headers)...)
+	}
+
+	paths := map[string]string{
+		"server.ingest_path": c.Server.IngestPath,
+		"server.health_path": c.Server.HealthPath,
+	}
+	for i, o := range c.Server.AdditionalServers {
+		paths[fmt.Sprintf("server.additional_servers[%d].ingest_path", i)] = o.IngestPath
+		paths[fmt.Sprintf("server.additional_servers[%d].health_path", i)] = o.HealthPath
+	}
+	for _, field := range sortedKeys(paths) {
+		if p := paths[field]; p != "" && !strings.HasPrefix(p, "/") {
+			errs = append(errs, fmt.Errorf("%s: %q must start with /", field, p))
+		}
+	}
+
+	if c.Server.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("server.max_retries: must not be negative"))
+	}
+	switch c.Server.GiveUp {
+	case "", "drop":
+	case "dead_letter":
+		if c.Server.DeadLetterDir == "" {
+			errs = append(errs, fmt.Errorf("server.give_up: dead_letter requires server.dead_letter_dir"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("server.give_up: unknown value %q (use dead_letter or drop)", c.Server.GiveUp))
+	}
+
+	if (c.Server.CertFile == "") != (c.Server.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("server.cert_file and server.key_file must be set together"))
+	}
+
+	if j := c.Collectors.Journald; j != nil {
+		for i, m := range j.Matches {
+			if m != "+" && !journalMatch.MatchString(m) {
+				errs = append(errs, fmt.Errorf("collectors.journald.matches[%d]: %q is not FIELD=value or \"+\"", i, m))
+			}
+		}
+	}
+
+	if h := c.Server.Health; h != nil {
+		for _, code := range h.StatusCodes {
+			if code < 100 || code > 599 {
+				errs = append(errs, fmt.Errorf("server.health.status_codes: invalid status code %d", code))
+			}
+		}
+	}
+
+	for i, f := range c.Collectors.Files {
+		switch f.ReadFrom {
+		case "", "end", "beginning":
+		default:
+			errs = append(errs, fmt.Errorf("collectors.files[%d].read_from: unknown value %q (use end or beginning)", i, f.ReadFrom))
+		}
+		if utf8.RuneCountInString(f.CSVDelimiter) > 1 {
+			errs = append(errs, fmt.Errorf("collectors.files[%d].csv_delimiter: must be a single character", i))
+		}
+	}
+
+	if k := c.Collectors.Kubernetes; k != nil {
+		switch k.ReadFrom {
+		case "", "end", "beginning":
+		default:
+			errs = append(errs, fmt.Errorf("collectors.kubernetes.read_from: unknown value %q (use end or beginning)", k.ReadFrom))
+		}
+	}
+
+	for i, n := range c.Collectors.Net {
+		if n.Enabled && !strings.HasPrefix(n.Address, "tcp://") && !strings.HasPrefix(n.Address, "udp://") {
+			errs = append(errs, fmt.Errorf("collectors.net[%d].address: %q must start with tcp:// or udp://", i, n.Address))
+		}
+	}
+
+	if sm := c.Agent.Sampling; sm != nil {
+		if sm.Percent < 0 || sm.Percent > 100 {
+			errs = append(errs, fmt.Errorf("agent.sampling.percent: must be between 0 and 100"))
+		}
+		if sm.OneIn < 0 {
+			errs = append(errs, fmt.Errorf("agent.sampling.one_in: must not be negative"))
+		}
+		if sm.Percent > 0 && sm.OneIn > 0 {
+			errs = append(errs, fmt.Errorf("agent.sampling: set percent or one_in, not both"))
+		}
+		for level, percent := range sm.Levels {
+			if percent < 0 || percent > 100 {
+				errs = append(errs, fmt.Errorf("agent.sampling.levels.%s: must be between 0 and 100", level))
+			}
+		}
+	}
+
+	switch strings.ToLower(c.Agent.LogLevel) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		errs = append(errs, fmt.Errorf("agent.log_level: unknown level %q (use debug, info, warn or error)", c.Agent.LogLevel))
+	}
+	switch c.Agent.LogFormat {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("agent.log_format: unknown format %q (use text or json)", c.Agent.LogFormat))
+	}
+
+	errs = append(errs, c.validateCollectors()...)
+	errs = append(errs, c.validateRateLimits()...)
+	errs = append(errs, c.validateMinLevels()...)
+
+	if len(errs) > 0 {
+		return &ValidationError{Problems: errs}
+	}
+	return nil
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  - " + p.Error()
+	}
+	return fmt.Sprintf("%d problems:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// Unwrap returns the individual problems for errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// parserNames are the values accepted for a collector's parser
+var parserNames = map[string]bool{
+	"": true, "plain": true, "json": true, "logfmt": true, "csv": true,
+	"cri": true, "docker-json": true, "regex": true, "bracketed": true,
+}
+
+// encodingNames are the values accepted for a file collector's encoding
+var encodingNames = map[string]bool{
+	"": true, "utf-8": true, "utf-16le": true, "utf-16be": true,
+	"latin1": true, "iso-8859-1": true, "windows-1252": true,
+}
+
+// validateCollectors checks each enabled collector's required fields and
+// compiles its regexes, so mistakes fail at startup instead of collecting
+// nothing
+func (c *Config) validateCollectors() []error {
+	var errs []error
+	for i, f := range c.Collectors.Files {
+		if !f.Enabled {
+			continue
+		}
+		field := fmt.Sprintf("collectors.files[%d]", i)
+		if len(f.Paths) == 0 {
+			errs = append(errs, fmt.Errorf("%s.paths: at least one path is required", field))
+		}
+		if f.MaxOpenFiles < 0 {
+			errs = append(errs, fmt.Errorf("%s.max_open_files: must not be negative", field))
+		}
+		if !encodingNames[f.Encoding] {
+			errs = append(errs, fmt.Errorf("%s.encoding: unknown encoding %q (use utf-8, utf-16le, utf-16be, latin1, iso-8859-1 or windows-1252)", field, f.Encoding))
+		}
+		errs = append(errs, validateParser(field, f.Parser, f.ParseRegex)...)
+		errs = append(errs, validateMultiline(field, f.Multiline)...)
+	}
+
+	if s := c.Collectors.Stdin; s != nil && s.Enabled {
+		if s.Parser == "cri" {
+			errs = append(errs, fmt.Errorf("collectors.stdin.parser: cri is only supported for files"))
+		}
+		errs = append(errs, validateParser("collectors.stdin", s.Parser, s.ParseRegex)...)
+	}
+
+	if k := c.Collectors.Kubernetes; k != nil && k.Enabled {
+		errs = append(errs, validateMultiline("collectors.kubernetes", k.Multiline)...)
+		if k.MaxOpenFiles < 0 {
+			errs = append(errs, fmt.Errorf("collectors.kubernetes.max_open_files: must not be negative"))
+		}
+	}
+
+	for i, cmd := range c.Collectors.Command {
+		if !cmd.Enabled {
+			continue
+		}
+		field := fmt.Sprintf("collectors.command[%d]", i)
+		if strings.TrimSpace(cmd.Command) == "" {
+			errs = append(errs, fmt.Errorf("%s.command: required", field))
+		}
+		if cmd.Interval < 0 {
+			errs = append(errs, fmt.Errorf("%s.interval: must not be negative", field))
+		}
+		if cmd.Timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s.timeout: must not be negative", field))
+		}
+	}
+
+	if j := c.Collectors.Journald; j != nil && j.Enabled {
+		if j.Priority < 0 || j.Priority > 7 {
+			errs = append(errs, fmt.Errorf("collectors.journald.priority: %d is outside 0-7", j.Priority))
+		}
+	}
+
+	for i, s := range c.Collectors.Syslog {
+		if !s.Enabled {
+			continue
+		}
+		field := fmt.Sprintf("collectors.syslog[%d]", i)
+		switch strings.ToLower(s.Protocol) {
+		case "", "auto", "rfc3164", "rfc5424":
+		default:
+			errs = append(errs, fmt.Errorf("%s.protocol: unknown value %q (use auto, rfc3164 or rfc5424)", field, s.Protocol))
+		}
+		switch strings.ToLower(s.Framing) {
+		case "", "auto", "octet", "newline":
+		default:
+			errs = append(errs, fmt.Errorf("%s.framing: unknown value %q (use auto, octet or newline)", field, s.Framing))
+		}
+	}
+
+	if e := c.Collectors.EventLog; e != nil && e.Enabled && len(e.Channels) == 0 && e.Query == "" {
+		errs = append(errs, fmt.Errorf("collectors.eventlog.channels: at least one channel is required"))
+	}
+	return errs
+}
+
+// validateParser checks a parser name and, for the regex parser, its pattern
+func validateParser(field, parser, parseRegex string) []error {
+	var errs []error
+	if !parserNames[parser] {
+		errs = append(errs, fmt.Errorf("%s.parser: unknown parser %q (use json, logfmt, csv, cri, docker-json, regex, bracketed or plain)", field, parser))
+	}
+	if parser == "regex" && parseRegex == "" {
+		errs = append(errs, fmt.Errorf("%s.parse_regex: required by the regex parser", field))
+	}
+	if parseRegex != "" {
+		if _, err := regexp.Compile(parseRegex); err != nil {
+			errs = append(errs, fmt.Errorf("%s.parse_regex: invalid regex: %v", field, err))
+		}
 	}
+	return errs
+}
+
+// validateMultiline checks a multiline block's pattern and match mode
+func validateMultiline(field string, m *MultilineConfig) []error {
+	if m == nil {
+		return nil
+	}
+	var errs []error
+	if m.Pattern == "" {
+		errs = append(errs, fmt.Errorf("%s.multiline.pattern: required", field))
+	} else if _, err := regexp.Compile(m.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("%s.multiline.pattern: invalid regex: %v", field, err))
+	}
+	switch m.Match {
+	case "", "after", "before":
+	default:
+		errs = append(errs, fmt.Errorf("%s.multiline.match: unknown value %q (use after or before)", field, m.Match))
+	}
+	return errs
+}
 
-	if !strings.HasPrefix(c.Server.URL, "http://") && !strings.HasPrefix(c.Server.URL, "https://") {
-		return fmt.Errorf("server.url must start with http:// or https://")
+// validateAuth checks a server's basic auth and custom header names
+func validateAuth(field, user, password string, headers map[string]string) []error {
+	var errs []error
+	if user == "" && password != "" {
+		errs = append(errs, fmt.Errorf("%s.basic_auth_password: set without basic_auth_user", field))
+	}
+	for _, name := range sortedKeys(headers) {
+		if name == "" || strings.ContainsAny(name, " \t:\r\n") {
+			errs = append(errs, fmt.Errorf("%s.headers: invalid header name %q", field, name))
+		}
+	}
+	return errs
+}
+
+// validateFormat checks a server's payload format and its format settings
+func validateFormat(field, format string, loki *LokiConfig, otlp *OTLPConfig) error {
+	switch format {
+	case "", "logchat", "loki", "otlp":
+	default:
+		return fmt.Errorf("%s.format: unknown format %q (use logchat, loki or otlp)", field, format)
+	}
+
+	if loki != nil {
+		switch loki.LineFormat {
+		case "", "message", "json":
+		default:
+			return fmt.Errorf("%s.loki.line_format: unknown value %q (use message or json)", field, loki.LineFormat)
+		}
+		for i, label := range loki.Labels {
+			if label == "" {
+				return fmt.Errorf("%s.loki.labels[%d]: empty label", field, i)
+			}
+		}
 	}
 
+	if otlp != nil {
+		switch otlp.Encoding {
+		case "", "protobuf", "json":
+		default:
+			return fmt.Errorf("%s.otlp.encoding: unknown value %q (use protobuf or json)", field, otlp.Encoding)
+		}
+	}
 	return nil
 }
 
+// journalMatch is a journal field match; field names are upper case
+var journalMatch = regexp.MustCompile(`^[A-Z0-9_]+=`)
+
+// validateMinLevels checks every min_level names a known level
+func (c *Config) validateMinLevels() []error {
+	levels := map[string]string{"agent.min_level": c.Agent.MinLevel}
+	for i, f := range c.Collectors.Files {
+		levels[fmt.Sprintf("collectors.files[%d].min_level", i)] = f.MinLevel
+	}
+	for i, cmd := range c.Collectors.Command {
+		levels[fmt.Sprintf("collectors.command[%d].min_level", i)] = cmd.MinLevel
+	}
+	for i, s := range c.Collectors.Syslog {
+		levels[fmt.Sprintf("collectors.syslog[%d].min_level", i)] = s.MinLevel
+	}
+	if c.Collectors.Docker != nil {
+		levels["collectors.docker.min_level"] = c.Collectors.Docker.MinLevel
+	}
+	if c.Collectors.Podman != nil {
+		levels["collectors.podman.min_level"] = c.Collectors.Podman.MinLevel
+	}
+	if c.Collectors.Stdin != nil {
+		levels["collectors.stdin.min_level"] = c.Collectors.Stdin.MinLevel
+	}
+	if c.Collectors.HTTP != nil {
+		levels["collectors.http.min_level"] = c.Collectors.HTTP.MinLevel
+	}
+	if c.Collectors.Kubernetes != nil {
+		levels["collectors.kubernetes.min_level"] = c.Collectors.Kubernetes.MinLevel
+	}
+	for i, n := range c.Collectors.Net {
+		levels[fmt.Sprintf("collectors.net[%d].min_level", i)] = n.MinLevel
+	}
+
+	var errs []error
+	for _, field := range sortedKeys(levels) {
+		switch level := levels[field]; strings.ToUpper(level) {
+		case "", "DEBUG", "INFO", "WARN", "ERROR", "FATAL":
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown level %q (use DEBUG, INFO, WARN, ERROR or FATAL)", field, level))
+		}
+	}
+	return errs
+}
+
+// validateRateLimits checks every rate_limit has a usable rate and mode
+func (c *Config) validateRateLimits() []error {
+	limits := map[string]*RateLimitConfig{"agent.rate_limit": c.Agent.RateLimit}
+	for i, f := range c.Collectors.Files {
+		limits[fmt.Sprintf("collectors.files[%d].rate_limit", i)] = f.RateLimit
+	}
+	for i, cmd := range c.Collectors.Command {
+		limits[fmt.Sprintf("collectors.command[%d].rate_limit", i)] = cmd.RateLimit
+	}
+	for i, s := range c.Collectors.Syslog {
+		limits[fmt.Sprintf("collectors.syslog[%d].rate_limit", i)] = s.RateLimit
+	}
+	for i, n := range c.Collectors.Net {
+		limits[fmt.Sprintf("collectors.net[%d].rate_limit", i)] = n.RateLimit
+	}
+	if c.Collectors.Docker != nil {
+		limits["collectors.docker.rate_limit"] = c.Collectors.Docker.RateLimit
+	}
+	if c.Collectors.Podman != nil {
+		limits["collectors.podman.rate_limit"] = c.Collectors.Podman.RateLimit
+	}
+	if c.Collectors.Journald != nil {
+		limits["collectors.journald.rate_limit"] = c.Collectors.Journald.RateLimit
+	}
+	if c.Collectors.EventLog != nil {
+		limits["collectors.eventlog.rate_limit"] = c.Collectors.EventLog.RateLimit
+	}
+	if c.Collectors.Stdin != nil {
+		limits["collectors.stdin.rate_limit"] = c.Collectors.Stdin.RateLimit
+	}
+	if c.Collectors.HTTP != nil {
+		limits["collectors.http.rate_limit"] = c.Collectors.HTTP.RateLimit
+	}
+	if c.Collectors.Kubernetes != nil {
+		limits["collectors.kubernetes.rate_limit"] = c.Collectors.Kubernetes.RateLimit
+	}
+
+	var errs []error
+	for _, field := range sortedKeys(limits) {
+		limit := limits[field]
+		if limit == nil {
+			continue
+		}
+		if limit.Rate < 0 {
+			errs = append(errs, fmt.Errorf("%s.rate: must not be negative", field))
+		}
+		if limit.Burst < 0 {
+			errs = append(errs, fmt.Errorf("%s.burst: must not be negative", field))
+		}
+		switch limit.Mode {
+		case "", "drop", "block":
+		default:
+			errs = append(errs, fmt.Errorf("%s.mode: unknown mode %q (use drop or block)", field, limit.Mode))
+		}
+	}
+	return errs
+}
+
+// sortedKeys returns a map's keys in order, so problems are reported the
+// same way on every run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // GenerateSampleConfig generates a sample configuration file
 func GenerateSampleConfig() error {
 	hostname, _ := os.Hostname()
@@ -273,20 +1628,145 @@ func GenerateSampleConfig() error {
 server:
   # LogChat API URL
   url: "http://localhost:3001"
+
+  # Standby endpoints used in order while the primary is unreachable
+  fallback_urls: []
   
   # API key for authentication (get from admin panel)
   api_key: "${LOGCHAT_API_KEY}"
+  # Or read it from a file, e.g. a Kubernetes secret (don't set both)
+  # api_key_file: "/run/secrets/logchat-api-key"
   
   # Request timeout
   timeout: 30s
+
+  # Egress proxy: http://, https:// or socks5:// (credentials as
+  # user:pass@host). Empty follows HTTP_PROXY/HTTPS_PROXY/NO_PROXY;
+  # "none" always connects directly.
+  proxy: ""
   
   # Skip TLS verification (for self-signed certs)
   insecure: false
+
+  # Or verify against a private CA, and present a client certificate for
+  # mutual TLS (cert_file and key_file go together)
+  # ca_file: "/etc/logchat/ca.pem"
+  # cert_file: "/etc/logchat/agent.pem"
+  # key_file: "/etc/logchat/agent-key.pem"
   
   # Batch settings
   batch_size: 100
   flush_interval: 5s
 
+  # Also end a batch before its entries exceed this many JSON bytes (keeps
+  # payloads under the server's body limit), 0 = count only. A single larger
+  # entry is sent on its own.
+  max_batch_bytes: 0
+
+  # Maximum concurrent ingest requests (0 = unlimited)
+  max_in_flight: 0
+
+  # Batches of one buffer posted in parallel per flush. Above 1, batches
+  # may arrive out of order, and a failure resends later batches of the
+  # same round even if they got through (enable dedup to filter those)
+  send_concurrency: 1
+
+  # After a failed send, wait backoff_base (doubling per failure, with jitter,
+  # up to max_backoff) before trying again
+  backoff_base: 1s
+  max_backoff: 2m
+
+  # Sort each batch by timestamp before sending (best effort, per batch only)
+  sort_batch_by_time: false
+
+  # Sign each ingest request for gateways that verify body integrity:
+  # X-Signature = hex(HMAC-SHA256(key, "<X-Signature-Timestamp>.<body>"))
+  # signing_key: "${LOGCHAT_SIGNING_KEY}"
+
+  # Gzip ingest requests (Content-Encoding: gzip); batches smaller than
+  # compress_min_bytes go out uncompressed. Health checks are never compressed.
+  compression: "none"
+  compress_min_bytes: 1024
+
+  # Push to Grafana Loki (/loki/api/v1/push) instead of a LogChat server.
+  # Each distinct combination of label values is a stream in Loki, so only
+  # pick low-cardinality labels: service, level, hostname, source,
+  # environment or a tag name.
+  # format: "loki"
+  # loki:
+  #   labels: [service, hostname, level]
+  #   line_format: "message"    # or "json" to keep tags and metadata
+  #   tenant_id: ""
+  #
+  # Or export to an OpenTelemetry collector's OTLP/HTTP endpoint (/v1/logs)
+  # format: "otlp"
+  # otlp:
+  #   encoding: "protobuf"      # or "json"
+
+  # Connection handling. Set http2: false for proxies that misbehave with h2;
+  # max_conn_age drops pooled connections a load balancer may have silently closed.
+  # http2: true
+  keep_alive: 30s
+  idle_conn_timeout: 30s
+  max_conn_age: 0s
+
+  # Delivery classes with their own batching; set "class" on a collector to
+  # use one. "urgent" and "bulk" have presets, other names inherit the above.
+  classes: {}
+  #  urgent: {}                # batch_size 10, flush_interval 1s
+  #  bulk:                     # batch_size 1000, flush_interval 30s
+  #    flush_interval: 1m
+  #  audit:                    # Crash-safe on disk, independent of the main buffer
+  #    buffer:
+  #      type: "file"
+
+  # Ship a copy of every entry to more servers, e.g. an archive. Each has its
+  # own key, TLS settings and buffer, so one failing doesn't block the rest;
+  # batching and connection settings follow the primary.
+  additional_servers: []
+  #  - name: "archive"
+  #    url: "https://archive.example.com"
+  #    api_key: "${ARCHIVE_API_KEY}"
+  #    ca_file: "/etc/logchat/archive-ca.pem"
+  #    buffer:
+  #      type: "file"
+  #      path: "/var/lib/logchat/buffer-archive"
+
+  # Health endpoint responses that count as healthy
+  health:
+    status_codes: [200]  # e.g. [200, 204]
+    body_match: ""       # e.g. '"status":"ok"'
+
+  # Skip entries already delivered when a restart replays the buffer.
+  # A rare false positive drops a new entry; capacity bounds the filter.
+  # Entries are told apart by content and timestamp, plus their position
+  # (file offset with include_offset, journal cursor, event record number)
+  # when known. Without a position, identical lines with the same timestamp
+  # count as one and only the first is delivered.
+  dedup:
+    enabled: false
+    capacity: 100000
+    false_positive_rate: 0.001
+
+  # Entries the server refuses as invalid (4xx other than 401/403/404/408/429)
+  # are isolated from their batch and saved here as JSON instead of being
+  # retried forever. Empty keeps retrying them.
+  dead_letter_dir: ""  # e.g. /var/lib/logchat/dead-letter
+
+  # Append-only hash chain of shipped batches (each head = sha256(prev + batch hash))
+  # as tamper-evident proof of what was shipped and in what order
+  ledger:
+    enabled: false
+    max_size: 10485760
+    include_in_payload: false
+
+# Prometheus metrics: collected, sent and failed counts, buffer depth and
+# server reachability
+metrics:
+  enabled: false
+  address: "127.0.0.1:9464"
+  path: "/metrics"
+
 # Agent identification
 agent:
   # Hostname (auto-detected if empty)
@@ -295,20 +1775,99 @@ agent:
   # Environment name
   environment: "production"
   
-  # Log level: debug, info, warn, error
+  # The agent's own log output: level debug, info, warn or error, and format
+  # text or json (one object per line, for when this output is collected)
   log_level: "info"
+  log_format: "text"
+
+  # Drop entries below this level before buffering (DEBUG < INFO < WARN <
+  # ERROR < FATAL); collectors can set their own min_level too
+  min_level: ""
   
   # Custom tags added to all logs
   tags:
     datacenter: "dc1"
     team: "platform"
 
+  # Processors applied to every entry before buffering
+  processors: []
+  #  - type: "split_by_field"   # One entry per element of an array field
+  #    field: "results"
+  #    max_fanout: 100
+  #  - type: "normalize_level"  # Map WARNING/W/4 etc. to DEBUG/INFO/WARN/ERROR/FATAL
+  #    mapping:
+  #      AUDIT: "INFO"
+  #  - type: "cardinality_limit" # Collapse values past the limit into "_overflow"
+  #    limits:
+  #      service: 200
+  #      tags.path: 500
+  #  - type: "compact_stack_traces" # Elide frames of recently shipped traces
+  #    window: 10m
+  #    full_every: 100
+
+  # Entries with a blank message (e.g. an event log record with no strings):
+  # keep as-is, drop, or synthesize a message from tags/metadata
+  empty_message:
+    policy: "synthesize"
+    template: ""  # e.g. "{service} event {event_id}"; empty = metadata key=value pairs
+
+  # Mask sensitive values in messages (and optionally metadata strings)
+  # before they are buffered or shipped
+  redact:
+    presets: []  # email, ipv4, credit_card (Luhn-checked)
+    rules: []
+    #  - pattern: "(?:token|password)=(\\S+)"  # Masks only the value
+    placeholder: "***"
+    metadata: false
+
+  # While the agent's own CPU is above max_percent (of one core), drop DEBUG
+  # entries and pause schema sampling so log bursts don't starve the host
+  cpu_throttle:
+    enabled: false
+    max_percent: 50
+    interval: 5s
+
+  # Cap entries per second across all collectors (the agent's own reports
+  # are exempt); excess entries are dropped, or held back with mode "block".
+  # Collectors take the same rate_limit section for a cap of their own.
+  # rate_limit:
+  #   rate: 1000
+  #   burst: 2000  # Default: rate
+  #   mode: "drop"
+
+  # Keep only a share of entries from chatty streams. The decision hashes
+  # service and message, so the same line is consistently kept or dropped,
+  # also across restarts. Levels not listed use percent (or one_in).
+  sampling:
+    enabled: false
+    percent: 10  # Or one_in: 10
+    levels:
+      DEBUG: 1
+      ERROR: 100
+      FATAL: 100
+
+  # Periodically report metadata keys and sizes per service to spot schema drift
+  schema_report:
+    enabled: false
+    interval: 1h
+    sample_every: 10
+
+  # On exit, how long to wait for collectors to stop and the buffer to be
+  # flushed before giving up
+  shutdown_timeout: 30s
+
+  # Write the agent's PID here while it runs, e.g. for init scripts. A
+  # second agent with the same pid_file refuses to start; a file left by a
+  # crashed agent is replaced.
+  pid_file: ""  # e.g. /run/logchat-agent.pid
+
 # Local buffer for when server is unavailable
 buffer:
-  # Type: memory, file
+  # Type: memory, file, sqlite (sqlite requires a build with -tags sqlite)
   type: "memory"
   
-  # Path for file buffer
+  # Directory for the file buffer; for sqlite a database file, or a
+  # directory to create buffer.db in
   path: "/var/lib/logchat/buffer"
   
   # Maximum buffer size in bytes (100MB)
@@ -317,6 +1876,18 @@ buffer:
   # Maximum number of buffered items
   max_items: 10000
 
+  # Snapshot the memory buffer to path at this interval (0 = disabled)
+  persist_interval: 0s
+
+  # Cap on all files under path, e.g. 1GB (0 = unlimited). Old backups,
+  # dead letters and stale temp files are pruned first, then the oldest
+  # buffered entries.
+  max_disk_bytes: 0
+
+  # The file buffer appends to a log and reclaims delivered entries once
+  # this many bytes of the log are spent (8MB)
+  compact_threshold: 8388608
+
 # Log collectors configuration
 collectors:
   # File-based log collection
@@ -329,9 +1900,19 @@ collectors:
       exclude:
         - "*.gz"
         - "*.old"
-      recursive: false
+      recursive: false  # Match file names at any depth; "**" in a path does the same
       service: "system"
       parser: "plain"
+      schema_version: ""  # Stamped into metadata; "auto" derives it from this config
+      include_offset: false  # Add file_path and file_offset to each entry
+      include_rotated: false  # Backfill app.log.1, app.log.2.gz once at startup; .gz files are decompressed
+      read_from: "end"  # "beginning" also ingests existing contents; saved checkpoints take precedence
+      discovery_interval: 30s  # Re-scan paths for new files, e.g. app-2024-06-01.log
+      checkpoint_dir: ""  # e.g. /var/lib/logchat/checkpoints; resume after restarts
+      checkpoint_report_interval: 0s  # Report each file's offset and lag, 0 = off
+      min_level: ""  # e.g. "INFO" to drop DEBUG lines from these files
+      # rate_limit: { rate: 500, burst: 1000 }  # Entries per second; "rate_limited" in stats counts drops
+      repeat_window: 0s  # e.g. 30s: ship identical consecutive lines once, then one entry with repeat_count
       tags:
         source: "file"
     
@@ -340,9 +1921,45 @@ collectors:
         - "/var/log/nginx/*.log"
       service: "nginx"
       parser: "regex"
-      parse_regex: '^(?P<remote_addr>\S+) .* \[(?P<time_local>[^\]]+)\] "(?P<request>[^"]*)" (?P<status>\d+)'
+      parse_regex: '^(?P<remote_addr>\S+) .* \[(?P<time>[^\]]+)\] "(?P<request>[^"]*)" (?P<status>\d+)'
+      time_format: "02/Jan/2006:15:04:05 -0700"  # Go layouts tried in order, or unix / unix_ms
       tags:
         source: "nginx"
+
+    # Lines like "[2024-01-02 10:00:00] [ERROR] [module] message"
+    - enabled: false
+      paths:
+        - "/var/log/app/*.log"
+      service: "app"
+      parser: "bracketed"
+      bracket_fields: ["timestamp", "level", "module"]
+      # Join stack traces: lines not starting with "[" belong to the previous entry
+      multiline:
+        pattern: '^\['
+        negate: true
+        match: "after"
+        timeout: 2s
+
+    # CSV access log with a header row
+    - enabled: false
+      paths:
+        - "/var/log/appliance/access.csv"
+      service: "appliance"
+      parser: "csv"
+      csv_delimiter: ","
+      csv_columns: []  # Empty: use each file's first row as the header
+      csv_message_column: "request"
+      csv_level_column: ""
+      csv_timestamp_column: "time"
+
+    # State file: emit an event only when "status" changes
+    - enabled: false
+      paths:
+        - "/var/run/app/status.json"
+      service: "app-status"
+      on_change:
+        field: "status"
+        interval: 10s
 `, runtime.GOOS, hostname)
 
 	// Add platform-specific collectors
@@ -358,13 +1975,25 @@ collectors:
     since: "-1h"
     service: "journald"
     priority: 4  # Warning and above
+    checkpoint_dir: ""  # e.g. /var/lib/logchat/checkpoints; resume after restarts without gaps
+    # slices: ["machine.slice"]       # Only entries from these slices
+    # cgroups: ["/system.slice/docker"] # Only entries under these cgroup paths
+    # matches: ["_COMM=sshd", "_COMM=sudo"] # Field matches; same field OR, different fields AND, "+" = OR group
 
-  # Syslog listener (Linux only)
+  # Syslog listeners (Linux only). A single mapping or a list of listeners.
   syslog:
-    enabled: false
-    address: "unix:///dev/log"
-    protocol: "rfc3164"
-    service: "syslog"
+    - enabled: false
+      address: "unix:///dev/log"
+      protocol: "rfc3164"
+      service: "syslog"
+    # - enabled: false
+    #   address: "udp://0.0.0.0:514"
+    #   protocol: "auto"         # rfc3164, rfc5424, or detect per message
+    #   framing: "auto"          # TCP only: octet (RFC 6587 counting), newline, or detect
+    #   service: "syslog-remote"
+    #   sd_tags:                 # RFC 5424 structured-data params to promote to tags
+    #     tenant: ""             # Keep the param name
+    #     origin@123.app_id: app # One element's param, renamed
 `
 	}
 
@@ -377,7 +2006,11 @@ collectors:
       - "Application"
       - "System"
       - "Security"
+      # - "Microsoft-Windows-PowerShell/Operational"
+    # query: "*[System[(Level=1 or Level=2 or Level=3)]]"  # XPath filter, default all events
     service: "windows"
+    backfill_count: 0  # Ship the last N events per channel on startup
+    checkpoint_dir: ""  # e.g. C:\ProgramData\LogChat\checkpoints; resume after restarts
 `
 	}
 
@@ -386,7 +2019,15 @@ collectors:
   docker:
     enabled: false
     socket: "/var/run/docker.sock"
-    containers: []  # Empty = all containers
+    containers: []  # Names or IDs, empty = all containers
+    labels: []      # e.g. ["logging=enabled"]
+    since: "1h"     # Backfill window for containers already running
+
+  # Podman container logs (same options as docker). The socket defaults to the
+  # running user's rootless socket, or /run/podman/podman.sock for root.
+  podman:
+    enabled: false
+    containers: []
     since: "1h"
 
   # Command execution (run commands periodically)
@@ -397,6 +2038,54 @@ collectors:
       interval: 60s
       service: "disk-usage"
       timeout: 10s
+      dedupe_unchanged: true  # Skip runs whose output didn't change
+      keepalive: 1h           # ...but ship at least this often
+      split_lines: false      # One entry per output line instead of one per run
+    # - enabled: false
+    #   command: "ps aux | grep nginx | grep -v grep"
+    #   shell: true             # Run through /bin/sh -c (cmd /c on Windows)
+    #   env:
+    #     LC_ALL: "C"
+    #   work_dir: "/tmp"
+    #   service: "nginx-procs"
+
+  # Read lines piped into the agent (also enabled by the -stdin flag).
+  # The agent exits after a final flush when input ends.
+  stdin:
+    enabled: false
+    service: "stdin"
+    parser: "plain"
+
+  # Receive JSON logs POSTed by local apps, one object or an array per request
+  http:
+    enabled: false
+    address: "127.0.0.1:9880"
+    path: "/logs"
+    api_key: ""
+    max_body_size: 1048576
+
+  # Plain newline-delimited text streamed to a TCP or UDP port by devices
+  # that don't speak syslog; each line becomes one entry
+  net:
+    - enabled: false
+      address: "tcp://0.0.0.0:5140"
+      service: "appliance"
+      max_line_size: 65536
+
+  # Container logs on a Kubernetes node (run the agent as a DaemonSet with
+  # /var/log/pods mounted). Entries are tagged with namespace, pod and
+  # container, and labels fetched from the API server when enabled.
+  kubernetes:
+    enabled: false
+    paths:
+      - /var/log/pods/*/*/*.log
+    labels: true
+
+  # Emit a small "logchat-agent" entry with uptime and collector counts even
+  # when nothing else is collected, so downstream can alert on silent agents
+  heartbeat:
+    enabled: false
+    interval: 60s
 `
 
 	return os.WriteFile("logchat-agent.yaml", []byte(sample), 0644)
@@ -0,0 +1,139 @@
+// Package logging is the agent's own leveled log output. Components get a
+// logger tagged with their name; Setup picks the level and the format, text
+// for people or JSON for when the agent's output is itself collected.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	level   = new(slog.LevelVar)
+	useJSON atomic.Bool
+	output  = &switchWriter{w: os.Stdout}
+
+	root = &handler{
+		text: slog.NewTextHandler(output, &slog.HandlerOptions{Level: level}),
+		json: slog.NewJSONHandler(output, &slog.HandlerOptions{Level: level}),
+	}
+)
+
+func init() {
+	if verboseEnv() {
+		level.Set(slog.LevelDebug)
+	}
+	slog.SetDefault(slog.New(root))
+}
+
+// Setup applies the configured level (debug, info, warn, error; default
+// info) and format (text or json). LOGCHAT_VERBOSE=1 or LOGCHAT_DEBUG=1
+// force debug. Loggers created earlier follow the change.
+func Setup(levelName, format string) error {
+	l, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+	if verboseEnv() {
+		l = slog.LevelDebug
+	}
+
+	switch format {
+	case "", "text":
+		useJSON.Store(false)
+	case "json":
+		useJSON.Store(true)
+	default:
+		return fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+
+	level.Set(l)
+	return nil
+}
+
+// ParseLevel maps a level name onto its slog level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+}
+
+// SetOutput sends log output to w instead of standard output. Loggers
+// created earlier follow the change.
+func SetOutput(w io.Writer) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	output.w = w
+}
+
+// JSON reports whether output is JSON, for callers that would otherwise
+// print decorations meant for a terminal
+func JSON() bool {
+	return useJSON.Load()
+}
+
+// For returns the logger of a component
+func For(component string) *slog.Logger {
+	return slog.New(root).With("component", component)
+}
+
+// verboseEnv reports whether the environment asks for debug output
+func verboseEnv() bool {
+	return os.Getenv("LOGCHAT_VERBOSE") == "1" || os.Getenv("LOGCHAT_DEBUG") == "1"
+}
+
+// switchWriter writes to a destination that SetOutput can replace
+type switchWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// handler writes through the text or the JSON handler, whichever Setup
+// selected last
+type handler struct {
+	text slog.Handler
+	json slog.Handler
+}
+
+func (h *handler) current() slog.Handler {
+	if useJSON.Load() {
+		return h.json
+	}
+	return h.text
+}
+
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.current().Enabled(ctx, l)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{text: h.text.WithAttrs(attrs), json: h.json.WithAttrs(attrs)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{text: h.text.WithGroup(name), json: h.json.WithGroup(name)}
+}
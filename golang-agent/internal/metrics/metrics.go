@@ -0,0 +1,190 @@
+// Package metrics serves agent statistics in the Prometheus text format
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"logchat/agent/internal/collector"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/sender"
+)
+
+// Server exposes collector and sender stats on an HTTP endpoint. Values
+// are read from the components' Stats at scrape time, so nothing is
+// registered up front and there is no state to keep in sync.
+type Server struct {
+	address    string
+	path       string
+	sender     *sender.Sender
+	collectors func() []collector.Collector // Current set, which reloads change
+}
+
+// New creates the metrics server, or returns nil when disabled
+func New(cfg *config.MetricsConfig, snd *sender.Sender, collectors func() []collector.Collector) *Server {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	address := cfg.Address
+	if address == "" {
+		address = "127.0.0.1:9464"
+	}
+	path := cfg.Path
+	if path == "" {
+		path = "/metrics"
+	}
+
+	return &Server{
+		address:    address,
+		path:       path,
+		sender:     snd,
+		collectors: collectors,
+	}
+}
+
+// Start serves metrics until the context ends
+func (s *Server) Start(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.path, s.handle)
+
+	srv := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log := logging.For("metrics")
+	log.Info("Serving Prometheus metrics", "url", "http://"+s.address+s.path)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Error("Server error", "error", err)
+	}
+}
+
+// handle writes the current metrics
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.write(w)
+}
+
+// family is one metric with its samples
+type family struct {
+	name, help, kind string
+	samples          []sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+// write renders every metric family
+func (s *Server) write(w io.Writer) {
+	collected := &family{name: "logchat_logs_collected_total", help: "Log entries collected.", kind: "counter"}
+	errors := &family{name: "logchat_collector_errors_total", help: "Collector errors.", kind: "counter"}
+	running := &family{name: "logchat_collector_running", help: "Whether the collector is running.", kind: "gauge"}
+
+	// Several collectors can share a name, e.g. two file collectors for one
+	// service; the instance label keeps their series apart
+	seen := make(map[string]int)
+	for _, c := range s.collectors() {
+		stats := c.Stats()
+		name := c.Name()
+		labels := fmt.Sprintf(`collector="%s",instance="%d"`, escapeLabel(name), seen[name])
+		seen[name]++
+
+		collected.add(labels, stats["logs_collected"])
+		errors.add(labels, stats["errors_count"])
+		running.add(labels, stats["running"])
+	}
+
+	stats := s.sender.Stats()
+	families := []*family{
+		collected,
+		errors,
+		running,
+		single("logchat_logs_sent_total", "Log entries delivered to the server.", "counter", stats["sent_count"]),
+		single("logchat_send_errors_total", "Failed send attempts.", "counter", stats["error_count"]),
+		single("logchat_duplicates_skipped_total", "Entries skipped as already delivered.", "counter", stats["dup_skipped"]),
+		single("logchat_buffer_length", "Entries waiting to be sent.", "gauge", stats["buffer_length"]),
+		single("logchat_buffer_bytes", "Serialized bytes waiting to be sent.", "gauge", stats["buffer_bytes"]),
+		single("logchat_server_alive", "Whether the server is reachable.", "gauge", stats["server_alive"]),
+		single("logchat_requests_in_flight", "Ingest requests in progress.", "gauge", stats["in_flight"]),
+		single("logchat_consecutive_send_failures", "Send failures since the last success.", "gauge", stats["consecutive_failures"]),
+		single("logchat_server_switchovers_total", "Switches between server URLs.", "counter", stats["switchovers"]),
+	}
+
+	if lengths, ok := stats["class_buffer_lengths"].(map[string]int); ok {
+		classes := &family{name: "logchat_class_buffer_length", help: "Entries waiting per delivery class.", kind: "gauge"}
+		names := make([]string, 0, len(lengths))
+		for name := range lengths {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			classes.add(fmt.Sprintf(`class="%s"`, escapeLabel(name)), lengths[name])
+		}
+		families = append(families, classes)
+	}
+
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, smp := range f.samples {
+			value := strconv.FormatFloat(smp.value, 'g', -1, 64)
+			if smp.labels == "" {
+				fmt.Fprintf(w, "%s %s\n", f.name, value)
+			} else {
+				fmt.Fprintf(w, "%s{%s} %s\n", f.name, smp.labels, value)
+			}
+		}
+	}
+}
+
+// single builds a family with one unlabeled sample
+func single(name, help, kind string, value any) *family {
+	f := &family{name: name, help: help, kind: kind}
+	f.add("", value)
+	return f
+}
+
+// add appends a sample if the stat has a numeric or boolean value
+func (f *family) add(labels string, value any) {
+	var v float64
+	switch n := value.(type) {
+	case int:
+		v = float64(n)
+	case int64:
+		v = float64(n)
+	case float64:
+		v = n
+	case bool:
+		if n {
+			v = 1
+		}
+	default:
+		return
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: v})
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package pidfile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// alive reports whether a process with the given ID exists. A process owned
+// by another user can't be signalled but still counts.
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,73 @@
+// Package pidfile records the agent's process ID so init scripts can find
+// it, and so a second agent can't start against the same buffer and
+// checkpoints
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Acquire writes the current process ID to path. It fails if the file
+// names another process that is still running; a file left behind by a
+// process that is gone is replaced. The returned function removes the file
+// again, unless another process has taken it over since.
+func Acquire(path string) (release func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	pid := os.Getpid()
+	// Two attempts: the second follows removing a stale file
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := fmt.Fprintf(f, "%d\n", pid)
+			if cerr := f.Close(); werr == nil {
+				werr = cerr
+			}
+			if werr != nil {
+				os.Remove(path)
+				return nil, werr
+			}
+			return func() { remove(path, pid) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		owner, err := read(path)
+		if err == nil && owner != pid && alive(owner) {
+			return nil, fmt.Errorf("%s: agent already running with PID %d", path, owner)
+		}
+		// Stale, unreadable or our own PID from before a restart
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%s: taken over by another process while starting", path)
+}
+
+// read returns the process ID stored in a PID file
+func read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid PID file contents %q", strings.TrimSpace(string(data)))
+	}
+	return pid, nil
+}
+
+// remove deletes the PID file if it still holds pid
+func remove(path string, pid int) {
+	if owner, err := read(path); err == nil && owner == pid {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+)
+
+// overflowValue replaces values beyond a dimension's limit
+const overflowValue = "_overflow"
+
+// CardinalityLimit caps the number of distinct values seen for the service
+// and selected tags, collapsing new values into an overflow bucket
+type CardinalityLimit struct {
+	mu     sync.Mutex
+	limits map[string]int
+	seen   map[string]map[string]struct{}
+	warned map[string]bool
+}
+
+func newCardinalityLimit(cfg config.ProcessorConfig) (*CardinalityLimit, error) {
+	if len(cfg.Limits) == 0 {
+		return nil, fmt.Errorf("cardinality_limit requires limits")
+	}
+
+	for dim, limit := range cfg.Limits {
+		if dim != "service" && !strings.HasPrefix(dim, "tags.") {
+			return nil, fmt.Errorf("cardinality_limit: unsupported dimension %q (use service or tags.<name>)", dim)
+		}
+		if limit <= 0 {
+			return nil, fmt.Errorf("cardinality_limit: limit for %s must be positive", dim)
+		}
+	}
+
+	return &CardinalityLimit{
+		limits: cfg.Limits,
+		seen:   make(map[string]map[string]struct{}),
+		warned: make(map[string]bool),
+	}, nil
+}
+
+// Name returns the processor name
+func (p *CardinalityLimit) Name() string {
+	return "cardinality_limit"
+}
+
+// Process rewrites over-limit dimension values to the overflow bucket
+func (p *CardinalityLimit) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for dim, limit := range p.limits {
+		if dim == "service" {
+			entry.Service = p.admit(dim, entry.Service, limit)
+			continue
+		}
+
+		tag := strings.TrimPrefix(dim, "tags.")
+		if v, ok := entry.Tags[tag]; ok {
+			entry.Tags[tag] = p.admit(dim, v, limit)
+		}
+	}
+
+	return []buffer.LogEntry{entry}
+}
+
+// admit returns value if it is known or fits under the limit, otherwise the
+// overflow bucket. The first overflow per dimension logs a warning.
+func (p *CardinalityLimit) admit(dim, value string, limit int) string {
+	values := p.seen[dim]
+	if values == nil {
+		values = make(map[string]struct{})
+		p.seen[dim] = values
+	}
+
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) < limit {
+		values[value] = struct{}{}
+		return value
+	}
+
+	if !p.warned[dim] {
+		p.warned[dim] = true
+		logging.For("processor").Warn("Cardinality limit reached, new values go to the overflow bucket",
+			"dimension", dim, "limit", limit, "overflow", overflowValue, "first", value)
+	}
+	return overflowValue
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// templateField matches {name} placeholders in an empty_message template
+var templateField = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// EmptyMessage applies the agent-wide policy for entries without a message:
+// keep them as-is, drop them, or synthesize a message from their fields
+type EmptyMessage struct {
+	policy   string
+	template string
+}
+
+// NewEmptyMessage creates the empty-message policy step. A nil config
+// synthesizes a message from metadata.
+func NewEmptyMessage(cfg *config.EmptyMessageConfig) (*EmptyMessage, error) {
+	p := &EmptyMessage{policy: "synthesize"}
+	if cfg != nil {
+		if cfg.Policy != "" {
+			p.policy = cfg.Policy
+		}
+		p.template = cfg.Template
+	}
+
+	switch p.policy {
+	case "keep", "drop", "synthesize":
+	default:
+		return nil, fmt.Errorf("unknown empty_message policy: %s", p.policy)
+	}
+
+	return p, nil
+}
+
+// Name returns the processor name
+func (p *EmptyMessage) Name() string {
+	return "empty_message"
+}
+
+// Process applies the policy when the entry message is blank
+func (p *EmptyMessage) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	if strings.TrimSpace(entry.Message) != "" {
+		return []buffer.LogEntry{entry}
+	}
+
+	switch p.policy {
+	case "drop":
+		return nil
+	case "synthesize":
+		if p.template != "" {
+			entry.Message = renderTemplate(p.template, entry)
+		} else {
+			entry.Message = formatFields(entry.Metadata)
+		}
+	}
+
+	return []buffer.LogEntry{entry}
+}
+
+// renderTemplate replaces {name} with the entry's level, service or source,
+// or else the matching tag or metadata value
+func renderTemplate(template string, entry buffer.LogEntry) string {
+	return templateField.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[1 : len(match)-1]
+
+		switch name {
+		case "level":
+			return entry.Level
+		case "service":
+			return entry.Service
+		case "source":
+			return entry.Source
+		}
+
+		if v, ok := entry.Tags[name]; ok {
+			return v
+		}
+		if v, ok := entry.Metadata[name]; ok {
+			return fmt.Sprint(v)
+		}
+		return ""
+	})
+}
+
+// formatFields renders metadata as sorted key=value pairs
+func formatFields(fields map[string]any) string {
+	keys := make([]string, 0, len(fields))
+	for k, v := range fields {
+		if v == nil || v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, fields[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"strings"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// defaultLevelMapping maps level variants (upper-cased) to canonical levels
+var defaultLevelMapping = map[string]string{
+	"TRACE": "DEBUG", "DEBUG": "DEBUG", "DBG": "DEBUG", "D": "DEBUG", "VERBOSE": "DEBUG", "7": "DEBUG",
+	"INFO": "INFO", "INF": "INFO", "I": "INFO", "INFORMATION": "INFO", "INFORMATIONAL": "INFO",
+	"NOTICE": "INFO", "5": "INFO", "6": "INFO",
+	"WARN": "WARN", "WARNING": "WARN", "WRN": "WARN", "W": "WARN", "4": "WARN",
+	"ERROR": "ERROR", "ERR": "ERROR", "E": "ERROR", "SEVERE": "ERROR", "3": "ERROR",
+	"FATAL": "FATAL", "CRIT": "FATAL", "CRITICAL": "FATAL", "ALERT": "FATAL", "EMERG": "FATAL",
+	"EMERGENCY": "FATAL", "PANIC": "FATAL", "F": "FATAL", "0": "FATAL", "1": "FATAL", "2": "FATAL",
+}
+
+// levelSeverity orders the canonical levels, least severe first
+var levelSeverity = map[string]int{"DEBUG": 0, "INFO": 1, "WARN": 2, "ERROR": 3, "FATAL": 4}
+
+// BelowLevel reports whether level is less severe than threshold. Level
+// variants are recognized as by normalize_level; unknown levels and an empty
+// threshold never count as below.
+func BelowLevel(level, threshold string) bool {
+	if threshold == "" {
+		return false
+	}
+
+	canonical, _ := CanonicalLevel(level)
+	rank, ok := levelSeverity[canonical]
+	limit, limitOK := levelSeverity[strings.ToUpper(threshold)]
+	return ok && limitOK && rank < limit
+}
+
+// CanonicalLevel maps a level variant onto DEBUG, INFO, WARN, ERROR or
+// FATAL, reporting false for levels it doesn't recognize
+func CanonicalLevel(level string) (string, bool) {
+	canonical, ok := defaultLevelMapping[strings.ToUpper(strings.TrimSpace(level))]
+	return canonical, ok
+}
+
+// NormalizeLevel maps level variants onto a canonical set, keeping the
+// original value in metadata.original_level
+type NormalizeLevel struct {
+	mapping map[string]string
+}
+
+func newNormalizeLevel(cfg config.ProcessorConfig) (*NormalizeLevel, error) {
+	mapping := make(map[string]string, len(defaultLevelMapping)+len(cfg.Mapping))
+	for k, v := range defaultLevelMapping {
+		mapping[k] = v
+	}
+	for k, v := range cfg.Mapping {
+		mapping[strings.ToUpper(k)] = v
+	}
+
+	return &NormalizeLevel{mapping: mapping}, nil
+}
+
+// Name returns the processor name
+func (p *NormalizeLevel) Name() string {
+	return "normalize_level"
+}
+
+// Process rewrites the entry level when it is a recognized variant
+func (p *NormalizeLevel) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	canonical, ok := p.mapping[strings.ToUpper(strings.TrimSpace(entry.Level))]
+	if !ok || canonical == entry.Level {
+		return []buffer.LogEntry{entry}
+	}
+
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]any)
+	}
+	entry.Metadata["original_level"] = entry.Level
+	entry.Level = canonical
+
+	return []buffer.LogEntry{entry}
+}
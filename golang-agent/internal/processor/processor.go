@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"fmt"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// Processor transforms a log entry into zero or more entries
+type Processor interface {
+	Name() string
+	Process(entry buffer.LogEntry) []buffer.LogEntry
+}
+
+// Chain applies processors in order
+type Chain []Processor
+
+// New creates a processor chain from configuration
+func New(cfgs []config.ProcessorConfig) (Chain, error) {
+	var chain Chain
+
+	for i, cfg := range cfgs {
+		var p Processor
+		var err error
+
+		switch cfg.Type {
+		case "split_by_field":
+			p, err = newSplitByField(cfg)
+		case "normalize_level":
+			p, err = newNormalizeLevel(cfg)
+		case "cardinality_limit":
+			p, err = newCardinalityLimit(cfg)
+		case "compact_stack_traces":
+			p, err = newCompactStackTraces(cfg)
+		default:
+			err = fmt.Errorf("unknown processor type: %s", cfg.Type)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("processors[%d]: %w", i, err)
+		}
+		chain = append(chain, p)
+	}
+
+	return chain, nil
+}
+
+// Process runs entry through every processor in the chain
+func (c Chain) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	entries := []buffer.LogEntry{entry}
+
+	for _, p := range c {
+		var next []buffer.LogEntry
+		for _, e := range entries {
+			next = append(next, p.Process(e)...)
+		}
+		entries = next
+		if len(entries) == 0 {
+			break
+		}
+	}
+
+	return entries
+}
+
+// cloneEntry copies an entry including its tag and metadata maps
+func cloneEntry(entry buffer.LogEntry) buffer.LogEntry {
+	clone := entry
+
+	if entry.Tags != nil {
+		clone.Tags = make(map[string]string, len(entry.Tags))
+		for k, v := range entry.Tags {
+			clone.Tags[k] = v
+		}
+	}
+
+	if entry.Metadata != nil {
+		clone.Metadata = make(map[string]any, len(entry.Metadata))
+		for k, v := range entry.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+
+	return clone
+}
@@ -0,0 +1,185 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// redactPresets are the built-in rules selectable by name
+var redactPresets = map[string]*regexp.Regexp{
+	"email":       regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"ipv4":        regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	"credit_card": regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+}
+
+// redactRule replaces matches of one pattern
+type redactRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+	check       func(match string) bool // Optional filter on matches, e.g. Luhn
+}
+
+// Redact masks sensitive values such as emails, card numbers and tokens in
+// entry messages, and optionally in metadata string values, before they
+// leave the host
+type Redact struct {
+	rules    []redactRule
+	metadata bool
+}
+
+// NewRedact compiles the redaction rules once, returning nil when none are
+// configured
+func NewRedact(cfg *config.RedactConfig) (*Redact, error) {
+	if cfg == nil || (len(cfg.Presets) == 0 && len(cfg.Rules) == 0) {
+		return nil, nil
+	}
+
+	placeholder := cfg.Placeholder
+	if placeholder == "" {
+		placeholder = "***"
+	}
+
+	p := &Redact{metadata: cfg.Metadata}
+
+	for _, name := range cfg.Presets {
+		pattern, ok := redactPresets[name]
+		if !ok {
+			return nil, fmt.Errorf("redact: unknown preset %q (use email, ipv4 or credit_card)", name)
+		}
+		rule := redactRule{pattern: pattern, replacement: placeholder}
+		if name == "credit_card" {
+			// Long digit runs such as millisecond timestamps aren't cards
+			rule.check = luhnValid
+		}
+		p.rules = append(p.rules, rule)
+	}
+
+	for i, r := range cfg.Rules {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact.rules[%d]: invalid pattern: %w", i, err)
+		}
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = placeholder
+		}
+		p.rules = append(p.rules, redactRule{pattern: pattern, replacement: replacement})
+	}
+
+	return p, nil
+}
+
+// Name returns the processor name
+func (p *Redact) Name() string {
+	return "redact"
+}
+
+// Process masks rule matches in the message and, if enabled, in metadata
+func (p *Redact) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	entry.Message = p.redact(entry.Message)
+
+	if p.metadata && len(entry.Metadata) > 0 {
+		// Collectors may share metadata maps, so build a new one
+		metadata := make(map[string]any, len(entry.Metadata))
+		for k, v := range entry.Metadata {
+			metadata[k] = p.redactValue(v)
+		}
+		entry.Metadata = metadata
+	}
+
+	return []buffer.LogEntry{entry}
+}
+
+// redact applies every rule to s
+func (p *Redact) redact(s string) string {
+	for _, rule := range p.rules {
+		if !rule.pattern.MatchString(s) {
+			continue
+		}
+		if rule.pattern.NumSubexp() > 0 {
+			s = rule.replaceGroups(s)
+			continue
+		}
+		s = rule.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if rule.check == nil || rule.check(match) {
+				return rule.replacement
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// replaceGroups replaces only the capture groups of each match, so a rule
+// like "password=(\S+)" keeps the key and masks the value
+func (rule redactRule) replaceGroups(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range rule.pattern.FindAllStringSubmatchIndex(s, -1) {
+		for g := 2; g+1 < len(loc); g += 2 {
+			start, end := loc[g], loc[g+1]
+			if start < last {
+				continue // Unmatched or nested inside a replaced group
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(rule.replacement)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// redactValue redacts strings in a metadata value, descending into nested
+// maps and lists
+func (p *Redact) redactValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		return p.redact(val)
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = p.redactValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = p.redactValue(item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, item := range val {
+			out[i] = p.redact(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum
+func luhnValid(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
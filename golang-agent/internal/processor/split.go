@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"fmt"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// SplitByField emits one entry per element of an array metadata field
+type SplitByField struct {
+	field     string
+	maxFanout int
+}
+
+func newSplitByField(cfg config.ProcessorConfig) (*SplitByField, error) {
+	if cfg.Field == "" {
+		return nil, fmt.Errorf("split_by_field requires a field")
+	}
+
+	maxFanout := cfg.MaxFanout
+	if maxFanout <= 0 {
+		maxFanout = 100
+	}
+
+	return &SplitByField{
+		field:     cfg.Field,
+		maxFanout: maxFanout,
+	}, nil
+}
+
+// Name returns the processor name
+func (p *SplitByField) Name() string {
+	return "split_by_field"
+}
+
+// Process splits the entry when the configured field holds an array
+func (p *SplitByField) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	items, ok := entry.Metadata[p.field].([]any)
+	if !ok || len(items) == 0 {
+		return []buffer.LogEntry{entry}
+	}
+
+	total := len(items)
+	if total > p.maxFanout {
+		items = items[:p.maxFanout]
+	}
+
+	entries := make([]buffer.LogEntry, 0, len(items))
+	for i, item := range items {
+		e := cloneEntry(entry)
+		delete(e.Metadata, p.field)
+
+		// Merge object elements into the metadata, keep scalars under the field name
+		if obj, ok := item.(map[string]any); ok {
+			for k, v := range obj {
+				e.Metadata[k] = v
+			}
+		} else {
+			e.Metadata[p.field] = item
+		}
+
+		e.Metadata["split_index"] = i
+		e.Metadata["split_total"] = total
+		entries = append(entries, e)
+	}
+
+	return entries
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// stackFrame matches frame lines of Java, Go and Python stack traces
+var stackFrame = regexp.MustCompile(`^(\s+at \S|\s+\.\.\. \d+ more|Caused by: |\t\S+\.go:\d+|\S+\(.*\)$|\s+File ".*", line \d+)`)
+
+// minStackFrames is how many frame lines make a message a stack trace
+const minStackFrames = 3
+
+// stackSeen tracks one trace signature within the window
+type stackSeen struct {
+	firstSeen time.Time
+	lastFull  time.Time
+	count     int
+}
+
+// CompactStackTraces replaces repeats of a recently shipped stack trace with
+// a reference to it, keeping the non-frame lines that vary between occurrences
+type CompactStackTraces struct {
+	mu         sync.Mutex
+	window     time.Duration
+	fullEvery  int
+	maxTracked int
+	seen       map[string]*stackSeen
+}
+
+func newCompactStackTraces(cfg config.ProcessorConfig) (*CompactStackTraces, error) {
+	p := &CompactStackTraces{
+		window:     cfg.Window,
+		fullEvery:  cfg.FullEvery,
+		maxTracked: cfg.MaxTracked,
+		seen:       make(map[string]*stackSeen),
+	}
+	if p.window <= 0 {
+		p.window = 10 * time.Minute
+	}
+	if p.fullEvery <= 0 {
+		p.fullEvery = 100
+	}
+	if p.maxTracked <= 0 {
+		p.maxTracked = 1000
+	}
+	return p, nil
+}
+
+// Name returns the processor name
+func (p *CompactStackTraces) Name() string {
+	return "compact_stack_traces"
+}
+
+// Process elides the frames of a repeated stack trace
+func (p *CompactStackTraces) Process(entry buffer.LogEntry) []buffer.LogEntry {
+	if !strings.Contains(entry.Message, "\n") {
+		return []buffer.LogEntry{entry}
+	}
+
+	lines := strings.Split(entry.Message, "\n")
+	var frames, rest []string
+	for _, line := range lines {
+		if stackFrame.MatchString(line) {
+			frames = append(frames, line)
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	if len(frames) < minStackFrames {
+		return []buffer.LogEntry{entry}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(frames, "\n")))
+	fingerprint := hex.EncodeToString(sum[:8])
+
+	full, occurrence := p.track(fingerprint, time.Now())
+
+	if entry.Metadata == nil {
+		entry.Metadata = make(map[string]any)
+	}
+	entry.Metadata["stack_fingerprint"] = fingerprint
+	entry.Metadata["stack_occurrence"] = occurrence
+
+	if !full {
+		entry.Metadata["stack_elided"] = true
+		rest = append(rest, fmt.Sprintf("[%d stack frames elided, same as stack_fingerprint %s]", len(frames), fingerprint))
+		entry.Message = strings.Join(rest, "\n")
+	}
+
+	return []buffer.LogEntry{entry}
+}
+
+// track records an occurrence and reports whether the full trace should be
+// shipped: on first sight, after the window expires, and every fullEvery
+// occurrences so a reference never points too far back
+func (p *CompactStackTraces) track(fingerprint string, now time.Time) (bool, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.seen[fingerprint]
+	if ok && now.Sub(s.lastFull) > p.window {
+		ok = false
+	}
+
+	if !ok {
+		if len(p.seen) >= p.maxTracked {
+			p.evict(now)
+		}
+		if len(p.seen) >= p.maxTracked {
+			// Table full of live traces; ship in full rather than grow
+			return true, 1
+		}
+		p.seen[fingerprint] = &stackSeen{firstSeen: now, lastFull: now, count: 1}
+		return true, 1
+	}
+
+	s.count++
+	if s.count%p.fullEvery == 0 {
+		s.lastFull = now
+		return true, s.count
+	}
+	return false, s.count
+}
+
+// evict drops traces whose window has expired
+func (p *CompactStackTraces) evict(now time.Time) {
+	for fp, s := range p.seen {
+		if now.Sub(s.lastFull) > p.window {
+			delete(p.seen, fp)
+		}
+	}
+}
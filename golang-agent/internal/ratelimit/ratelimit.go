@@ -0,0 +1,67 @@
+// Package ratelimit caps how many entries per second pass a point in the
+// pipeline, so one noisy source can't flood the buffer and the server
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"logchat/agent/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter admits entries at a steady rate with a burst allowance. Excess
+// entries are dropped and counted, or in block mode held until allowed.
+type Limiter struct {
+	limiter *rate.Limiter
+	block   bool
+	dropped int64 // atomic
+}
+
+// New creates a limiter, nil when cfg is unset or has no rate
+func New(cfg *config.RateLimitConfig) *Limiter {
+	if cfg == nil || cfg.Rate <= 0 {
+		return nil
+	}
+
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(cfg.Rate))
+	}
+
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(cfg.Rate), burst),
+		block:   cfg.Mode == "block",
+	}
+}
+
+// Allow reports whether an entry may pass. In block mode it waits for its
+// turn, which slows the collector down instead of losing entries. A nil
+// limiter allows everything.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+
+	if l.block {
+		// Can't fail: the burst is at least one and there's no deadline
+		l.limiter.Wait(context.Background())
+		return true
+	}
+
+	if l.limiter.Allow() {
+		return true
+	}
+	atomic.AddInt64(&l.dropped, 1)
+	return false
+}
+
+// Dropped returns how many entries were dropped over the limit
+func (l *Limiter) Dropped() int64 {
+	if l == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&l.dropped)
+}
@@ -0,0 +1,97 @@
+package sender
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+const (
+	defaultBackoffBase = time.Second
+	defaultMaxBackoff  = 2 * time.Minute
+)
+
+// recordFailure counts a failed send and schedules the next attempt after
+// an exponential delay. Half the delay is randomized so a fleet of agents
+// doesn't reconnect in lockstep when the server comes back.
+func (s *Sender) recordFailure() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures++
+	if s.breaker.failure(s.consecutiveFailures) {
+		s.logger().Warn("Circuit breaker open, pausing sends until the server is healthy", "failures", s.consecutiveFailures)
+	}
+
+	delay := s.backoffBase
+	for i := 1; i < s.consecutiveFailures && delay < s.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > s.maxBackoff {
+		delay = s.maxBackoff
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	s.retryAt = time.Now().Add(delay)
+	return delay
+}
+
+// recordSuccess clears the backoff after a successful send
+func (s *Sender) recordSuccess() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.consecutiveFailures = 0
+	s.retryAt = time.Time{}
+	if s.breaker.success() {
+		s.logger().Info("Circuit breaker closed, sends resumed")
+	}
+}
+
+// countAttempt records a failed send of the batch at the head of a lane.
+// Once the batch has failed more than maxRetries retries it is dead-lettered
+// or dropped so the entries behind it can move.
+func (s *Sender) countAttempt(l *lane, batch []buffer.LogEntry, cause error) {
+	s.mu.Lock()
+	l.attempts++
+	if l.failingSince.IsZero() {
+		l.failingSince = time.Now()
+	}
+	attempts, since := l.attempts, l.failingSince
+	s.mu.Unlock()
+
+	if s.maxRetries == 0 || attempts <= s.maxRetries || len(batch) == 0 {
+		return
+	}
+
+	cause = fmt.Errorf("gave up after %d retries over %s: %w", attempts-1, time.Since(since).Round(time.Second), cause)
+	if s.giveUp == "dead_letter" && s.deadLetter != nil {
+		path, err := s.deadLetter.write(s.activeURL(), batch, cause)
+		if err != nil {
+			// Keep the batch rather than lose it
+			s.logger().Error("Error writing dead letter", "error", err)
+			return
+		}
+		atomic.AddInt64(&s.deadLettered, int64(len(batch)))
+		s.logger().Warn("Giving up on batch, moved to dead letters", "count", len(batch), "cause", cause, "path", path)
+	} else {
+		s.logger().Warn("Giving up on batch, dropped", "count", len(batch), "cause", cause)
+	}
+
+	s.mu.Lock()
+	l.buffer.Remove(len(batch))
+	l.attempts, l.failingSince = 0, time.Time{}
+	s.givenUp += int64(len(batch))
+	s.mu.Unlock()
+	s.deadLetter.forget(batch)
+}
+
+// backingOff reports whether sends are paused after recent failures
+func (s *Sender) backingOff() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Now().Before(s.retryAt)
+}
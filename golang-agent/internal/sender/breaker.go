@@ -0,0 +1,117 @@
+package sender
+
+import (
+	"sync"
+	"time"
+
+	"logchat/agent/internal/config"
+)
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"    // Flushing normally
+	breakerOpen     = "open"      // Server down, flushes skipped
+	breakerHalfOpen = "half_open" // Health check passed, next flush probes
+)
+
+// breaker skips flushes while the server is known to be down, so a long
+// outage doesn't cost a full batch POST and timeout per flush. It opens
+// after consecutive failures, half-opens when a health check passes, and
+// closes on the next successful send.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	state     string
+	openedAt  time.Time
+	opens     int64
+	skipped   int64
+}
+
+// newBreaker creates a breaker, nil when disabled
+func newBreaker(cfg *config.CircuitBreakerConfig) *breaker {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	threshold := cfg.Failures
+	if threshold <= 0 {
+		threshold = 5
+	}
+	return &breaker{threshold: threshold, state: breakerClosed}
+}
+
+// allow reports whether a flush may send, counting the ones skipped
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen {
+		b.skipped++
+		return false
+	}
+	return true
+}
+
+// failure records a failed send and reports whether it opened the breaker.
+// A failed probe while half-open reopens it straight away.
+func (b *breaker) failure(consecutive int) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen || (b.state == breakerClosed && consecutive < b.threshold) {
+		return false
+	}
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.opens++
+	return true
+}
+
+// success closes the breaker, reporting whether it wasn't closed
+func (b *breaker) success() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerClosed {
+		return false
+	}
+	b.state = breakerClosed
+	b.openedAt = time.Time{}
+	return true
+}
+
+// healthy half-opens an open breaker after a passing health check,
+// reporting whether it did
+func (b *breaker) healthy() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerOpen {
+		return false
+	}
+	b.state = breakerHalfOpen
+	return true
+}
+
+// stats reports the breaker's state and counters
+func (b *breaker) stats() map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := map[string]any{
+		"state":           b.state,
+		"failures":        b.threshold,
+		"opens":           b.opens,
+		"skipped_flushes": b.skipped,
+	}
+	if !b.openedAt.IsZero() {
+		stats["opened_at"] = b.openedAt
+	}
+	return stats
+}
@@ -0,0 +1,71 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+// HealthResult is the outcome of probing one server URL
+type HealthResult struct {
+	URL string // Including the health path
+	OK  bool
+}
+
+// CheckHealth probes every server URL's health endpoint without sending
+// any logs
+func (s *Sender) CheckHealth(ctx context.Context) []HealthResult {
+	results := make([]HealthResult, len(s.urls))
+	for i, url := range s.urls {
+		results[i] = HealthResult{URL: url + s.healthPath, OK: s.probe(ctx, url)}
+	}
+	return results
+}
+
+// CheckServer verifies the server end to end: every URL's health endpoint
+// must answer, and the active URL must accept a synthetic test entry. The
+// payload is flagged as a test so servers that honor it don't store it.
+func (s *Sender) CheckServer(ctx context.Context) error {
+	for _, url := range s.urls {
+		if s.probe(ctx, url) {
+			fmt.Printf("  ✓ Health check passed: %s%s\n", url, s.healthPath)
+		} else {
+			fmt.Printf("  ✗ Health check failed: %s%s\n", url, s.healthPath)
+		}
+	}
+
+	entry := buffer.LogEntry{
+		Timestamp:   time.Now(),
+		Level:       "INFO",
+		Message:     "LogChat agent connectivity check",
+		Service:     selfService,
+		Source:      selfSource,
+		Hostname:    s.hostname,
+		Environment: s.environment,
+		Metadata:    map[string]any{"test": true},
+	}
+
+	payload := s.newPayload([]buffer.LogEntry{entry})
+	payload.Test = true
+
+	url := s.urls[0]
+	body, err := s.post(ctx, url, payload)
+	if err != nil {
+		if se, ok := err.(*statusError); ok {
+			switch se.code {
+			case 401, 403:
+				return fmt.Errorf("server rejected the API key (HTTP %d): %s", se.code, se.body)
+			case 404:
+				return fmt.Errorf("ingest endpoint not found at %s%s (HTTP 404)", url, s.ingestPath)
+			case 400, 422:
+				return fmt.Errorf("server rejected the payload format (HTTP %d): %s", se.code, se.body)
+			}
+		}
+		return fmt.Errorf("test entry not accepted: %w", err)
+	}
+
+	fmt.Printf("  ✓ Test entry accepted by %s: %s\n", url, truncate(string(body), 200))
+	return nil
+}
@@ -0,0 +1,37 @@
+package sender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+// defaultCompressMinBytes is the payload size below which compression costs
+// more than it saves
+const defaultCompressMinBytes = 1024
+
+// gzipWriters reuses gzip writers across batches; each holds sizeable
+// compression state that would otherwise be allocated per request
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// gzipPayload compresses data with a pooled writer
+func gzipPayload(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(data) / 4)
+
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&out)
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/buffer"
+)
+
+// deadLetter stores entries the server refused, so they stop blocking the
+// buffer but can still be inspected and replayed. Each write is one JSON
+// file: a single entry isolated from a rejected batch, or a whole batch
+// given up on after its retries.
+type deadLetter struct {
+	mu  sync.Mutex
+	dir string
+	seq int64
+
+	// Fingerprints of entries isolated and written while their batch is
+	// still buffered, so a retry of the batch doesn't write them again
+	isolated map[string]bool
+}
+
+// deadLetterRecord is the file format of a dead-lettered entry
+type deadLetterRecord struct {
+	Time  time.Time         `json:"time"`
+	URL   string            `json:"url"`
+	Error string            `json:"error"`
+	Logs  []buffer.LogEntry `json:"logs"`
+}
+
+func newDeadLetter(dir string) (*deadLetter, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	return &deadLetter{dir: dir, isolated: make(map[string]bool)}, nil
+}
+
+// write saves entries with the error that rejected them
+func (d *deadLetter) write(url string, entries []buffer.LogEntry, cause error) (string, error) {
+	data, err := json.MarshalIndent(deadLetterRecord{
+		Time:  time.Now(),
+		URL:   url,
+		Error: cause.Error(),
+		Logs:  entries,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	d.seq++
+	// Named so max_disk_bytes can prune it
+	name := buffer.DeadLetterName(time.Now(), d.seq)
+	d.mu.Unlock()
+
+	path := filepath.Join(d.dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return path, nil
+}
+
+// remember records an isolated entry as written
+func (d *deadLetter) remember(entry buffer.LogEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.isolated[string(entry.Fingerprint())] = true
+}
+
+// skipIsolated returns entries without those already isolated and written
+func (d *deadLetter) skipIsolated(entries []buffer.LogEntry) []buffer.LogEntry {
+	if d == nil {
+		return entries
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.isolated) == 0 {
+		return entries
+	}
+
+	kept := make([]buffer.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !d.isolated[string(entry.Fingerprint())] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// forget drops isolated entries once their batch has left the buffer
+func (d *deadLetter) forget(entries []buffer.LogEntry) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.isolated) == 0 {
+		return
+	}
+	for _, entry := range entries {
+		delete(d.isolated, string(entry.Fingerprint()))
+	}
+}
+
+// rejected reports whether the server refused the payload itself, so the
+// same entries can never succeed: a 4xx other than auth failures, a missing
+// endpoint, timeouts and rate limiting, which are all worth retrying
+func rejected(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.code {
+	case 401, 403, 404, 408, 429:
+		return false
+	}
+	return se.code >= 400 && se.code < 500
+}
+
+// isolateRejected splits a rejected batch until the entries the server
+// refuses are found, sending the rest and dead-lettering those. It returns
+// how many entries were dead-lettered, or an error once a retriable failure
+// interrupts it; halves already sent are then resent with the retry, while
+// entries already dead-lettered are remembered and skipped by it.
+func (s *Sender) isolateRejected(ctx context.Context, batch []buffer.LogEntry, cause error) (int, error) {
+	if len(batch) == 1 {
+		path, err := s.deadLetter.write(s.activeURL(), batch, cause)
+		if err != nil {
+			return 0, err
+		}
+		s.deadLetter.remember(batch[0])
+		atomic.AddInt64(&s.deadLettered, 1)
+		s.logger().Warn("Entry rejected, moved to dead letters", "cause", cause, "path", path)
+		return 1, nil
+	}
+
+	dead := 0
+	half := len(batch) / 2
+	for _, part := range [][]buffer.LogEntry{batch[:half], batch[half:]} {
+		err := s.sendWithFailover(ctx, part)
+		if err == nil {
+			continue
+		}
+		if !rejected(err) {
+			return dead, err
+		}
+
+		n, err := s.isolateRejected(ctx, part, err)
+		dead += n
+		if err != nil {
+			return dead, err
+		}
+	}
+	return dead, nil
+}
@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+func TestIsolateRejectedInterruptedDoesNotDeadLetterTwice(t *testing.T) {
+	// "bad" is refused; the first batch holding "late" fails retriably
+	var lateFailed atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case bytes.Contains(body, []byte(`"bad"`)):
+			w.WriteHeader(http.StatusBadRequest)
+		case bytes.Contains(body, []byte(`"late`)) && !lateFailed.Swap(true):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	s := newTestSender(t, config.ServerConfig{URL: srv.URL, DeadLetterDir: dir})
+	batch := testEntries("bad", "ok", "late 1", "late 2")
+
+	// The first half is isolated, then the second half fails retriably
+	if err := s.sendBatch(context.Background(), batch); err == nil {
+		t.Fatal("first attempt succeeded, want a retriable error")
+	}
+	// The retry of the whole batch skips the entry already dead-lettered
+	if err := s.sendBatch(context.Background(), batch); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("dead letter files = %d, want 1", len(files))
+	}
+	for _, f := range files {
+		// max_disk_bytes can only free dead letters it recognizes
+		if ok, _ := filepath.Match(buffer.DeadLetterPattern, f.Name()); !ok {
+			t.Errorf("dead letter %s doesn't match %s", f.Name(), buffer.DeadLetterPattern)
+		}
+	}
+	if n := atomic.LoadInt64(&s.deadLettered); n != 1 {
+		t.Errorf("dead_lettered = %d, want 1", n)
+	}
+	if n := len(s.deadLetter.isolated); n != 0 {
+		t.Errorf("isolated entries still remembered = %d, want 0", n)
+	}
+}
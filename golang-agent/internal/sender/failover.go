@@ -0,0 +1,94 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"logchat/agent/internal/buffer"
+)
+
+// statusError is returned when the server answers with an error status
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.code, e.body)
+}
+
+// shouldFailover reports whether err means the endpoint is unreachable or
+// failing (network error or 5xx) rather than rejecting the payload
+func shouldFailover(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// activeURL returns the URL currently receiving logs
+func (s *Sender) activeURL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.urls[s.active]
+}
+
+// switchTo makes urls[index] the active URL
+func (s *Sender) switchTo(index int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index == s.active {
+		return
+	}
+	s.logger().Warn("Switching server", "from", s.urls[s.active], "to", s.urls[index], "reason", reason)
+	s.active = index
+	s.switchovers++
+}
+
+// sendWithFailover sends the batch to the active URL, trying the remaining
+// configured URLs in order when it is unreachable
+func (s *Sender) sendWithFailover(ctx context.Context, entries []buffer.LogEntry) error {
+	payload := s.newPayload(entries)
+
+	// Chain the batch into the ledger; it only advances once delivered
+	var rec LedgerRecord
+	if s.ledger != nil {
+		s.ledger.chainMu.Lock()
+		defer s.ledger.chainMu.Unlock()
+
+		var err error
+		if rec, err = s.ledger.prepare(entries); err != nil {
+			return err
+		}
+		if s.ledger.inPayload {
+			payload.Chain = &rec
+		}
+	}
+
+	s.mu.RLock()
+	start := s.active
+	s.mu.RUnlock()
+
+	var err error
+	for i := 0; i < len(s.urls); i++ {
+		index := (start + i) % len(s.urls)
+		if i > 0 {
+			s.switchTo(index, fmt.Sprintf("send failed: %v", err))
+		}
+
+		_, err = s.post(ctx, s.urls[index], payload)
+		if err == nil && s.ledger != nil {
+			if lerr := s.ledger.commit(rec); lerr != nil {
+				log.Warn("Ledger commit failed", "error", lerr)
+			}
+		}
+		if err == nil || !shouldFailover(err) {
+			return err
+		}
+	}
+
+	return err
+}
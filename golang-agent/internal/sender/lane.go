@@ -0,0 +1,33 @@
+package sender
+
+import (
+	"sort"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// lane is an independently batched delivery path with its own buffer.
+// Entries are routed to a lane by their delivery class.
+type lane struct {
+	name          string
+	buffer        buffer.Buffer
+	batchSize     int
+	flushInterval time.Duration
+
+	// Failed sends of the batch at the head of the buffer, and when the
+	// first of them happened
+	attempts     int
+	failingSince time.Time
+}
+
+// sortedClassNames returns class names in a stable order
+func sortedClassNames(classes map[string]config.DeliveryClassConfig) []string {
+	names := make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
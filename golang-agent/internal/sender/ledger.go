@@ -0,0 +1,164 @@
+package sender
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// genesisHash is the previous head of the first ledger record
+var genesisHash = strings.Repeat("0", 64)
+
+// LedgerRecord is one shipped batch in the hash chain. Head is
+// sha256(Prev + BatchHash), so altering or dropping a record breaks every
+// later head.
+type LedgerRecord struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Count     int       `json:"count"`
+	BatchHash string    `json:"batch_hash"`
+	Prev      string    `json:"prev"`
+	Head      string    `json:"head"`
+}
+
+// ledger is an append-only, hash-chained log of shipped batches. chainMu is
+// held from prepare to commit so concurrent lanes chain in send order; mu
+// only guards the head so stats never wait on a send.
+type ledger struct {
+	chainMu sync.Mutex
+	mu      sync.Mutex
+
+	path      string
+	maxSize   int64
+	inPayload bool
+
+	seq  int64
+	head string
+}
+
+func newLedger(cfg *config.LedgerConfig) (*ledger, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	l := &ledger{
+		path:      cfg.Path,
+		maxSize:   cfg.MaxSize,
+		inPayload: cfg.IncludeInPayload,
+		head:      genesisHash,
+	}
+	if l.maxSize <= 0 {
+		l.maxSize = 10 * 1024 * 1024
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load resumes the chain from the last record on disk
+func (l *ledger) load() error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	var last LedgerRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec LedgerRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+			last = rec
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	if last.Head != "" {
+		l.seq = last.Seq
+		l.head = last.Head
+	}
+	return nil
+}
+
+// prepare builds the record the batch would add to the chain
+func (l *ledger) prepare(entries []buffer.LogEntry) (LedgerRecord, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return LedgerRecord{}, fmt.Errorf("failed to hash batch: %w", err)
+	}
+	batchSum := sha256.Sum256(data)
+	batchHash := hex.EncodeToString(batchSum[:])
+	seq, head := l.state()
+	headSum := sha256.Sum256([]byte(head + batchHash))
+
+	return LedgerRecord{
+		Seq:       seq + 1,
+		Time:      time.Now().UTC(),
+		Count:     len(entries),
+		BatchHash: batchHash,
+		Prev:      head,
+		Head:      hex.EncodeToString(headSum[:]),
+	}, nil
+}
+
+// commit appends a delivered batch's record and advances the head. When the
+// file exceeds the size limit it is rotated to .1; the new file's first
+// record still references the old head.
+func (l *ledger) commit(rec LedgerRecord) error {
+	if info, err := os.Stat(l.path); err == nil && info.Size() >= l.maxSize {
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate ledger: %w", err)
+		}
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write ledger: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync ledger: %w", err)
+	}
+
+	l.mu.Lock()
+	l.seq = rec.Seq
+	l.head = rec.Head
+	l.mu.Unlock()
+	return nil
+}
+
+// state returns the current sequence number and chain head
+func (l *ledger) state() (int64, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq, l.head
+}
@@ -0,0 +1,165 @@
+package sender
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// Loki endpoints, used instead of the LogChat ones when format is loki
+const (
+	lokiPushPath  = "/loki/api/v1/push"
+	lokiReadyPath = "/ready"
+)
+
+// lokiEncoder turns batches into Loki push requests, grouping entries into
+// streams by their label values
+type lokiEncoder struct {
+	labels []string // Entry fields or tag names that form the label set
+	json   bool     // Lines are the whole entry as JSON rather than the message
+	tenant string
+}
+
+// lokiPush is the body of a Loki push request
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream holds the entries of one label set as [nanoseconds, line] pairs
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// newLokiEncoder creates the encoder for a Loki server, nil for other formats
+func newLokiEncoder(format string, cfg *config.LokiConfig) *lokiEncoder {
+	if format != "loki" {
+		return nil
+	}
+
+	le := &lokiEncoder{labels: []string{"service", "hostname", "level"}}
+	if cfg != nil {
+		if len(cfg.Labels) > 0 {
+			le.labels = cfg.Labels
+		}
+		le.json = cfg.LineFormat == "json"
+		le.tenant = cfg.TenantID
+	}
+	return le
+}
+
+// encode builds the push request for a batch, with each stream's values in
+// time order. A ledger record has no place in the Loki format and is dropped.
+func (le *lokiEncoder) encode(payload LogPayload) ([]byte, error) {
+	var streams []*lokiStream
+	index := make(map[string]*lokiStream)
+
+	for _, entry := range sortByTimestamp(payload.Logs) {
+		labels := le.streamLabels(entry, payload.Agent.Tags)
+		key := lokiStreamKey(labels)
+
+		stream, ok := index[key]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			index[key] = stream
+			streams = append(streams, stream)
+		}
+
+		line := entry.Message
+		if le.json {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return nil, err
+			}
+			line = string(data)
+		}
+		stream.Values = append(stream.Values, [2]string{
+			strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+			line,
+		})
+	}
+
+	push := lokiPush{Streams: make([]lokiStream, 0, len(streams))}
+	for _, stream := range streams {
+		push.Streams = append(push.Streams, *stream)
+	}
+	return json.Marshal(push)
+}
+
+// streamLabels picks the configured labels from an entry. Names other than
+// the entry's own fields are looked up in its tags, then the agent's tags;
+// labels without a value are left out.
+func (le *lokiEncoder) streamLabels(entry buffer.LogEntry, agentTags map[string]string) map[string]string {
+	labels := make(map[string]string, len(le.labels))
+	for _, name := range le.labels {
+		var value string
+		switch name {
+		case "service":
+			value = entry.Service
+		case "level":
+			value = entry.Level
+		case "hostname":
+			value = entry.Hostname
+		case "source":
+			value = entry.Source
+		case "environment":
+			value = entry.Environment
+		default:
+			if v, ok := entry.Tags[name]; ok {
+				value = v
+			} else {
+				value = agentTags[name]
+			}
+		}
+		if value != "" {
+			labels[lokiLabelName(name)] = value
+		}
+	}
+
+	// Loki rejects streams without labels
+	if len(labels) == 0 {
+		labels["service"] = selfService
+	}
+	return labels
+}
+
+// lokiStreamKey identifies a label set regardless of map order
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// lokiLabelName maps a name onto Loki's label charset [a-zA-Z_][a-zA-Z0-9_]*
+func lokiLabelName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
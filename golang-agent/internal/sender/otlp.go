@@ -0,0 +1,429 @@
+package sender
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/processor"
+)
+
+// OTLP/HTTP logs endpoint. Collectors have no health endpoint on the OTLP
+// port, but a live one answers a GET here with 405.
+const otlpLogsPath = "/v1/logs"
+
+// otlpEncoder turns batches into OTLP ExportLogsServiceRequests, as
+// protobuf or as OTLP's JSON mapping
+type otlpEncoder struct {
+	json bool
+}
+
+// newOTLPEncoder creates the encoder for an OTLP endpoint, nil for other formats
+func newOTLPEncoder(format string, cfg *config.OTLPConfig) *otlpEncoder {
+	if format != "otlp" {
+		return nil
+	}
+	return &otlpEncoder{json: cfg != nil && cfg.Encoding == "json"}
+}
+
+// contentType is the request content type for the encoding
+func (oe *otlpEncoder) contentType() string {
+	if oe.json {
+		return "application/json"
+	}
+	return "application/x-protobuf"
+}
+
+// The OTLP logs data model, with the field names of its JSON mapping.
+// 64-bit integers are strings in JSON and IDs are hex.
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         uint64         `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64         `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int            `json:"severityNumber,omitempty"`
+	SeverityText         string         `json:"severityText,omitempty"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue holds exactly one of its fields
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *int64          `json:"intValue,omitempty,string"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlist     `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvlist struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// encode builds the export request for a batch. Entries are grouped into
+// one resource per service, host and environment, which OTLP models as
+// resource attributes rather than per-record ones.
+func (oe *otlpEncoder) encode(payload LogPayload) ([]byte, error) {
+	var req otlpRequest
+	index := make(map[string]int)
+	observed := uint64(time.Now().UnixNano())
+
+	for _, entry := range payload.Logs {
+		key := entry.Service + "\x00" + entry.Hostname + "\x00" + entry.Environment
+		i, ok := index[key]
+		if !ok {
+			i = len(req.ResourceLogs)
+			index[key] = i
+			req.ResourceLogs = append(req.ResourceLogs, otlpResourceLogs{
+				Resource: otlpResource{Attributes: otlpResourceAttributes(entry, payload.Agent)},
+				ScopeLogs: []otlpScopeLogs{{
+					Scope: otlpScope{Name: selfService, Version: payload.Agent.Version},
+				}},
+			})
+		}
+
+		scope := &req.ResourceLogs[i].ScopeLogs[0]
+		scope.LogRecords = append(scope.LogRecords, otlpRecord(entry, observed))
+	}
+
+	if oe.json {
+		return json.Marshal(req)
+	}
+	return req.marshalProto(), nil
+}
+
+// otlpResourceAttributes describes where an entry came from using the
+// OpenTelemetry semantic conventions, plus the agent's tags
+func otlpResourceAttributes(entry buffer.LogEntry, agent AgentInfo) []otlpKeyValue {
+	var attrs []otlpKeyValue
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, otlpKeyValue{Key: key, Value: otlpString(value)})
+		}
+	}
+
+	add("service.name", entry.Service)
+	add("host.name", entry.Hostname)
+	add("deployment.environment", entry.Environment)
+	add("logchat.agent.version", agent.Version)
+	for _, key := range sortedKeys(agent.Tags) {
+		add(key, agent.Tags[key])
+	}
+	return attrs
+}
+
+// otlpRecord maps an entry onto a log record. Tags and metadata become
+// attributes; trace_id and span_id metadata fill the record's trace context
+// when they are valid hex IDs.
+func otlpRecord(entry buffer.LogEntry, observed uint64) otlpLogRecord {
+	record := otlpLogRecord{
+		ObservedTimeUnixNano: observed,
+		SeverityNumber:       otlpSeverity(entry.Level),
+		SeverityText:         entry.Level,
+		Body:                 otlpString(entry.Message),
+	}
+	if !entry.Timestamp.IsZero() {
+		record.TimeUnixNano = uint64(entry.Timestamp.UnixNano())
+	}
+
+	if entry.Source != "" {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: "log.source", Value: otlpString(entry.Source)})
+	}
+	for _, key := range sortedKeys(entry.Tags) {
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: key, Value: otlpString(entry.Tags[key])})
+	}
+
+	keys := make([]string, 0, len(entry.Metadata))
+	for key := range entry.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := entry.Metadata[key]
+		switch key {
+		case "trace_id":
+			if id, ok := otlpID(value, 16); ok {
+				record.TraceID = id
+				continue
+			}
+		case "span_id":
+			if id, ok := otlpID(value, 8); ok {
+				record.SpanID = id
+				continue
+			}
+		}
+		record.Attributes = append(record.Attributes, otlpKeyValue{Key: key, Value: otlpValue(value)})
+	}
+
+	return record
+}
+
+// otlpSeverity maps a level onto the OTLP SeverityNumber at the start of
+// its range: TRACE 1, DEBUG 5, INFO 9, WARN 13, ERROR 17, FATAL 21. Unknown
+// levels are left unspecified (0).
+func otlpSeverity(level string) int {
+	if strings.EqualFold(strings.TrimSpace(level), "TRACE") {
+		return 1
+	}
+
+	canonical, _ := processor.CanonicalLevel(level)
+	switch canonical {
+	case "DEBUG":
+		return 5
+	case "INFO":
+		return 9
+	case "WARN":
+		return 13
+	case "ERROR":
+		return 17
+	case "FATAL":
+		return 21
+	}
+	return 0
+}
+
+// otlpID returns value as lower-case hex if it's an ID of size bytes
+func otlpID(value any, size int) (string, bool) {
+	s, ok := value.(string)
+	if !ok || len(s) != size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts a decoded metadata value into an AnyValue
+func otlpValue(value any) otlpAnyValue {
+	switch v := value.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		n := int64(v)
+		return otlpAnyValue{IntValue: &n}
+	case int64:
+		return otlpAnyValue{IntValue: &v}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			n := int64(v)
+			return otlpAnyValue{IntValue: &n}
+		}
+		return otlpAnyValue{DoubleValue: &v}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return otlpAnyValue{IntValue: &n}
+		}
+		if f, err := v.Float64(); err == nil {
+			return otlpAnyValue{DoubleValue: &f}
+		}
+		return otlpString(v.String())
+	case []any:
+		array := &otlpArrayValue{Values: make([]otlpAnyValue, 0, len(v))}
+		for _, item := range v {
+			array.Values = append(array.Values, otlpValue(item))
+		}
+		return otlpAnyValue{ArrayValue: array}
+	case map[string]any:
+		list := &otlpKvlist{}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			list.Values = append(list.Values, otlpKeyValue{Key: key, Value: otlpValue(v[key])})
+		}
+		return otlpAnyValue{KvlistValue: list}
+	case nil:
+		return otlpAnyValue{}
+	}
+	return otlpString(fmt.Sprint(value))
+}
+
+// sortedKeys returns a string map's keys in order, for stable output
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Protobuf encoding of the model, following opentelemetry-proto's
+// collector/logs/v1 field numbers. Written by hand to avoid pulling in a
+// protobuf runtime for one message type.
+
+// protoWriter appends protobuf wire-format fields
+type protoWriter []byte
+
+func (w *protoWriter) tag(field, wireType int) {
+	*w = binary.AppendUvarint(*w, uint64(field<<3|wireType))
+}
+
+func (w *protoWriter) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, 0)
+	*w = binary.AppendUvarint(*w, v)
+}
+
+func (w *protoWriter) fixed64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	w.tag(field, 1)
+	*w = binary.LittleEndian.AppendUint64(*w, v)
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	w.tag(field, 2)
+	*w = binary.AppendUvarint(*w, uint64(len(b)))
+	*w = append(*w, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	w.bytes(field, []byte(s))
+}
+
+// message appends an embedded message built by fill
+func (w *protoWriter) message(field int, fill func(*protoWriter)) {
+	var sub protoWriter
+	fill(&sub)
+	w.bytes(field, sub)
+}
+
+func (r otlpRequest) marshalProto() []byte {
+	var w protoWriter
+	for _, rl := range r.ResourceLogs {
+		w.message(1, rl.marshalProto)
+	}
+	return w
+}
+
+func (rl otlpResourceLogs) marshalProto(w *protoWriter) {
+	w.message(1, func(w *protoWriter) {
+		for _, kv := range rl.Resource.Attributes {
+			w.message(1, kv.marshalProto)
+		}
+	})
+	for _, sl := range rl.ScopeLogs {
+		w.message(2, sl.marshalProto)
+	}
+}
+
+func (sl otlpScopeLogs) marshalProto(w *protoWriter) {
+	w.message(1, func(w *protoWriter) {
+		w.string(1, sl.Scope.Name)
+		w.string(2, sl.Scope.Version)
+	})
+	for _, lr := range sl.LogRecords {
+		w.message(2, lr.marshalProto)
+	}
+}
+
+func (lr otlpLogRecord) marshalProto(w *protoWriter) {
+	w.fixed64(1, lr.TimeUnixNano)
+	w.varint(2, uint64(lr.SeverityNumber))
+	w.string(3, lr.SeverityText)
+	w.message(5, lr.Body.marshalProto)
+	for _, kv := range lr.Attributes {
+		w.message(6, kv.marshalProto)
+	}
+	if id, err := hex.DecodeString(lr.TraceID); err == nil && len(id) > 0 {
+		w.bytes(9, id)
+	}
+	if id, err := hex.DecodeString(lr.SpanID); err == nil && len(id) > 0 {
+		w.bytes(10, id)
+	}
+	w.fixed64(11, lr.ObservedTimeUnixNano)
+}
+
+func (kv otlpKeyValue) marshalProto(w *protoWriter) {
+	w.string(1, kv.Key)
+	w.message(2, kv.Value.marshalProto)
+}
+
+func (v otlpAnyValue) marshalProto(w *protoWriter) {
+	switch {
+	case v.StringValue != nil:
+		// Written even when empty: the oneof must record which value is set
+		w.bytes(1, []byte(*v.StringValue))
+	case v.BoolValue != nil:
+		w.tag(2, 0)
+		if *v.BoolValue {
+			*w = append(*w, 1)
+		} else {
+			*w = append(*w, 0)
+		}
+	case v.IntValue != nil:
+		w.tag(3, 0)
+		*w = binary.AppendUvarint(*w, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		w.tag(4, 1)
+		*w = binary.LittleEndian.AppendUint64(*w, math.Float64bits(*v.DoubleValue))
+	case v.ArrayValue != nil:
+		w.message(5, func(w *protoWriter) {
+			for _, item := range v.ArrayValue.Values {
+				w.message(1, item.marshalProto)
+			}
+		})
+	case v.KvlistValue != nil:
+		w.message(6, func(w *protoWriter) {
+			for _, kv := range v.KvlistValue.Values {
+				w.message(1, kv.marshalProto)
+			}
+		})
+	}
+}
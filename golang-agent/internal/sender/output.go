@@ -0,0 +1,109 @@
+package sender
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// newOutputs creates a sender per additional server. Each gets its own
+// buffer and retry state, so a failing output only backs up its own queue.
+func newOutputs(serverCfg config.ServerConfig, agentCfg config.AgentConfig) ([]*Sender, error) {
+	var outputs []*Sender
+
+	for i, o := range serverCfg.AdditionalServers {
+		name := o.Name
+		if name == "" {
+			name = o.URL
+		}
+
+		// Batching, timeouts and connection handling follow the primary;
+		// delivery tracking and classes belong to it alone
+		cfg := serverCfg
+		cfg.URL = o.URL
+		cfg.FallbackURLs = nil
+		cfg.Format = o.Format
+		cfg.Loki = o.Loki
+		cfg.OTLP = o.OTLP
+		cfg.APIKey = o.APIKey
+		cfg.SigningKey = o.SigningKey
+		cfg.Insecure = o.Insecure
+		cfg.CAFile = o.CAFile
+		cfg.CertFile = o.CertFile
+		cfg.KeyFile = o.KeyFile
+		cfg.IngestPath = o.IngestPath
+		cfg.HealthPath = o.HealthPath
+		cfg.BasicAuthUser = o.BasicAuthUser
+		cfg.BasicAuthPassword = o.BasicAuthPassword
+		cfg.Headers = o.Headers
+		cfg.Classes = nil
+		cfg.Dedup = nil
+		cfg.Ledger = nil
+		cfg.AdditionalServers = nil
+		if cfg.DeadLetterDir != "" {
+			cfg.DeadLetterDir = filepath.Join(cfg.DeadLetterDir, fmt.Sprintf("output-%d", i))
+		}
+
+		bufCfg := config.BufferConfig{
+			Type:     "memory",
+			MaxItems: 10000,
+			MaxSize:  100 * 1024 * 1024,
+		}
+		if o.Buffer != nil {
+			bufCfg = *o.Buffer
+		}
+
+		buf, err := buffer.New(bufCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create buffer for additional_servers[%d]: %w", i, err)
+		}
+
+		// Entries arrive already enriched and processed by the primary
+		out, err := New(cfg, config.AgentConfig{
+			Hostname:    agentCfg.Hostname,
+			Environment: agentCfg.Environment,
+		}, buf)
+		if err != nil {
+			buf.Close()
+			return nil, fmt.Errorf("additional_servers[%d]: %w", i, err)
+		}
+		out.name = "output:" + name
+
+		outputs = append(outputs, out)
+	}
+
+	return outputs, nil
+}
+
+// fanOut queues a copy of a processed entry for every output
+func (s *Sender) fanOut(entry buffer.LogEntry) {
+	for _, o := range s.outputs {
+		if err := o.lanes[0].buffer.Push(entry); err != nil {
+			o.mu.Lock()
+			o.errorCount++
+			o.lastError = err.Error()
+			o.mu.Unlock()
+		}
+	}
+}
+
+// outputStats summarizes an output's delivery state
+func (s *Sender) outputStats() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return map[string]any{
+		"url":                  s.serverURL,
+		"sent_count":           s.sentCount,
+		"error_count":          s.errorCount,
+		"last_sent":            s.lastSent,
+		"last_error":           s.lastError,
+		"server_alive":         s.serverAlive,
+		"buffer_length":        s.bufferLen(),
+		"consecutive_failures": s.consecutiveFailures,
+		"dead_lettered":        atomic.LoadInt64(&s.deadLettered),
+	}
+}
@@ -0,0 +1,157 @@
+package sender
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// loadTestConfig loads a config file holding yaml
+func loadTestConfig(t *testing.T, yaml string) *config.Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// newConfiguredSender creates an unstarted sender from a loaded config with
+// its main buffer, closing both when the test ends
+func newConfiguredSender(t *testing.T, cfg *config.Config) *Sender {
+	t.Helper()
+
+	buf, err := buffer.New(cfg.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg.Server, cfg.Agent, buf)
+	if err != nil {
+		buf.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		buf.Close()
+	})
+	return s
+}
+
+func TestOutputBufferDefaults(t *testing.T) {
+	dir := t.TempDir()
+	cfg := loadTestConfig(t, fmt.Sprintf(`
+server:
+  url: "http://127.0.0.1:0"
+  additional_servers:
+    - url: "http://127.0.0.1:0"
+      buffer: {type: memory}
+    - url: "http://127.0.0.1:0"
+      buffer: {type: file}
+buffer:
+  type: file
+  path: %q
+  max_items: 100
+`, dir))
+
+	for i, o := range cfg.Server.AdditionalServers {
+		if o.Buffer.MaxItems != 100 || o.Buffer.MaxSize != cfg.Buffer.MaxSize {
+			t.Errorf("output %d limits = %d items, %d bytes, want the main buffer's", i, o.Buffer.MaxItems, o.Buffer.MaxSize)
+		}
+		if want := filepath.Join(dir, fmt.Sprintf("output-%d", i)); o.Buffer.Path != want {
+			t.Errorf("output %d path = %q, want %q", i, o.Buffer.Path, want)
+		}
+	}
+
+	s := newConfiguredSender(t, cfg)
+	for i := 0; i < 5; i++ {
+		if err := s.Send(testEntries(fmt.Sprintf("entry %d", i))[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := s.lanes[0].buffer.Len(); n != 5 {
+		t.Errorf("main buffer holds %d entries, want 5", n)
+	}
+	for i, o := range s.outputs {
+		if n := o.lanes[0].buffer.Len(); n != 5 {
+			t.Errorf("output %d buffer holds %d entries, want 5", i, n)
+		}
+	}
+}
+
+func TestFanOutTracksEachOutput(t *testing.T) {
+	server := func(status int) (*httptest.Server, *atomic.Int64) {
+		var received atomic.Int64
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			received.Add(1)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &received
+	}
+	primary, primaryGot := server(http.StatusOK)
+	archive, archiveGot := server(http.StatusOK)
+	broken, _ := server(http.StatusServiceUnavailable)
+
+	cfg := loadTestConfig(t, fmt.Sprintf(`
+server:
+  url: %q
+  additional_servers:
+    - name: archive
+      url: %q
+    - name: broken
+      url: %q
+`, primary.URL, archive.URL, broken.URL))
+	s := newConfiguredSender(t, cfg)
+
+	for i := 0; i < 3; i++ {
+		if err := s.Send(testEntries(fmt.Sprintf("entry %d", i))[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	s.flush(ctx, s.lanes[0])
+	for _, o := range s.outputs {
+		o.flush(ctx, o.lanes[0])
+	}
+
+	if primaryGot.Load() != 1 || archiveGot.Load() != 1 {
+		t.Errorf("batches received: primary %d, archive %d, want 1 each", primaryGot.Load(), archiveGot.Load())
+	}
+
+	outputs := s.Stats()["outputs"].(map[string]any)
+	archiveStats := outputs["archive"].(map[string]any)
+	brokenStats := outputs["broken"].(map[string]any)
+
+	if archiveStats["sent_count"] != int64(3) || archiveStats["buffer_length"] != 0 {
+		t.Errorf("archive stats = %v, want 3 sent and an empty buffer", archiveStats)
+	}
+	// The failing output keeps its copies without holding up the others
+	if brokenStats["sent_count"] != int64(0) || brokenStats["buffer_length"] != 3 {
+		t.Errorf("broken stats = %v, want nothing sent and 3 buffered", brokenStats)
+	}
+	if brokenStats["consecutive_failures"] != 1 || brokenStats["last_error"] == "" {
+		t.Errorf("broken stats = %v, want one recorded failure", brokenStats)
+	}
+	if n := s.lanes[0].buffer.Len(); n != 0 {
+		t.Errorf("primary buffer holds %d entries after its flush", n)
+	}
+}
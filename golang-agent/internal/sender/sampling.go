@@ -0,0 +1,100 @@
+package sender
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/processor"
+)
+
+// sampleBuckets is the resolution of the sampler: percentages are honored
+// down to 0.01%
+const sampleBuckets = 10000
+
+// sampler keeps a share of entries per level. The decision hashes the
+// service and message, so a given line is always kept or always dropped,
+// across restarts and agents alike, instead of randomly thinning a stream.
+type sampler struct {
+	percent float64            // Share kept for levels without their own
+	levels  map[string]float64 // Canonical level -> share kept
+
+	seen    int64 // atomic
+	dropped int64 // atomic
+}
+
+func newSampler(cfg *config.SamplingConfig) *sampler {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	s := &sampler{percent: 100}
+	switch {
+	case cfg.OneIn > 0:
+		s.percent = 100 / float64(cfg.OneIn)
+	case cfg.Percent > 0:
+		s.percent = cfg.Percent
+	}
+
+	levels := cfg.Levels
+	if levels == nil {
+		levels = map[string]float64{"ERROR": 100, "FATAL": 100}
+	}
+	s.levels = make(map[string]float64, len(levels))
+	for level, percent := range levels {
+		if canonical, ok := processor.CanonicalLevel(level); ok {
+			level = canonical
+		}
+		s.levels[strings.ToUpper(level)] = percent
+	}
+	return s
+}
+
+// keep reports whether the entry survives sampling
+func (s *sampler) keep(entry buffer.LogEntry) bool {
+	atomic.AddInt64(&s.seen, 1)
+
+	percent := s.percent
+	level, ok := processor.CanonicalLevel(entry.Level)
+	if !ok {
+		level = strings.ToUpper(entry.Level)
+	}
+	if p, ok := s.levels[level]; ok {
+		percent = p
+	}
+	if percent >= 100 {
+		return true
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(entry.Service))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Message))
+	if float64(h.Sum64()%sampleBuckets) < percent*sampleBuckets/100 {
+		return true
+	}
+
+	atomic.AddInt64(&s.dropped, 1)
+	return false
+}
+
+// stats returns the sampler counters and the share of entries actually kept
+func (s *sampler) stats() map[string]any {
+	seen := atomic.LoadInt64(&s.seen)
+	dropped := atomic.LoadInt64(&s.dropped)
+
+	effective := 100.0
+	if seen > 0 {
+		effective = float64(seen-dropped) / float64(seen) * 100
+	}
+
+	return map[string]any{
+		"percent":           s.percent,
+		"seen":              seen,
+		"dropped":           dropped,
+		"effective_percent": math.Round(effective*100) / 100,
+	}
+}
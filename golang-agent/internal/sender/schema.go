@@ -0,0 +1,148 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// fieldStats aggregates observed sizes of one metadata field
+type fieldStats struct {
+	count    int64
+	total    int64
+	maxBytes int
+}
+
+// schemaReport samples entries and aggregates metadata keys per service so
+// new or oversized fields can be spotted before they surprise the server
+type schemaReport struct {
+	mu sync.Mutex
+
+	interval    time.Duration
+	sampleEvery int64
+	seen        int64
+
+	services map[string]map[string]*fieldStats
+	known    map[string]map[string]bool // Fields reported in earlier windows
+}
+
+func newSchemaReport(cfg *config.SchemaReportConfig) *schemaReport {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = time.Hour
+	}
+	sampleEvery := int64(cfg.SampleEvery)
+	if sampleEvery <= 0 {
+		sampleEvery = 10
+	}
+
+	return &schemaReport{
+		interval:    interval,
+		sampleEvery: sampleEvery,
+		services:    make(map[string]map[string]*fieldStats),
+		known:       make(map[string]map[string]bool),
+	}
+}
+
+// observe records the metadata fields of every Nth entry
+func (r *schemaReport) observe(entry buffer.LogEntry) {
+	if len(entry.Metadata) == 0 || entry.Source == selfSource {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen++
+	if r.seen%r.sampleEvery != 0 {
+		return
+	}
+
+	fields, ok := r.services[entry.Service]
+	if !ok {
+		fields = make(map[string]*fieldStats)
+		r.services[entry.Service] = fields
+	}
+
+	for key, value := range entry.Metadata {
+		data, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+
+		fs, ok := fields[key]
+		if !ok {
+			fs = &fieldStats{}
+			fields[key] = fs
+		}
+		fs.count++
+		fs.total += int64(len(data))
+		fs.maxBytes = max(fs.maxBytes, len(data))
+	}
+}
+
+// report builds a self-telemetry entry for the current window and resets it
+func (r *schemaReport) report() (buffer.LogEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.services) == 0 {
+		return buffer.LogEntry{}, false
+	}
+
+	services := make(map[string]any, len(r.services))
+	var newFields []string
+	fieldCount := 0
+
+	for service, fields := range r.services {
+		known, ok := r.known[service]
+		if !ok {
+			known = make(map[string]bool)
+			r.known[service] = known
+		}
+
+		summary := make(map[string]any, len(fields))
+		for key, fs := range fields {
+			summary[key] = map[string]any{
+				"samples":   fs.count,
+				"avg_bytes": fs.total / fs.count,
+				"max_bytes": fs.maxBytes,
+			}
+			if !known[key] {
+				known[key] = true
+				newFields = append(newFields, service+"."+key)
+			}
+			fieldCount++
+		}
+		services[service] = summary
+	}
+	sort.Strings(newFields)
+
+	entry := buffer.LogEntry{
+		Timestamp: time.Now(),
+		Level:     "INFO",
+		Message: fmt.Sprintf("Metadata field report: %d services, %d fields, %d new",
+			len(r.services), fieldCount, len(newFields)),
+		Service: selfService,
+		Source:  selfSource,
+		Tags:    map[string]string{"report": "schema"},
+		Metadata: map[string]any{
+			"services":     services,
+			"new_fields":   newFields,
+			"window":       r.interval.String(),
+			"sample_every": r.sampleEvery,
+		},
+	}
+
+	r.services = make(map[string]map[string]*fieldStats)
+	return entry, true
+}
@@ -3,39 +3,72 @@ package sender
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"logchat/agent/internal/bloom"
 	"logchat/agent/internal/buffer"
 	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+	"logchat/agent/internal/processor"
+	"logchat/agent/internal/ratelimit"
 )
 
-// Verbose logging flag
-var Verbose = false
+// Build information reported with every batch, set by SetBuildInfo
+var (
+	agentVersion = "dev"
+	agentCommit  = ""
+)
 
-func init() {
-	if os.Getenv("LOGCHAT_VERBOSE") == "1" || os.Getenv("LOGCHAT_DEBUG") == "1" {
-		Verbose = true
-	}
+// SetBuildInfo records the agent version and git commit stamped at build
+// time, so the server can inventory the fleet by version
+func SetBuildInfo(version, commit string) {
+	agentVersion = version
+	agentCommit = commit
 }
 
+// log is the logger for messages not tied to one sender or output
+var log = logging.For("sender")
+
+// logVerbose logs a debug message, formatted only when debug is on
 func logVerbose(format string, args ...interface{}) {
-	if Verbose {
-		fmt.Printf("[sender] "+format+"\n", args...)
+	if log.Enabled(context.Background(), slog.LevelDebug) {
+		log.Debug(fmt.Sprintf(format, args...))
 	}
 }
 
+// logger returns the logger of this sender, or output for additional servers
+func (s *Sender) logger() *slog.Logger {
+	return logging.For(s.name)
+}
+
+// Service and source used for entries the agent emits about itself
+const (
+	selfService = "logchat-agent"
+	selfSource  = "logchat-agent"
+)
+
+// defaultHealthInterval is how often server health is probed when unset
+const defaultHealthInterval = 30 * time.Second
+
 // AgentInfo represents agent metadata
 type AgentInfo struct {
 	Hostname    string            `json:"hostname"`
 	Environment string            `json:"environment,omitempty"`
 	Version     string            `json:"version,omitempty"`
+	GitCommit   string            `json:"git_commit,omitempty"`
 	Tags        map[string]string `json:"tags,omitempty"`
 }
 
@@ -43,12 +76,17 @@ type AgentInfo struct {
 type LogPayload struct {
 	Agent AgentInfo         `json:"agent"`
 	Logs  []buffer.LogEntry `json:"logs"`
+	Test  bool              `json:"test,omitempty"` // Asks the server not to store the logs
+
+	Chain *LedgerRecord `json:"chain,omitempty"` // Ledger record for this batch, when enabled
 }
 
 // Sender handles sending logs to the LogChat server
 type Sender struct {
 	mu sync.RWMutex
 
+	name string // Log prefix: "sender", or "output:<name>" for additional servers
+
 	serverURL     string
 	apiKey        string
 	timeout       time.Duration
@@ -56,33 +94,114 @@ type Sender struct {
 	flushInterval time.Duration
 	insecure      bool
 
+	// Primary URL followed by fallbacks; active indexes the one in use
+	urls        []string
+	active      int
+	urlAlive    []bool
+	switchovers int64
+
+	// Delivery lanes: the default lane plus one per configured class
+	lanes   []*lane
+	classes map[string]*lane
+
+	// Additional servers fed a copy of every entry
+	outputs []*Sender
+
 	hostname    string
 	environment string
 	tags        map[string]string
 
-	buffer buffer.Buffer
-	client *http.Client
+	client    *http.Client
+	transport *http.Transport
+	dedup     *bloom.Filter // Fingerprints of delivered entries, nil when disabled
+	ledger    *ledger       // Hash chain of shipped batches, nil when disabled
+
+	// Entries the server refused as invalid, nil when disabled
+	deadLetter   *deadLetter
+	deadLettered int64 // atomic
+
+	processors processor.Chain
+	minLevel   string        // Entries below this level are dropped
+	filtered   int64         // atomic
+	schema     *schemaReport // nil when disabled
+	throttle   *cpuThrottle  // nil when disabled
+
+	limiter *ratelimit.Limiter // Agent-wide rate limit, nil when unset
+	sampler *sampler           // nil when disabled
+
+	// inFlight bounds concurrent ingest requests; nil means unlimited
+	inFlight      chan struct{}
+	inFlightCount int64
+
+	sortByTime bool
+
+	// Batches posted in parallel per flush round
+	sendConcurrency int
+
+	// Batches are also cut at this many encoded entry bytes, 0 = no limit
+	maxBatchBytes int
+
+	// Payload format and the endpoints that go with it
+	loki       *lokiEncoder // Nil unless pushing to Loki
+	otlp       *otlpEncoder // Nil unless exporting OTLP
+	ingestPath string
+	healthPath string
+
+	// How often the server's health is probed, 0 = never
+	healthInterval time.Duration
+
+	// HMAC key signing each ingest request, nil when disabled
+	signingKey []byte
+
+	// Basic auth replacing the bearer token, and headers for every request
+	basicAuthUser string
+	basicAuthPass string
+	headers       map[string]string
+
+	// Gzip request bodies of at least compressMin bytes
+	compress    bool
+	compressMin int
+
+	// Accepted health responses
+	healthCodes     []int
+	healthBodyMatch string
+
+	// Connection refresh and reuse tracking
+	connMaxAge    time.Duration
+	connReused    int64
+	connNew       int64
+	connRefreshes int64
+
+	// Backoff after consecutive send failures
+	backoffBase         time.Duration
+	maxBackoff          time.Duration
+	consecutiveFailures int
+	retryAt             time.Time
+	breaker             *breaker // Skips flushes while the server is down, nil when disabled
+
+	// Retries of a failing batch before it is dead-lettered or dropped,
+	// 0 = no limit
+	maxRetries int
+	giveUp     string // "dead_letter" or "drop"
+	givenUp    int64  // Entries given up on
 
 	// Metrics
 	sentCount   int64
+	batchesSent int64
+	bytesSent   int64 // atomic; uncompressed payload bytes
 	errorCount  int64
 	lastSent    time.Time
 	lastError   string
 	serverAlive bool
+	dupSkipped  int64
 }
 
 // New creates a new sender
 func New(serverCfg config.ServerConfig, agentCfg config.AgentConfig, buf buffer.Buffer) (*Sender, error) {
 	// Create HTTP client
-	transport := &http.Transport{
-		MaxIdleConns:        10,
-		IdleConnTimeout:     30 * time.Second,
-		DisableCompression:  false,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
-
-	if serverCfg.Insecure {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport, err := newTransport(serverCfg)
+	if err != nil {
+		return nil, err
 	}
 
 	client := &http.Client{
@@ -90,55 +209,343 @@ func New(serverCfg config.ServerConfig, agentCfg config.AgentConfig, buf buffer.
 		Timeout:   serverCfg.Timeout,
 	}
 
-	return &Sender{
-		serverURL:     serverCfg.URL,
-		apiKey:        serverCfg.APIKey,
-		timeout:       serverCfg.Timeout,
+	var dedup *bloom.Filter
+	if d := serverCfg.Dedup; d != nil && d.Enabled {
+		f, err := bloom.New(d.Path, d.Capacity, d.FalsePositiveRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dedup filter: %w", err)
+		}
+		dedup = f
+	}
+
+	ledger, err := newLedger(serverCfg.Ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+
+	processors, err := processor.New(agentCfg.Processors)
+	if err != nil {
+		return nil, err
+	}
+
+	// The empty-message policy runs first so every collector is treated alike
+	emptyMessage, err := processor.NewEmptyMessage(agentCfg.EmptyMessage)
+	if err != nil {
+		return nil, err
+	}
+	processors = append(processor.Chain{emptyMessage}, processors...)
+
+	// Redaction runs last so entries produced by other processors are
+	// masked too
+	redact, err := processor.NewRedact(agentCfg.Redact)
+	if err != nil {
+		return nil, err
+	}
+	if redact != nil {
+		processors = append(processors, redact)
+	}
+
+	var inFlight chan struct{}
+	if serverCfg.MaxInFlight > 0 {
+		inFlight = make(chan struct{}, serverCfg.MaxInFlight)
+	}
+
+	defaultLane := &lane{
+		name:          "default",
+		buffer:        buf,
 		batchSize:     serverCfg.BatchSize,
 		flushInterval: serverCfg.FlushInterval,
-		insecure:      serverCfg.Insecure,
-		hostname:      agentCfg.Hostname,
-		environment:   agentCfg.Environment,
-		tags:          agentCfg.Tags,
-		buffer:        buf,
-		client:        client,
-		serverAlive:   true,
+	}
+	lanes := []*lane{defaultLane}
+	classes := make(map[string]*lane)
+
+	for _, name := range sortedClassNames(serverCfg.Classes) {
+		class := serverCfg.Classes[name]
+		bufCfg := config.BufferConfig{
+			Type:     "memory",
+			MaxItems: 10000,
+			MaxSize:  100 * 1024 * 1024,
+		}
+		if class.Buffer != nil {
+			bufCfg = *class.Buffer
+		}
+
+		classBuf, err := buffer.New(bufCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create buffer for class %s: %w", name, err)
+		}
+
+		l := &lane{
+			name:          name,
+			buffer:        classBuf,
+			batchSize:     class.BatchSize,
+			flushInterval: class.FlushInterval,
+		}
+		lanes = append(lanes, l)
+		classes[name] = l
+	}
+
+	sendConcurrency := serverCfg.SendConcurrency
+	if sendConcurrency <= 0 {
+		sendConcurrency = 1
+	}
+
+	compressMin := serverCfg.CompressMinBytes
+	if compressMin <= 0 {
+		compressMin = defaultCompressMinBytes
+	}
+
+	backoffBase := serverCfg.BackoffBase
+	if backoffBase <= 0 {
+		backoffBase = defaultBackoffBase
+	}
+	maxBackoff := serverCfg.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	var healthCodes []int
+	var healthBodyMatch string
+	if serverCfg.Health != nil {
+		healthCodes = serverCfg.Health.StatusCodes
+		healthBodyMatch = serverCfg.Health.BodyMatch
+	}
+
+	deadLetter, err := newDeadLetter(serverCfg.DeadLetterDir)
+	if err != nil {
+		return nil, err
+	}
+	giveUp := serverCfg.GiveUp
+	if giveUp == "" {
+		giveUp = "drop"
+		if deadLetter != nil {
+			giveUp = "dead_letter"
+		}
+	}
+
+	outputs, err := newOutputs(serverCfg, agentCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var signingKey []byte
+	if serverCfg.SigningKey != "" {
+		signingKey = []byte(serverCfg.SigningKey)
+	}
+
+	loki := newLokiEncoder(serverCfg.Format, serverCfg.Loki)
+	otlp := newOTLPEncoder(serverCfg.Format, serverCfg.OTLP)
+	ingestPath, healthPath := "/api/logs/ingest", "/api/health"
+	switch {
+	case loki != nil:
+		ingestPath, healthPath = lokiPushPath, lokiReadyPath
+	case otlp != nil:
+		ingestPath, healthPath = otlpLogsPath, otlpLogsPath
+		if len(healthCodes) == 0 {
+			healthCodes = []int{200, 405}
+		}
+	}
+	if serverCfg.IngestPath != "" {
+		ingestPath = serverCfg.IngestPath
+	}
+	if serverCfg.HealthPath != "" {
+		healthPath = serverCfg.HealthPath
+	}
+
+	healthInterval := defaultHealthInterval
+	if serverCfg.HealthInterval != nil {
+		healthInterval = *serverCfg.HealthInterval
+	}
+
+	return &Sender{
+		name:            "sender",
+		serverURL:       serverCfg.URL,
+		urls:            append([]string{serverCfg.URL}, serverCfg.FallbackURLs...),
+		urlAlive:        make([]bool, 1+len(serverCfg.FallbackURLs)),
+		apiKey:          serverCfg.APIKey,
+		timeout:         serverCfg.Timeout,
+		batchSize:       serverCfg.BatchSize,
+		flushInterval:   serverCfg.FlushInterval,
+		insecure:        serverCfg.Insecure,
+		hostname:        agentCfg.Hostname,
+		environment:     agentCfg.Environment,
+		tags:            agentCfg.Tags,
+		lanes:           lanes,
+		classes:         classes,
+		outputs:         outputs,
+		client:          client,
+		transport:       transport,
+		sortByTime:      serverCfg.SortBatchByTime,
+		sendConcurrency: sendConcurrency,
+		maxBatchBytes:   serverCfg.MaxBatchBytes,
+		loki:            loki,
+		signingKey:      signingKey,
+		basicAuthUser:   serverCfg.BasicAuthUser,
+		basicAuthPass:   serverCfg.BasicAuthPassword,
+		headers:         serverCfg.Headers,
+		otlp:            otlp,
+		ingestPath:      ingestPath,
+		healthPath:      healthPath,
+		healthInterval:  healthInterval,
+		compress:        serverCfg.Compression == "gzip",
+		compressMin:     compressMin,
+		backoffBase:     backoffBase,
+		maxBackoff:      max(maxBackoff, backoffBase),
+		maxRetries:      serverCfg.MaxRetries,
+		breaker:         newBreaker(serverCfg.CircuitBreaker),
+		giveUp:          giveUp,
+		healthCodes:     healthCodes,
+		healthBodyMatch: healthBodyMatch,
+		connMaxAge:      serverCfg.MaxConnAge,
+		dedup:           dedup,
+		deadLetter:      deadLetter,
+		ledger:          ledger,
+		processors:      processors,
+		minLevel:        agentCfg.MinLevel,
+		limiter:         ratelimit.New(agentCfg.RateLimit),
+		sampler:         newSampler(agentCfg.Sampling),
+		schema:          newSchemaReport(agentCfg.SchemaReport),
+		throttle:        newCPUThrottle(agentCfg.CPUThrottle),
+		inFlight:        inFlight,
+		serverAlive:     true,
 	}, nil
 }
 
 // Start starts the sender loop
 func (s *Sender) Start(ctx context.Context) {
-	ticker := time.NewTicker(s.flushInterval)
-	defer ticker.Stop()
+	// Health check ticker (disabled when health_interval is 0)
+	var healthC <-chan time.Time
+	if s.healthInterval > 0 {
+		healthTicker := time.NewTicker(s.healthInterval)
+		defer healthTicker.Stop()
+		healthC = healthTicker.C
+	}
 
-	// Health check ticker
-	healthTicker := time.NewTicker(30 * time.Second)
-	defer healthTicker.Stop()
+	// Schema report ticker (disabled when nil)
+	var schemaC <-chan time.Time
+	if s.schema != nil {
+		schemaTicker := time.NewTicker(s.schema.interval)
+		defer schemaTicker.Stop()
+		schemaC = schemaTicker.C
+	}
 
-	fmt.Printf("  [sender] Started (flush every %v, batch size %d)\n", s.flushInterval, s.batchSize)
+	s.logger().Info("Started", "flush_interval", s.flushInterval, "batch_size", s.batchSize)
+	for _, l := range s.lanes[1:] {
+		s.logger().Info("Class lane started", "class", l.name, "flush_interval", l.flushInterval, "batch_size", l.batchSize)
+	}
 	logVerbose("Server URL: %s", s.serverURL)
+	if len(s.urls) > 1 {
+		s.logger().Info("Fallback URLs configured", "urls", s.urls[1:])
+	}
 	logVerbose("API Key: %s...", s.apiKey[:min(20, len(s.apiKey))])
 
+	// CPU throttle sampling (disabled when nil)
+	var throttleC <-chan time.Time
+	if s.throttle != nil {
+		throttleTicker := time.NewTicker(s.throttle.interval)
+		defer throttleTicker.Stop()
+		throttleC = throttleTicker.C
+	}
+
+	// Connection refresh ticker (disabled when max_conn_age is 0)
+	var refreshC <-chan time.Time
+	if s.connMaxAge > 0 {
+		refreshTicker := time.NewTicker(s.connMaxAge)
+		defer refreshTicker.Stop()
+		refreshC = refreshTicker.C
+	}
+
+	// Dedup filter saves (disabled when nil)
+	var dedupC <-chan time.Time
+	if s.dedup != nil {
+		dedupTicker := time.NewTicker(dedupSaveInterval)
+		defer dedupTicker.Stop()
+		dedupC = dedupTicker.C
+	}
+
 	// Initial health check
-	s.checkHealth(ctx)
-	if s.serverAlive {
-		fmt.Println("  [sender] Server is reachable ✓")
+	if s.healthInterval > 0 {
+		s.checkHealth(ctx)
+		if s.serverAlive {
+			s.logger().Info("Server is reachable", "url", s.activeURL())
+		} else {
+			s.logger().Warn("Server is not reachable, buffering logs", "url", s.activeURL())
+		}
 	} else {
-		fmt.Println("  [sender] Server is not reachable - will buffer logs")
+		logVerbose("Health checks disabled")
+	}
+
+	// Each lane flushes on its own schedule
+	var wg sync.WaitGroup
+	for _, l := range s.lanes {
+		wg.Add(1)
+		go func(l *lane) {
+			defer wg.Done()
+			s.runLane(ctx, l)
+		}(l)
+	}
+
+	// Outputs deliver independently; shutdown waits for their final flush too
+	for _, o := range s.outputs {
+		wg.Add(1)
+		go func(o *Sender) {
+			defer wg.Done()
+			o.Start(ctx)
+		}(o)
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			// Final flush before shutdown
-			s.flush(context.Background())
+			// Wait for the lanes' final flush, then keep what it delivered
+			wg.Wait()
+			s.saveDedup()
 			return
 
-		case <-ticker.C:
-			s.flush(ctx)
+		case <-dedupC:
+			s.saveDedup()
 
-		case <-healthTicker.C:
+		case <-healthC:
 			s.checkHealth(ctx)
+
+		case <-refreshC:
+			s.refreshConnections()
+
+		case <-throttleC:
+			s.throttle.sample()
+
+		case <-schemaC:
+			if entry, ok := s.schema.report(); ok {
+				s.Send(entry)
+			}
+		}
+	}
+}
+
+// runLane flushes a lane every flush interval until the context ends
+func (s *Sender) runLane(ctx context.Context, l *lane) {
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Final flush before shutdown, unless the server is known down
+			if s.breaker.allow() {
+				s.flush(context.Background(), l)
+			}
+			return
+
+		case <-ticker.C:
+			if !s.breaker.allow() {
+				logVerbose("Circuit breaker open, skipping %s flush", l.name)
+				continue
+			}
+			if s.backingOff() {
+				logVerbose("Backing off, skipping %s flush", l.name)
+				continue
+			}
+			s.flush(ctx, l)
 		}
 	}
 }
@@ -158,9 +565,38 @@ func (s *Sender) Send(entry buffer.LogEntry) error {
 		}
 	}
 
-	logVerbose("Queuing log: [%s] %s - %s", entry.Level, entry.Service, truncate(entry.Message, 50))
+	if s.throttle != nil && s.throttle.shed(entry) {
+		return nil
+	}
+	throttled := s.throttle != nil && s.throttle.active.Load()
+
+	for _, e := range s.processors.Process(entry) {
+		// The agent's own reports are exempt, or a high min_level would
+		// silence heartbeats
+		if e.Tags["report"] == "" && processor.BelowLevel(e.Level, s.minLevel) {
+			atomic.AddInt64(&s.filtered, 1)
+			continue
+		}
+		if e.Tags["report"] == "" && s.sampler != nil && !s.sampler.keep(e) {
+			continue
+		}
+		if e.Tags["report"] == "" && !s.limiter.Allow() {
+			continue
+		}
+
+		if s.schema != nil && !throttled {
+			s.schema.observe(e)
+		}
+
+		logVerbose("Queuing log: [%s] %s - %s", e.Level, e.Service, truncate(e.Message, 50))
 
-	return s.buffer.Push(entry)
+		if err := s.laneFor(e.Class).buffer.Push(e); err != nil {
+			return err
+		}
+		s.fanOut(e)
+	}
+
+	return nil
 }
 
 func truncate(s string, maxLen int) string {
@@ -177,10 +613,44 @@ func min(a, b int) int {
 	return b
 }
 
-// flush sends buffered logs to the server
-func (s *Sender) flush(ctx context.Context) {
+// Close closes the class and output buffers. The default buffer belongs to
+// the caller.
+func (s *Sender) Close() error {
+	s.saveDedup()
+
+	var firstErr error
+	for _, l := range s.classes {
+		if err := l.buffer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, o := range s.outputs {
+		if err := o.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := o.lanes[0].buffer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// laneFor returns the lane for a delivery class, falling back to the default lane
+func (s *Sender) laneFor(class string) *lane {
+	if class == "" {
+		return s.lanes[0]
+	}
+	if l, ok := s.classes[class]; ok {
+		return l
+	}
+	logVerbose("Unknown delivery class %q, using default", class)
+	return s.lanes[0]
+}
+
+// flush sends a lane's buffered logs to the server
+func (s *Sender) flush(ctx context.Context, l *lane) {
 	s.mu.Lock()
-	bufLen := s.buffer.Len()
+	bufLen := l.buffer.Len()
 	s.mu.Unlock()
 
 	if bufLen == 0 {
@@ -188,90 +658,309 @@ func (s *Sender) flush(ctx context.Context) {
 		return
 	}
 
-	logVerbose("Flushing buffer with %d entries", bufLen)
+	logVerbose("Flushing %s buffer with %d entries", l.name, bufLen)
 
-	// Process in batches
+	// Take up to sendConcurrency batches from the head of the buffer at a
+	// time and post them in parallel
 	for {
 		s.mu.Lock()
-		if s.buffer.Len() == 0 {
+		if l.buffer.Len() == 0 {
 			s.mu.Unlock()
 			break
 		}
 
-		entries, err := s.buffer.Peek(s.batchSize)
+		entries, err := l.buffer.Peek(l.batchSize * s.sendConcurrency)
 		s.mu.Unlock()
 
 		if err != nil || len(entries) == 0 {
 			break
 		}
 
-		logVerbose("Sending batch of %d logs...", len(entries))
-
-		// Send batch
-		if err := s.sendBatch(ctx, entries); err != nil {
+		acked, failed, err := s.sendWindow(ctx, l, entries)
+		if acked > 0 {
 			s.mu.Lock()
-			s.errorCount++
-			s.lastError = err.Error()
-			s.serverAlive = false
+			l.buffer.Remove(acked)
+			l.attempts, l.failingSince = 0, time.Time{}
 			s.mu.Unlock()
+		}
 
-			fmt.Printf("  [sender] ❌ Error sending logs: %v\n", err)
-			// Don't remove entries if send failed - they'll be retried
+		if err != nil {
+			delay := s.recordFailure()
+			s.logger().Error("Error sending logs", "error", err, "retry_in", delay.Round(time.Millisecond))
+			// Entries from the failed batch on stay buffered for the retry,
+			// unless it has used up its retries
+			s.countAttempt(l, entries[acked:acked+failed], err)
 			break
 		}
+		s.recordSuccess()
+	}
+}
+
+// sendWindow posts entries as concurrent batches and returns how many
+// entries from the head of the window may be removed from the buffer: those
+// of the batches before the first failure. failed is the size of the failed
+// batch. Batches that succeeded after a
+// failed one stay buffered and are sent again, so delivery is at least once;
+// with dedup enabled the resend is filtered out.
+func (s *Sender) sendWindow(ctx context.Context, l *lane, entries []buffer.LogEntry) (acked, failed int, err error) {
+	batches := s.splitBatches(entries, l.batchSize)
+
+	errs := make([]error, len(batches))
+	if len(batches) == 1 {
+		errs[0] = s.sendBatch(ctx, batches[0])
+	} else {
+		var wg sync.WaitGroup
+		for i, batch := range batches {
+			wg.Add(1)
+			go func(i int, batch []buffer.LogEntry) {
+				defer wg.Done()
+				errs[i] = s.sendBatch(ctx, batch)
+			}(i, batch)
+		}
+		wg.Wait()
+	}
 
-		// Remove sent entries
+	for i, batch := range batches {
+		if errs[i] != nil {
+			return acked, len(batch), errs[i]
+		}
+		acked += len(batch)
+	}
+	return acked, 0, nil
+}
+
+// splitBatches cuts entries into at most sendConcurrency batches of up to
+// batchSize entries and maxBatchBytes encoded bytes. An entry larger than
+// the byte budget goes alone. Entries past the last batch are left for the
+// next round.
+func (s *Sender) splitBatches(entries []buffer.LogEntry, batchSize int) [][]buffer.LogEntry {
+	var batches [][]buffer.LogEntry
+	start, used := 0, 0
+
+	for i, entry := range entries {
+		size := 0
+		if s.maxBatchBytes > 0 {
+			data, _ := json.Marshal(entry)
+			size = len(data) + 1 // Separating comma
+		}
+
+		full := i-start >= batchSize || (s.maxBatchBytes > 0 && i > start && used+size > s.maxBatchBytes)
+		if full {
+			batches = append(batches, entries[start:i])
+			if len(batches) == s.sendConcurrency {
+				return batches
+			}
+			start, used = i, 0
+		}
+		used += size
+	}
+
+	if start < len(entries) {
+		batches = append(batches, entries[start:])
+	}
+	return batches
+}
+
+// sendBatch delivers one batch, skipping entries already delivered
+func (s *Sender) sendBatch(ctx context.Context, entries []buffer.LogEntry) error {
+	// Skip entries confirmed delivered before a restart
+	batch := s.filterDelivered(entries)
+	dups := len(entries) - len(batch)
+
+	// Entries dead-lettered before a retriable failure interrupted the
+	// isolation are neither written nor sent again
+	batch = s.deadLetter.skipIsolated(batch)
+	if len(batch) == 0 {
+		s.deadLetter.forget(entries)
 		s.mu.Lock()
-		s.buffer.Remove(len(entries))
-		s.sentCount += int64(len(entries))
-		s.lastSent = time.Now()
-		s.serverAlive = true
+		s.dupSkipped += int64(dups)
 		s.mu.Unlock()
+		return nil
+	}
 
-		fmt.Printf("  [sender] ✓ Sent %d logs (total: %d)\n", len(entries), s.sentCount)
+	if s.sortByTime {
+		batch = sortByTimestamp(batch)
+	}
+
+	logVerbose("Sending batch of %d logs...", len(batch))
+
+	err := s.sendWithFailover(ctx, batch)
+	dead := 0
+	if err != nil && s.deadLetter != nil && rejected(err) {
+		// Find the refused entries so they stop blocking the rest
+		dead, err = s.isolateRejected(ctx, batch, err)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.errorCount++
+		s.lastError = err.Error()
+		s.serverAlive = false
+		s.mu.Unlock()
+		return err
 	}
+
+	s.markDelivered(batch)
+	s.deadLetter.forget(entries)
+
+	s.mu.Lock()
+	s.sentCount += int64(len(batch) - dead)
+	s.batchesSent++
+	s.dupSkipped += int64(dups)
+	s.lastSent = time.Now()
+	s.serverAlive = true
+	total := s.sentCount
+	s.mu.Unlock()
+
+	s.logger().Info("Sent logs", "count", len(batch)-dead, "total", total)
+	return nil
 }
 
-// sendBatch sends a batch of logs to the server
-func (s *Sender) sendBatch(ctx context.Context, entries []buffer.LogEntry) error {
-	payload := LogPayload{
+// filterDelivered drops entries whose fingerprint is already in the dedup filter
+func (s *Sender) filterDelivered(entries []buffer.LogEntry) []buffer.LogEntry {
+	if s.dedup == nil {
+		return entries
+	}
+
+	batch := make([]buffer.LogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if s.dedup.Test(entry.Fingerprint()) {
+			logVerbose("Skipping already delivered log: %s", truncate(entry.Message, 50))
+			continue
+		}
+		batch = append(batch, entry)
+	}
+	return batch
+}
+
+// sortByTimestamp returns a copy of the batch ordered by timestamp. The sort
+// is stable so entries with equal timestamps keep their arrival order.
+func sortByTimestamp(entries []buffer.LogEntry) []buffer.LogEntry {
+	sorted := make([]buffer.LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return sorted
+}
+
+// markDelivered records delivered entries in the dedup filter. The filter
+// is saved periodically and on shutdown rather than after every batch.
+func (s *Sender) markDelivered(entries []buffer.LogEntry) {
+	if s.dedup == nil {
+		return
+	}
+
+	for _, entry := range entries {
+		s.dedup.Add(entry.Fingerprint())
+	}
+}
+
+// dedupSaveInterval is how often the dedup filter is saved. Entries
+// delivered since the last save may be sent again after a crash.
+const dedupSaveInterval = 5 * time.Second
+
+// saveDedup persists the dedup filter if entries were added since the last save
+func (s *Sender) saveDedup() {
+	if s.dedup == nil {
+		return
+	}
+	if err := s.dedup.Save(); err != nil {
+		log.Error("Error saving dedup filter", "error", err)
+	}
+}
+
+// newPayload wraps entries with the agent info
+func (s *Sender) newPayload(entries []buffer.LogEntry) LogPayload {
+	return LogPayload{
 		Agent: AgentInfo{
 			Hostname:    s.hostname,
 			Environment: s.environment,
-			Version:     "1.0.0",
+			Version:     agentVersion,
+			GitCommit:   agentCommit,
 			Tags:        s.tags,
 		},
 		Logs: entries,
 	}
+}
 
-	data, err := json.Marshal(payload)
+// encode serializes a payload in the server's format
+func (s *Sender) encode(payload LogPayload) ([]byte, error) {
+	switch {
+	case s.loki != nil:
+		return s.loki.encode(payload)
+	case s.otlp != nil:
+		return s.otlp.encode(payload)
+	}
+	return json.Marshal(payload)
+}
+
+// post sends a payload to the ingest endpoint and returns the response body
+func (s *Sender) post(ctx context.Context, url string, payload LogPayload) ([]byte, error) {
+	data, err := s.encode(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal logs: %w", err)
+		return nil, fmt.Errorf("failed to marshal logs: %w", err)
 	}
 
-	logVerbose("Request payload size: %d bytes", len(data))
-	if Verbose {
-		fmt.Printf("[sender] Payload: %s\n", string(data[:min(500, len(data))]))
+	rawLen := len(data)
+	logVerbose("Request payload size: %d bytes", rawLen)
+	logVerbose("Payload: %s", data[:min(500, len(data))])
+
+	compressed := false
+	if s.compress && len(data) >= s.compressMin {
+		zipped, err := gzipPayload(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress logs: %w", err)
+		}
+		logVerbose("Compressed payload %d -> %d bytes", len(data), len(zipped))
+		data, compressed = zipped, true
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", s.serverURL+"/api/logs/ingest", bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(s.withConnTrace(ctx), "POST", url+s.ingestPath, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "LogChat-Agent/1.0")
+	if s.otlp != nil {
+		req.Header.Set("Content-Type", s.otlp.contentType())
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("User-Agent", "LogChat-Agent/"+agentVersion)
 	req.Header.Set("X-API-Key", s.apiKey)
 
-	if s.apiKey != "" {
+	if s.apiKey != "" && s.basicAuthUser == "" {
 		req.Header.Set("Authorization", "Bearer "+s.apiKey)
 	}
+	s.setAuthHeaders(req)
+	if s.loki != nil && s.loki.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.loki.tenant)
+	}
+	if s.signingKey != nil {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		req.Header.Set("X-Signature", signPayload(s.signingKey, timestamp, data))
+	}
+
+	// Wait for a free in-flight slot
+	if s.inFlight != nil {
+		select {
+		case s.inFlight <- struct{}{}:
+			defer func() { <-s.inFlight }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	atomic.AddInt64(&s.inFlightCount, 1)
+	defer atomic.AddInt64(&s.inFlightCount, -1)
 
-	logVerbose("POST %s/api/logs/ingest", s.serverURL)
+	logVerbose("POST %s%s", url, s.ingestPath)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -279,31 +968,110 @@ func (s *Sender) sendBatch(ctx context.Context, entries []buffer.LogEntry) error
 	logVerbose("Response: %d - %s", resp.StatusCode, string(body))
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+		return body, &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
-	return nil
+	atomic.AddInt64(&s.bytesSent, int64(rawLen))
+	return body, nil
+}
+
+// signPayload returns hex(HMAC-SHA256(key, timestamp + "." + body)). The
+// timestamp is signed too, so a captured request can't be replayed later
+// under a fresh one.
+func signPayload(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// checkHealth checks if the server is reachable
+// checkHealth probes every configured URL, falling back when the active one
+// is down and returning to the primary once it recovers
 func (s *Sender) checkHealth(ctx context.Context) {
-	req, err := http.NewRequestWithContext(ctx, "GET", s.serverURL+"/api/health", nil)
+	alive := make([]bool, len(s.urls))
+	for i, url := range s.urls {
+		alive[i] = s.probe(ctx, url)
+	}
+
+	s.mu.Lock()
+	copy(s.urlAlive, alive)
+	active := s.active
+	s.mu.Unlock()
+
+	if active != 0 && alive[0] {
+		s.switchTo(0, "primary recovered")
+	} else if !alive[active] {
+		for i, ok := range alive {
+			if ok {
+				s.switchTo(i, "health check failed")
+				break
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.serverAlive = alive[s.active]
+	healthy := s.serverAlive
+	s.mu.Unlock()
+
+	if healthy && s.breaker.healthy() {
+		s.logger().Info("Server healthy, circuit breaker half-open", "url", s.activeURL())
+	}
+}
+
+// setAuthHeaders adds basic auth and the configured headers to a request
+func (s *Sender) setAuthHeaders(req *http.Request) {
+	if s.basicAuthUser != "" {
+		req.SetBasicAuth(s.basicAuthUser, s.basicAuthPass)
+	}
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// probe checks if a single server URL is reachable
+func (s *Sender) probe(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(s.withConnTrace(ctx), "GET", url+s.healthPath, nil)
 	if err != nil {
-		return
+		return false
 	}
+	req.Header.Set("User-Agent", "LogChat-Agent/"+agentVersion)
+	s.setAuthHeaders(req)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		s.mu.Lock()
-		s.serverAlive = false
-		s.mu.Unlock()
-		return
+		return false
 	}
 	defer resp.Body.Close()
 
-	s.mu.Lock()
-	s.serverAlive = resp.StatusCode == 200
-	s.mu.Unlock()
+	if !s.healthyStatus(resp.StatusCode) {
+		logVerbose("Health check %s: unexpected status %d", url, resp.StatusCode)
+		return false
+	}
+
+	if s.healthBodyMatch != "" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if !strings.Contains(string(body), s.healthBodyMatch) {
+			logVerbose("Health check %s: body does not contain %q", url, s.healthBodyMatch)
+			return false
+		}
+	}
+
+	return true
+}
+
+// healthyStatus reports whether a health endpoint status code is accepted
+func (s *Sender) healthyStatus(code int) bool {
+	if len(s.healthCodes) == 0 {
+		return code == 200
+	}
+	for _, c := range s.healthCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
 }
 
 // Stats returns sender statistics
@@ -311,14 +1079,113 @@ func (s *Sender) Stats() map[string]any {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return map[string]any{
-		"sent_count":    s.sentCount,
-		"error_count":   s.errorCount,
-		"last_sent":     s.lastSent,
-		"last_error":    s.lastError,
-		"server_alive":  s.serverAlive,
-		"buffer_length": s.buffer.Len(),
+	stats := map[string]any{
+		"sent_count":     s.sentCount,
+		"error_count":    s.errorCount,
+		"last_sent":      s.lastSent,
+		"last_error":     s.lastError,
+		"server_alive":   s.serverAlive,
+		"buffer_length":  s.bufferLen(),
+		"buffer_bytes":   s.bufferSize(),
+		"dup_skipped":    s.dupSkipped,
+		"logs_filtered":  atomic.LoadInt64(&s.filtered),
+		"rate_limited":   s.limiter.Dropped(),
+		"dead_lettered":  atomic.LoadInt64(&s.deadLettered),
+		"in_flight":      atomic.LoadInt64(&s.inFlightCount),
+		"active_url":     s.urls[s.active],
+		"switchovers":    s.switchovers,
+		"conn_reused":    atomic.LoadInt64(&s.connReused),
+		"conn_new":       atomic.LoadInt64(&s.connNew),
+		"conn_refreshes": atomic.LoadInt64(&s.connRefreshes),
+
+		"consecutive_failures": s.consecutiveFailures,
+	}
+
+	if s.maxRetries > 0 {
+		stats["max_retries"] = s.maxRetries
+		stats["give_up"] = s.giveUp
+		stats["given_up"] = s.givenUp
+	}
+	for _, l := range s.lanes {
+		if l.attempts == 0 {
+			continue
+		}
+		stuck, _ := stats["stuck_batches"].(map[string]any)
+		if stuck == nil {
+			stuck = make(map[string]any)
+			stats["stuck_batches"] = stuck
+		}
+		stuck[l.name] = map[string]any{
+			"attempts":      l.attempts,
+			"failing_since": l.failingSince,
+			"stuck_for":     time.Since(l.failingSince).Round(time.Second).String(),
+		}
+	}
+
+	if s.batchesSent > 0 {
+		stats["avg_batch_size"] = float64(s.sentCount) / float64(s.batchesSent)
+		stats["avg_batch_bytes"] = float64(atomic.LoadInt64(&s.bytesSent)) / float64(s.batchesSent)
+	}
+
+	if !s.retryAt.IsZero() {
+		stats["backoff_until"] = s.retryAt
+	}
+
+	if len(s.classes) > 0 {
+		classLengths := make(map[string]int, len(s.classes))
+		for name, l := range s.classes {
+			classLengths[name] = l.buffer.Len()
+		}
+		stats["class_buffer_lengths"] = classLengths
+	}
+
+	if s.throttle != nil {
+		stats["cpu_throttle"] = s.throttle.stats()
+	}
+
+	if s.breaker != nil {
+		stats["circuit_breaker"] = s.breaker.stats()
+	}
+
+	if s.sampler != nil {
+		stats["sampling"] = s.sampler.stats()
+	}
+
+	if s.ledger != nil {
+		seq, head := s.ledger.state()
+		stats["ledger_seq"] = seq
+		stats["ledger_head"] = head
+	}
+
+	if len(s.outputs) > 0 {
+		outputs := make(map[string]any, len(s.outputs))
+		for _, o := range s.outputs {
+			outputs[strings.TrimPrefix(o.name, "output:")] = o.outputStats()
+		}
+		stats["outputs"] = outputs
+	}
+
+	return stats
+}
+
+// bufferLen returns the number of entries buffered across all lanes
+func (s *Sender) bufferLen() int {
+	total := 0
+	for _, l := range s.lanes {
+		total += l.buffer.Len()
+	}
+	return total
+}
+
+// bufferSize returns the bytes buffered across lanes whose buffers track it
+func (s *Sender) bufferSize() int64 {
+	var total int64
+	for _, l := range s.lanes {
+		if sized, ok := l.buffer.(interface{ Size() int64 }); ok {
+			total += sized.Size()
+		}
 	}
+	return total
 }
 
 // IsServerAlive returns whether the server is reachable
@@ -0,0 +1,410 @@
+package sender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+	"logchat/agent/internal/logging"
+)
+
+// newTestSender creates an unstarted sender posting to url
+func newTestSender(t testing.TB, cfg config.ServerConfig) *Sender {
+	t.Helper()
+
+	buf, err := buffer.New(config.BufferConfig{Type: "memory", MaxItems: 1000, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg, config.AgentConfig{}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testEntries creates one entry per message
+func testEntries(messages ...string) []buffer.LogEntry {
+	now := time.Now()
+	entries := make([]buffer.LogEntry, len(messages))
+	for i, m := range messages {
+		entries[i] = buffer.LogEntry{Timestamp: now, Level: "INFO", Message: m}
+	}
+	return entries
+}
+
+// verifyingServer accepts requests signed with key over
+// "<X-Signature-Timestamp>.<body>" and refuses the rest
+func verifyingServer(t *testing.T, key string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		sent, err := strconv.ParseInt(r.Header.Get("X-Signature-Timestamp"), 10, 64)
+		if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+			http.Error(w, "stale or missing timestamp", http.StatusUnauthorized)
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(r.Header.Get("X-Signature-Timestamp") + "."))
+		mac.Write(body)
+		got, err := hex.DecodeString(r.Header.Get("X-Signature"))
+		if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("body wasn't compressed, so the signature didn't cover gzip bytes")
+		}
+	}))
+}
+
+func TestSigningKeySignsTimestampAndBody(t *testing.T) {
+	srv := verifyingServer(t, "secret")
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		key    string
+		wantOK bool
+	}{
+		{"secret", true},
+		{"other", false},
+	} {
+		s := newTestSender(t, config.ServerConfig{
+			URL:              srv.URL,
+			SigningKey:       tc.key,
+			Compression:      "gzip",
+			CompressMinBytes: 1,
+		})
+		err := s.sendBatch(context.Background(), testEntries("signed entry"))
+		if ok := err == nil; ok != tc.wantOK {
+			t.Errorf("key %q: send error %v, want accepted %v", tc.key, err, tc.wantOK)
+		}
+	}
+}
+
+func TestSignedRequestReplayWithFreshTimestamp(t *testing.T) {
+	verifier := verifyingServer(t, "secret")
+	defer verifier.Close()
+
+	// Capture a signed request on its way to the verifier
+	var captured *http.Request
+	var body []byte
+	capture := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		captured = r
+	}))
+	defer capture.Close()
+
+	s := newTestSender(t, config.ServerConfig{
+		URL:              capture.URL,
+		SigningKey:       "secret",
+		Compression:      "gzip",
+		CompressMinBytes: 1,
+	})
+	if err := s.sendBatch(context.Background(), testEntries("signed entry")); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := func(timestamp string) int {
+		req, err := http.NewRequest(http.MethodPost, verifier.URL+captured.URL.Path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = captured.Header.Clone()
+		req.Header.Set("X-Signature-Timestamp", timestamp)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := replay(captured.Header.Get("X-Signature-Timestamp")); code != http.StatusOK {
+		t.Fatalf("unchanged request refused with %d", code)
+	}
+	fresh := strconv.FormatInt(time.Now().Unix()+1, 10)
+	if code := replay(fresh); code != http.StatusUnauthorized {
+		t.Errorf("replay with a fresh timestamp got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestRedactedSecretNeverLeavesTheHost(t *testing.T) {
+	const secret = "hunter2-s3cr3t"
+
+	var mu sync.Mutex
+	var bodies []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	// Debug output includes the payload, so it must be redacted too
+	var logs bytes.Buffer
+	logging.SetOutput(&logs)
+	if err := logging.Setup("debug", "text"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		logging.SetOutput(os.Stdout)
+		logging.Setup("info", "text")
+	})
+
+	buf, err := buffer.New(config.BufferConfig{Type: "memory", MaxItems: 1000, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(config.ServerConfig{URL: srv.URL, BatchSize: 10, SendConcurrency: 1}, config.AgentConfig{
+		Redact: &config.RedactConfig{
+			Rules:    []config.RedactRule{{Pattern: `token=(\S+)`}},
+			Metadata: true,
+		},
+	}, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Send(buffer.LogEntry{
+		Timestamp: time.Now(),
+		Level:     "INFO",
+		Message:   "login with token=" + secret,
+		Metadata:  map[string]any{"query": "token=" + secret},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.flush(context.Background(), s.lanes[0])
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !bytes.Contains(bodies, []byte("token=***")) {
+		t.Fatalf("payload %s has no redacted token", bodies)
+	}
+	if bytes.Contains(bodies, []byte(secret)) {
+		t.Errorf("secret in payload: %s", bodies)
+	}
+	if !strings.Contains(logs.String(), "Payload") {
+		t.Fatalf("payload wasn't logged at debug level:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), secret) {
+		t.Errorf("secret in logs:\n%s", logs.String())
+	}
+}
+
+func TestBasicAuthAndHeadersOnEveryRequest(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]http.Header) // Path -> request headers
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s := newTestSender(t, config.ServerConfig{
+		URL:               srv.URL,
+		APIKey:            "key",
+		BasicAuthUser:     "agent",
+		BasicAuthPassword: "pass",
+		Headers:           map[string]string{"X-Tenant-ID": "tenant-1"},
+	})
+	if err := s.sendBatch(context.Background(), testEntries("entry")); err != nil {
+		t.Fatal(err)
+	}
+	if !s.probe(context.Background(), srv.URL) {
+		t.Fatal("health probe failed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 2 {
+		t.Fatalf("requests to %d paths, want ingest and health", len(seen))
+	}
+	for path, header := range seen {
+		req := &http.Request{Header: header}
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != "agent" || pass != "pass" {
+			t.Errorf("%s: basic auth = %q, %q, %v", path, user, pass, ok)
+		}
+		if got := header.Get("X-Tenant-ID"); got != "tenant-1" {
+			t.Errorf("%s: X-Tenant-ID = %q, want tenant-1", path, got)
+		}
+		if got := header.Get("Authorization"); strings.HasPrefix(got, "Bearer") {
+			t.Errorf("%s: bearer token sent with basic auth", path)
+		}
+	}
+}
+
+func TestGzipPayloadRoundTrip(t *testing.T) {
+	var mu sync.Mutex
+	var received []LogPayload
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer zr.Close()
+			body = zr
+		}
+		var payload LogPayload
+		if err := json.NewDecoder(body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, payload)
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name     string
+		minBytes int
+		encoding string
+	}{
+		{"compressed", 1, "gzip"},
+		{"below threshold", 1 << 20, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			received, encodings = nil, nil
+			mu.Unlock()
+
+			s := newTestSender(t, config.ServerConfig{
+				URL:              srv.URL,
+				Compression:      "gzip",
+				CompressMinBytes: tc.minBytes,
+			})
+			want := []string{"first entry", "ünïcode entry", strings.Repeat("long entry ", 200)}
+			if err := s.sendBatch(context.Background(), testEntries(want...)); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != 1 {
+				t.Fatalf("server decoded %d payloads, want 1", len(received))
+			}
+			if encodings[0] != tc.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", encodings[0], tc.encoding)
+			}
+			logs := received[0].Logs
+			if len(logs) != len(want) {
+				t.Fatalf("got %d logs, want %d", len(logs), len(want))
+			}
+			for i := range want {
+				if logs[i].Message != want[i] || logs[i].Level != "INFO" {
+					t.Errorf("log %d = %q %q, want INFO %q", i, logs[i].Level, logs[i].Message, want[i])
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFlushConcurrency drains a deep buffer through a server with a
+// fixed round-trip latency, one flush per iteration
+func BenchmarkFlushConcurrency(b *testing.B) {
+	const (
+		entries   = 1000
+		batchSize = 50
+		latency   = 2 * time.Millisecond
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(latency)
+	}))
+	defer srv.Close()
+
+	// Each batch would log "Sent logs"
+	logging.Setup("warn", "text")
+	defer logging.Setup("info", "text")
+
+	batch := testEntries(make([]string, entries)...)
+	for i := range batch {
+		batch[i].Message = fmt.Sprintf("benchmark entry %04d", i)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := newTestSender(b, config.ServerConfig{URL: srv.URL, BatchSize: batchSize, SendConcurrency: workers})
+			l := s.lanes[0]
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for _, e := range batch {
+					l.buffer.Push(e)
+				}
+				b.StartTimer()
+
+				s.flush(context.Background(), l)
+			}
+			b.StopTimer()
+
+			if n := l.buffer.Len(); n != 0 {
+				b.Fatalf("%d entries left after flush", n)
+			}
+			b.ReportMetric(float64(entries*b.N)/b.Elapsed().Seconds(), "entries/s")
+		})
+	}
+}
+
+func TestDedupKeepsRepeatedLinesAtDifferentOffsets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	s := newTestSender(t, config.ServerConfig{
+		URL: srv.URL,
+		Dedup: &config.DedupConfig{
+			Enabled:           true,
+			Path:              filepath.Join(t.TempDir(), "delivered.bloom"),
+			Capacity:          1000,
+			FalsePositiveRate: 0.001,
+		},
+	})
+
+	// The same health-check line logged twice within one second, read with
+	// include_offset, lands in two batches
+	line := func(offset int64) []buffer.LogEntry {
+		batch := testEntries("GET /health 200")
+		batch[0].Timestamp = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		batch[0].Metadata = map[string]any{"file_path": "/var/log/app.log", "file_offset": offset}
+		return batch
+	}
+	if err := s.sendBatch(context.Background(), line(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.filterDelivered(line(16)); len(got) != 1 {
+		t.Error("repeated line at a new offset filtered as already delivered")
+	}
+	if got := s.filterDelivered(line(0)); len(got) != 0 {
+		t.Error("replayed line at the same offset not filtered")
+	}
+}
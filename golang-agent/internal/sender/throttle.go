@@ -0,0 +1,115 @@
+package sender
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/buffer"
+	"logchat/agent/internal/config"
+)
+
+// cpuThrottle samples the agent's own CPU usage and, while it is above the
+// limit, sheds non-essential work: DEBUG entries are dropped and schema
+// sampling pauses until usage falls back below the resume threshold
+type cpuThrottle struct {
+	mu sync.Mutex
+
+	interval      time.Duration
+	maxPercent    float64
+	resumePercent float64
+
+	lastCPU  time.Duration
+	lastWall time.Time
+	percent  float64
+
+	active  atomic.Bool
+	dropped int64 // atomic
+}
+
+func newCPUThrottle(cfg *config.CPUThrottleConfig) *cpuThrottle {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	t := &cpuThrottle{
+		interval:      cfg.Interval,
+		maxPercent:    cfg.MaxPercent,
+		resumePercent: cfg.ResumePercent,
+		lastWall:      time.Now(),
+	}
+	if t.interval <= 0 {
+		t.interval = 5 * time.Second
+	}
+	if t.maxPercent <= 0 {
+		t.maxPercent = 50
+	}
+	if t.resumePercent <= 0 || t.resumePercent > t.maxPercent {
+		t.resumePercent = t.maxPercent * 0.8
+	}
+	if cpu, err := processCPUTime(); err == nil {
+		t.lastCPU = cpu
+	}
+	return t
+}
+
+// sample measures CPU usage since the last sample as a percentage of one core
+// and updates the throttle state
+func (t *cpuThrottle) sample() {
+	cpu, err := processCPUTime()
+	if err != nil {
+		logVerbose("CPU sample failed: %v", err)
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	wall := now.Sub(t.lastWall)
+	if wall <= 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.percent = float64(cpu-t.lastCPU) / float64(wall) * 100
+	t.lastCPU = cpu
+	t.lastWall = now
+	percent := t.percent
+	t.mu.Unlock()
+
+	switch {
+	case !t.active.Load() && percent > t.maxPercent:
+		t.active.Store(true)
+		log.Warn("CPU over limit, throttling: dropping DEBUG entries", "cpu_percent", math.Round(percent), "max_percent", t.maxPercent)
+	case t.active.Load() && percent < t.resumePercent:
+		t.active.Store(false)
+		log.Info("Throttle released", "cpu_percent", math.Round(percent), "dropped", atomic.LoadInt64(&t.dropped))
+	}
+}
+
+// shed reports whether the entry should be dropped while throttled
+func (t *cpuThrottle) shed(entry buffer.LogEntry) bool {
+	if !t.active.Load() {
+		return false
+	}
+	switch strings.ToUpper(entry.Level) {
+	case "DEBUG", "TRACE":
+		atomic.AddInt64(&t.dropped, 1)
+		return true
+	}
+	return false
+}
+
+// stats returns the throttle state
+func (t *cpuThrottle) stats() map[string]any {
+	t.mu.Lock()
+	percent := t.percent
+	t.mu.Unlock()
+
+	return map[string]any{
+		"active":      t.active.Load(),
+		"cpu_percent": math.Round(percent*10) / 10,
+		"max_percent": t.maxPercent,
+		"dropped":     atomic.LoadInt64(&t.dropped),
+	}
+}
@@ -0,0 +1,135 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"logchat/agent/internal/config"
+)
+
+// newTransport builds the HTTP transport from the server settings
+func newTransport(cfg config.ServerConfig) (*http.Transport, error) {
+	idleTimeout := cfg.IdleConnTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 30 * time.Second
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     idleTimeout,
+		DisableCompression:  false,
+		TLSHandshakeTimeout: 10 * time.Second,
+		// A custom dialer turns off HTTP/2 unless forced; keep it on by default
+		ForceAttemptHTTP2: true,
+	}
+
+	if cfg.HTTP2 != nil && !*cfg.HTTP2 {
+		// A non-nil empty map disables HTTP/2 negotiation
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	proxy, err := proxyFunc(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// proxyFunc resolves the proxy setting: empty follows HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY, "none" connects directly, anything else is a proxy URL
+func proxyFunc(setting string) (func(*http.Request) (*url.URL, error), error) {
+	switch setting {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case "none":
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(setting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https or socks5)", proxyURL.Scheme)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// newTLSConfig applies insecure, ca_file and the client certificate, or
+// returns nil for Go's defaults
+func newTLSConfig(cfg config.ServerConfig) (*tls.Config, error) {
+	if !cfg.Insecure && cfg.CAFile == "" && cfg.CertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// withConnTrace records whether requests reuse a pooled connection
+func (s *Sender) withConnTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&s.connReused, 1)
+			} else {
+				atomic.AddInt64(&s.connNew, 1)
+			}
+		},
+	})
+}
+
+// refreshConnections drops idle pooled connections so a connection silently
+// dropped by a load balancer isn't picked up by the next send
+func (s *Sender) refreshConnections() {
+	s.transport.CloseIdleConnections()
+	atomic.AddInt64(&s.connRefreshes, 1)
+	logVerbose("Closed idle connections")
+}
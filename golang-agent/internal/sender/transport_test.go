@@ -0,0 +1,145 @@
+package sender
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"logchat/agent/internal/config"
+)
+
+// testProxy is a forward proxy that records what it was asked to reach.
+// Plain HTTP requests are forwarded; CONNECT opens a tunnel for HTTPS.
+type testProxy struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	seen []string // Method and target of each proxied request
+}
+
+func newTestProxy(t *testing.T) *testProxy {
+	p := &testProxy{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.seen = append(p.seen, r.Method+" "+r.Host)
+		p.mu.Unlock()
+
+		if r.Method == http.MethodConnect {
+			p.tunnel(t, w, r)
+			return
+		}
+
+		out, err := http.NewRequest(r.Method, r.URL.String(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out.Header = r.Header.Clone()
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// tunnel splices the client connection to the CONNECT target
+func (p *testProxy) tunnel(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	client, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		t.Errorf("hijack: %v", err)
+		upstream.Close()
+		return
+	}
+	rw.Flush()
+
+	go func() {
+		io.Copy(upstream, rw)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
+
+// requests returns the proxied requests so far
+func (p *testProxy) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.seen...)
+}
+
+func TestSendThroughProxy(t *testing.T) {
+	target := func(tls bool) *httptest.Server {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+		})
+		if tls {
+			return httptest.NewTLSServer(handler)
+		}
+		return httptest.NewServer(handler)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tls    bool
+		method string
+	}{
+		{"http", false, http.MethodPost},
+		{"https", true, http.MethodConnect},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := target(tc.tls)
+			defer srv.Close()
+			proxy := newTestProxy(t)
+
+			s := newTestSender(t, config.ServerConfig{URL: srv.URL, Proxy: proxy.URL, Insecure: tc.tls})
+			if err := s.sendBatch(context.Background(), testEntries("via proxy")); err != nil {
+				t.Fatal(err)
+			}
+
+			seen := proxy.requests()
+			want := tc.method + " " + srv.Listener.Addr().String()
+			if len(seen) == 0 || seen[0] != want {
+				t.Errorf("proxy saw %q, want %q", seen, want)
+			}
+		})
+	}
+}
+
+func TestProxySettingValidation(t *testing.T) {
+	for _, tc := range []struct {
+		setting string
+		wantErr bool
+	}{
+		{"", false},
+		{"none", false},
+		{"http://proxy:3128", false},
+		{"socks5://127.0.0.1:1080", false},
+		{"ftp://proxy:21", true},
+		{"http://[bad", true},
+	} {
+		if _, err := proxyFunc(tc.setting); (err != nil) != tc.wantErr {
+			t.Errorf("proxyFunc(%q) error = %v, want error %v", tc.setting, err, tc.wantErr)
+		}
+	}
+
+	// "none" bypasses HTTP_PROXY and HTTPS_PROXY as well
+	if proxy, _ := proxyFunc("none"); proxy != nil {
+		t.Error(`proxyFunc("none") returned a proxy`)
+	}
+}